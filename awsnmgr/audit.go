@@ -0,0 +1,343 @@
+package awsnmgr
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/awslabs/smithy-go/middleware"
+	"github.com/nuagenetworks/go-bambou/bambou"
+	log "github.com/sirupsen/logrus"
+)
+
+// redacted replaces the secrets in the parameters of the audit log
+const redacted = "REDACTED"
+
+// mutatingPrefixes are the prefixes of the AWS operations that change resources
+var mutatingPrefixes = []string{
+	"Accept", "Associate", "Attach", "Create", "Delete", "Deregister", "Detach", "Disable",
+	"Disassociate", "Enable", "Modify", "Register", "Reject", "Replace", "Tag", "Untag", "Update",
+}
+
+// secretKeys are the parameter names, in lower case, whose values are redacted
+var secretKeys = []string{"presharedkey", "psk", "password", "secret", "token"}
+
+// AuditEntry is a mutating AWS or VSD call in the audit log
+type AuditEntry struct {
+	Time        time.Time   `json:"time" yaml:"time"`
+	RunID       string      `json:"runId,omitempty" yaml:"run-id,omitempty"`
+	User        string      `json:"user" yaml:"user"`
+	AWSIdentity string      `json:"awsIdentity,omitempty" yaml:"aws-identity,omitempty"`
+	Command     string      `json:"command,omitempty" yaml:"command,omitempty"`
+	Service     string      `json:"service" yaml:"service"`
+	Region      string      `json:"region,omitempty" yaml:"region,omitempty"`
+	Operation   string      `json:"operation" yaml:"operation"`
+	Params      interface{} `json:"params,omitempty" yaml:"params,omitempty"`
+	Result      string      `json:"result" yaml:"result"`
+	Error       string      `json:"error,omitempty" yaml:"error,omitempty"`
+	ResourceIDs []string    `json:"resourceIds,omitempty" yaml:"resource-ids,omitempty"`
+}
+
+// auditLog appends the entries to the audit log file, the file is opened in append
+// mode for every entry so it is never truncated
+type auditLog struct {
+	mu   sync.Mutex
+	file string
+	user string
+}
+
+func newAuditLog(file string) *auditLog {
+	a := &auditLog{file: file}
+	if u, err := user.Current(); err == nil {
+		a.user = u.Username
+	} else {
+		a.user = os.Getenv("USER")
+	}
+	return a
+}
+
+// write appends the entry to the audit log, a failed write is logged and does not fail
+// the call
+func (a *auditLog) write(e *AuditEntry) {
+	if a == nil || a.file == "" {
+		return
+	}
+	e.User = a.user
+	b, err := json.Marshal(e)
+	if err != nil {
+		log.Warnf("Error writing audit log entry %s: %s", e.Operation, err)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.OpenFile(a.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Warnf("Error opening audit log %s: %s", a.file, err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		log.Warnf("Error writing audit log %s: %s", a.file, err)
+	}
+}
+
+// isMutating returns if the AWS operation changes resources
+func isMutating(operation string) bool {
+	for _, p := range mutatingPrefixes {
+		if strings.HasPrefix(operation, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// addAuditLog writes the mutating calls of the AWS clients of the config to the audit
+// log with the caller identity of the config, it runs after the service metadata is set
+// in the context
+func (nm *NMgr) addAuditLog(cfg *aws.Config, identity func() (*sts.GetCallerIdentityOutput, error)) {
+	cfg.APIOptions = append(cfg.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("AuditLog", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			operation := awsmiddleware.GetOperationName(ctx)
+			if !isMutating(operation) {
+				return next.HandleInitialize(ctx, in)
+			}
+			out, md, err := next.HandleInitialize(ctx, in)
+			params := auditValue(in.Parameters)
+			e := nm.newAuditEntry(strings.ToLower(awsmiddleware.GetServiceID(ctx)), operation, params, err)
+			e.Region = awsmiddleware.GetRegion(ctx)
+			e.AWSIdentity = "unknown"
+			if r, err := identity(); err == nil {
+				e.AWSIdentity = strValue(r.Arn)
+			}
+			e.ResourceIDs = resourceIDs(nil, params)
+			if err == nil {
+				e.ResourceIDs = resourceIDs(e.ResourceIDs, auditValue(out.Result))
+			}
+			nm.audit.write(e)
+			return out, md, err
+		}), middleware.After)
+	})
+}
+
+// auditVSD writes a mutating VSD call to the audit log with its result
+func (nm *NMgr) auditVSD(operation string, params interface{}, id string, err error) {
+	e := nm.newAuditEntry("vsd", operation, auditValue(params), err)
+	if id != "" {
+		e.ResourceIDs = []string{id}
+	}
+	nm.audit.write(e)
+}
+
+// vsdError returns the VSD error as an error
+func vsdError(err *bambou.Error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%s", err.Description)
+}
+
+// newAuditEntry returns an audit log entry of the run with the result of the call
+func (nm *NMgr) newAuditEntry(service, operation string, params interface{}, err error) *AuditEntry {
+	e := &AuditEntry{
+		Time:      time.Now().UTC(),
+		RunID:     nm.runID,
+		Command:   nm.command,
+		Service:   service,
+		Operation: operation,
+		Params:    params,
+		Result:    "ok",
+	}
+	if err != nil {
+		e.Result = "error"
+		e.Error = err.Error()
+	}
+	return e
+}
+
+// auditValue returns the value as generic JSON with the secrets redacted
+func auditValue(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var g interface{}
+	if err := json.Unmarshal(b, &g); err != nil {
+		return nil
+	}
+	return redact(g)
+}
+
+// redact replaces the values of the secret parameters and drops the parameters that
+// are not set
+func redact(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if val == nil {
+				delete(t, k)
+				continue
+			}
+			if isSecretKey(k) {
+				t[k] = redacted
+				continue
+			}
+			t[k] = redact(val)
+		}
+	case []interface{}:
+		for i := range t {
+			t[i] = redact(t[i])
+		}
+	}
+	return v
+}
+
+func isSecretKey(k string) bool {
+	k = strings.ToLower(k)
+	for _, s := range secretKeys {
+		if strings.Contains(k, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceIDs adds the resource ids and ARNs in the value to the ids, account ids are
+// skipped
+func resourceIDs(ids []string, v interface{}) []string {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(t) {
+			if isIDKey(k) {
+				switch id := t[k].(type) {
+				case string:
+					ids = appendID(ids, id)
+				case []interface{}:
+					for _, i := range id {
+						if s, ok := i.(string); ok {
+							ids = appendID(ids, s)
+						}
+					}
+				}
+				continue
+			}
+			ids = resourceIDs(ids, t[k])
+		}
+	case []interface{}:
+		for _, i := range t {
+			ids = resourceIDs(ids, i)
+		}
+	}
+	return ids
+}
+
+func isIDKey(k string) bool {
+	if strings.Contains(k, "Owner") || strings.Contains(k, "Account") {
+		return false
+	}
+	for _, s := range []string{"Id", "Ids", "ID", "Arn", "Arns"} {
+		if strings.HasSuffix(k, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendID(ids []string, id string) []string {
+	if id == "" {
+		return ids
+	}
+	for _, i := range ids {
+		if i == id {
+			return ids
+		}
+	}
+	return append(ids, id)
+}
+
+// AuditFilter selects the entries of the audit log, empty fields match all entries,
+// the operation and resource are glob patterns
+type AuditFilter struct {
+	RunID     string
+	User      string
+	Service   string
+	Operation string
+	Resource  string
+	Since     time.Time
+	Errors    bool
+}
+
+// matches returns if the entry matches the filter
+func (f *AuditFilter) matches(e *AuditEntry) bool {
+	if f.RunID != "" && e.RunID != f.RunID {
+		return false
+	}
+	if f.User != "" && e.User != f.User {
+		return false
+	}
+	if f.Service != "" && e.Service != f.Service {
+		return false
+	}
+	if f.Operation != "" {
+		if ok, _ := path.Match(f.Operation, e.Operation); !ok {
+			return false
+		}
+	}
+	if f.Resource != "" {
+		found := false
+		for _, id := range e.ResourceIDs {
+			if ok, _ := path.Match(f.Resource, id); ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if f.Errors && e.Result != "error" {
+		return false
+	}
+	return true
+}
+
+// ReadAuditLog returns the entries of the audit log that match the filter, in the order
+// they were written
+func ReadAuditLog(file string, f AuditFilter) ([]*AuditEntry, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	var entries []*AuditEntry
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		e := new(AuditEntry)
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			return nil, fmt.Errorf("%s line %d: %s", file, line, err)
+		}
+		if f.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
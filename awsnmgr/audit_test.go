@@ -0,0 +1,131 @@
+package awsnmgr
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuditValue(t *testing.T) {
+	type tunnel struct {
+		TunnelInsideCidr *string
+		PreSharedKey     *string
+	}
+	type params struct {
+		VpnConnectionId *string
+		Password        string
+		Tunnels         []tunnel
+		Options         map[string]interface{}
+	}
+	psk, cidr, id := "secret-psk", "169.254.100.0/30", "vpn-1"
+	v := auditValue(&params{
+		VpnConnectionId: &id,
+		Password:        "admin",
+		Tunnels:         []tunnel{{TunnelInsideCidr: &cidr, PreSharedKey: &psk}, {}},
+		Options:         map[string]interface{}{"ApiToken": "t0k3n", "Unset": nil, "Psk": nil},
+	})
+	want := "map[Options:map[ApiToken:REDACTED] Password:REDACTED " +
+		"Tunnels:[map[PreSharedKey:REDACTED TunnelInsideCidr:169.254.100.0/30] map[]] VpnConnectionId:vpn-1]"
+	if got := fmt.Sprint(v); got != want {
+		t.Errorf("auditValue = %s, want %s", got, want)
+	}
+	if v := auditValue(nil); v != nil {
+		t.Errorf("auditValue(nil) = %v, want nil", v)
+	}
+}
+
+func TestResourceIDs(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		ids  []string
+		v    interface{}
+		want string
+	}{
+		{name: "none", v: map[string]interface{}{"Description": "branch"}, want: "[]"},
+		{
+			name: "ids and arns",
+			v: map[string]interface{}{
+				"TransitGatewayId": "tgw-1",
+				"DeviceArn":        "arn:aws:networkmanager::123456789012:device/global-network-1/device-1",
+				"SubnetIds":        []interface{}{"subnet-1", "subnet-2"},
+			},
+			want: "[arn:aws:networkmanager::123456789012:device/global-network-1/device-1 subnet-1 subnet-2 tgw-1]",
+		},
+		{
+			name: "account ids skipped",
+			v:    map[string]interface{}{"OwnerId": "123456789012", "AccountId": "123456789012", "VpcId": "vpc-1"},
+			want: "[vpc-1]",
+		},
+		{
+			name: "nested and deduplicated",
+			ids:  []string{"tgw-1"},
+			v: map[string]interface{}{
+				"TransitGateway": map[string]interface{}{"TransitGatewayId": "tgw-1", "Options": map[string]interface{}{"AssociationDefaultRouteTableId": "tgw-rtb-1"}},
+				"Tags":           []interface{}{map[string]interface{}{"Key": "Name", "Value": "tgw"}},
+				"LinkIds":        []interface{}{"", "link-1"},
+			},
+			want: "[tgw-1 link-1 tgw-rtb-1]",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fmt.Sprint(resourceIDs(tc.ids, tc.v)); got != tc.want {
+				t.Errorf("resourceIDs = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a := newAuditLog(filepath.Join(dir, "audit.jsonl"))
+	nm := &NMgr{runID: "run-1", audit: a}
+	nm.auditVSD("CreateNSGateway", map[string]string{"Name": "nsg1", "Secret": "s"}, "nsg-1", nil)
+	e := nm.newAuditEntry("ec2", "CreateVpnConnection", nil, fmt.Errorf("throttled"))
+	e.ResourceIDs = []string{"tgw-1"}
+	a.write(e)
+	nm.runID = "run-2"
+	nm.auditVSD("DeleteNSGateway", nil, "nsg-1", nil)
+
+	for _, tc := range []struct {
+		name   string
+		filter AuditFilter
+		want   string
+	}{
+		{name: "all", want: "[CreateNSGateway CreateVpnConnection DeleteNSGateway]"},
+		{name: "run", filter: AuditFilter{RunID: "run-1"}, want: "[CreateNSGateway CreateVpnConnection]"},
+		{name: "service", filter: AuditFilter{Service: "ec2"}, want: "[CreateVpnConnection]"},
+		{name: "operation", filter: AuditFilter{Operation: "Delete*"}, want: "[DeleteNSGateway]"},
+		{name: "resource", filter: AuditFilter{Resource: "tgw-*"}, want: "[CreateVpnConnection]"},
+		{name: "errors", filter: AuditFilter{Errors: true}, want: "[CreateVpnConnection]"},
+		{name: "since", filter: AuditFilter{Since: time.Now().Add(time.Hour)}, want: "[]"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, err := ReadAuditLog(a.file, tc.filter)
+			if err != nil {
+				t.Fatalf("ReadAuditLog: %v", err)
+			}
+			var ops []string
+			for _, e := range entries {
+				ops = append(ops, e.Operation)
+			}
+			if got := fmt.Sprint(ops); got != tc.want {
+				t.Errorf("operations = %s, want %s", got, tc.want)
+			}
+		})
+	}
+
+	entries, err := ReadAuditLog(a.file, AuditFilter{Operation: "CreateNSGateway"})
+	if err != nil {
+		t.Fatalf("ReadAuditLog: %v", err)
+	}
+	if got, want := fmt.Sprint(entries[0].Params), "map[Name:nsg1 Secret:REDACTED]"; got != want {
+		t.Errorf("logged params = %s, want %s", got, want)
+	}
+}
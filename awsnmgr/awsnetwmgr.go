@@ -9,12 +9,17 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-func createNetwTags(tagKey, tagValue *string) (tags []types.Tag) {
+func (nm *NMgr) createNetwTags(tagKey, tagValue *string, extra map[string]string) (tags []types.Tag) {
 	tag := types.Tag{
 		Key:   tagKey,
 		Value: tagValue,
 	}
 	tags = append(tags, tag)
+	tags = append(tags, netwTags(extra)...)
+	if nm.runID != "" {
+		runIDKey := RunIDTagKey
+		tags = append(tags, types.Tag{Key: &runIDKey, Value: &nm.runID})
+	}
 	return tags
 }
 
@@ -22,7 +27,7 @@ func createNetwTags(tagKey, tagValue *string) (tags []types.Tag) {
 func (nm *NMgr) CreateGlobalNetwork(name *string) (*networkmanager.CreateGlobalNetworkOutput, error) {
 	r, err := nm.DescribeGlobalNetworks()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	//if len(r.GlobalNetworks) > 0 {
@@ -31,6 +36,7 @@ func (nm *NMgr) CreateGlobalNetwork(name *string) (*networkmanager.CreateGlobalN
 			if *g.Tags[i].Key == "Name" {
 				if *g.Tags[i].Value == *name {
 					log.Infof("Global Betwork exists")
+					nm.syncNetwTags("global network", *name, g.GlobalNetworkArn, g.Tags, mergeTags(nm.Config.Tags))
 					o := &networkmanager.CreateGlobalNetworkOutput{
 						GlobalNetwork: &r.GlobalNetworks[idx],
 					}
@@ -42,14 +48,22 @@ func (nm *NMgr) CreateGlobalNetwork(name *string) (*networkmanager.CreateGlobalN
 	//}
 
 	tagKey := "Name"
-	tags := createNetwTags(&tagKey, name)
+	tags := nm.createNetwTags(&tagKey, name, mergeTags(nm.Config.Tags))
 
 	input := &networkmanager.CreateGlobalNetworkInput{
 		Description: name,
 		Tags:        tags,
 	}
 
-	return nm.ClientNMgr.CreateGlobalNetwork(nm.ctx, input)
+	o, err := nm.ClientNMgr.CreateGlobalNetwork(nm.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	nm.journal.record("global network", *name, strValue(o.GlobalNetwork.GlobalNetworkId), strValue(o.GlobalNetwork.GlobalNetworkArn), func() error {
+		_, err := nm.DeleteGlobalNetwork()
+		return err
+	})
+	return o, nil
 }
 
 // DeleteGlobalNetwork function
@@ -57,31 +71,30 @@ func (nm *NMgr) DeleteGlobalNetwork() (*networkmanager.DeleteGlobalNetworkOutput
 	input := &networkmanager.DeleteGlobalNetworkInput{
 		GlobalNetworkId: nm.GlobalNetworkID,
 	}
-	return nm.ClientNMgr.DeleteGlobalNetwork(nm.ctx, input)
+	o, err := nm.ClientNMgr.DeleteGlobalNetwork(nm.ctx, input)
+	if err == nil {
+		nm.deleted("global network", nm.Config.Name, strValue(nm.GlobalNetworkID), strValue(o.GlobalNetwork.GlobalNetworkArn))
+	}
+	return o, err
 }
 
 // CreateSite function
 func (nm *NMgr) CreateSite(name *string, s *Site) (*networkmanager.CreateSiteOutput, error) {
-	r, err := nm.GetSites()
+	c, err := nm.siteInventory()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	for idx, g := range r.Sites {
-		for i := 0; i < len(g.Tags); i++ {
-			if *g.Tags[i].Key == "Name" {
-				if *g.Tags[i].Value == *name {
-					log.Infof("Site exists")
-					o := &networkmanager.CreateSiteOutput{
-						Site: &r.Sites[idx],
-					}
-					return o, nil
-				}
-			}
+	if site := c.name(*name); site != nil {
+		log.Infof("Site exists")
+		nm.syncNetwTags("site", *name, site.SiteArn, site.Tags, s.Tags)
+		o := &networkmanager.CreateSiteOutput{
+			Site: site,
 		}
+		return o, nil
 	}
 
 	tagKey := "Name"
-	tags := createNetwTags(&tagKey, name)
+	tags := nm.createNetwTags(&tagKey, name, s.Tags)
 
 	address := geocoder.Address{
 		Street:  s.Street,
@@ -103,7 +116,16 @@ func (nm *NMgr) CreateSite(name *string, s *Site) (*networkmanager.CreateSiteOut
 		Tags:            tags,
 	}
 
-	return nm.ClientNMgr.CreateSite(nm.ctx, input)
+	o, err := nm.ClientNMgr.CreateSite(nm.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	c.add(o.Site)
+	nm.journal.record("site", *name, *o.Site.SiteId, strValue(o.Site.SiteArn), func() error {
+		_, err := nm.DeleteSite(o.Site.SiteId)
+		return err
+	})
+	return o, nil
 
 }
 
@@ -113,16 +135,23 @@ func (nm *NMgr) DeleteSite(s *string) (*networkmanager.DeleteSiteOutput, error)
 		GlobalNetworkId: nm.GlobalNetworkID,
 		SiteId:          s,
 	}
-	return nm.ClientNMgr.DeleteSite(nm.ctx, input)
+	o, err := nm.ClientNMgr.DeleteSite(nm.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	nm.inv.sites.remove(*s)
+	nm.deleted("site", nmNameTag(o.Site.Tags), *s, strValue(o.Site.SiteArn))
+	return o, nil
 }
 
 // DeleteSites function
 func (nm *NMgr) DeleteSites() error {
-	r, err := nm.GetSites()
+	c, err := nm.siteInventory()
 	if err != nil {
 		log.Error(err)
+		return err
 	}
-	for _, s := range r.Sites {
+	for _, s := range c.all() {
 		log.Infof("Delete site: %s", *s.SiteId)
 		_, err := nm.DeleteSite(s.SiteId)
 		if err != nil {
@@ -134,26 +163,21 @@ func (nm *NMgr) DeleteSites() error {
 
 // CreateDevice function
 func (nm *NMgr) CreateDevice(name *string, d *Device) (*networkmanager.CreateDeviceOutput, error) {
-	r, err := nm.GetDevices()
+	c, err := nm.deviceInventory()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	for idx, g := range r.Devices {
-		for i := 0; i < len(g.Tags); i++ {
-			if *g.Tags[i].Key == "Name" {
-				if *g.Tags[i].Value == *name {
-					log.Infof("Site exists")
-					o := &networkmanager.CreateDeviceOutput{
-						Device: &r.Devices[idx],
-					}
-					return o, nil
-				}
-			}
+	if device := c.name(*name); device != nil {
+		log.Infof("Device exists")
+		nm.syncNetwTags("device", *name, device.DeviceArn, device.Tags, d.Tags)
+		o := &networkmanager.CreateDeviceOutput{
+			Device: device,
 		}
+		return o, nil
 	}
 
 	tagKey := "Name"
-	tags := createNetwTags(&tagKey, name)
+	tags := nm.createNetwTags(&tagKey, name, d.Tags)
 
 	address := geocoder.Address{
 		Street:  d.Site.Street,
@@ -184,7 +208,16 @@ func (nm *NMgr) CreateDevice(name *string, d *Device) (*networkmanager.CreateDev
 		SiteId:          d.Site.SiteID,
 	}
 
-	return nm.ClientNMgr.CreateDevice(nm.ctx, input)
+	o, err := nm.ClientNMgr.CreateDevice(nm.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	c.add(o.Device)
+	nm.journal.record("device", *name, *o.Device.DeviceId, strValue(o.Device.DeviceArn), func() error {
+		_, err := nm.DeleteDevice(o.Device.DeviceId)
+		return err
+	})
+	return o, nil
 }
 
 // DeleteDevice function
@@ -193,16 +226,23 @@ func (nm *NMgr) DeleteDevice(d *string) (*networkmanager.DeleteDeviceOutput, err
 		GlobalNetworkId: nm.GlobalNetworkID,
 		DeviceId:        d,
 	}
-	return nm.ClientNMgr.DeleteDevice(nm.ctx, input)
+	o, err := nm.ClientNMgr.DeleteDevice(nm.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	nm.inv.devices.remove(*d)
+	nm.deleted("device", nmNameTag(o.Device.Tags), *d, strValue(o.Device.DeviceArn))
+	return o, nil
 }
 
 // DeleteDevices function
 func (nm *NMgr) DeleteDevices() error {
-	r, err := nm.GetDevices()
+	c, err := nm.deviceInventory()
 	if err != nil {
 		log.Error(err)
+		return err
 	}
-	for _, d := range r.Devices {
+	for _, d := range c.all() {
 		log.Infof("Delete device: %s", *d.DeviceId)
 		_, err := nm.DeleteDevice(d.DeviceId)
 		if err != nil {
@@ -214,28 +254,24 @@ func (nm *NMgr) DeleteDevices() error {
 
 // CreateLink function
 func (nm *NMgr) CreateLink(name *string, ep *Endpoint) (*networkmanager.CreateLinkOutput, error) {
-	r, err := nm.GetLinks()
+	c, err := nm.linkInventory()
 	if err != nil {
-		log.Fatal(err)
-	}
-	//if len(r.Links) > 0 {
-	for idx, g := range r.Links {
-		for i := 0; i < len(g.Tags); i++ {
-			if *g.Tags[i].Key == "Name" {
-				if *g.Tags[i].Value == *name {
-					log.Infof("Site exists")
-					o := &networkmanager.CreateLinkOutput{
-						Link: &r.Links[idx],
-					}
-					return o, nil
-				}
+		return nil, err
+	}
+	// link names are only unique within a site
+	for _, link := range c.named(*name) {
+		if strValue(link.SiteId) == strValue(ep.Site.SiteID) {
+			log.Infof("Link exists")
+			nm.syncNetwTags("link", *name, link.LinkArn, link.Tags, ep.Tags)
+			o := &networkmanager.CreateLinkOutput{
+				Link: link,
 			}
+			return o, nil
 		}
 	}
-	//}
 
 	tagKey := "Name"
-	tags := createNetwTags(&tagKey, name)
+	tags := nm.createNetwTags(&tagKey, name, ep.Tags)
 
 	bw := &types.Bandwidth{
 		DownloadSpeed: &ep.BwDown,
@@ -252,7 +288,16 @@ func (nm *NMgr) CreateLink(name *string, ep *Endpoint) (*networkmanager.CreateLi
 		Tags:            tags,
 	}
 
-	return nm.ClientNMgr.CreateLink(nm.ctx, input)
+	o, err := nm.ClientNMgr.CreateLink(nm.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	c.add(o.Link)
+	nm.journal.record("link", *name, *o.Link.LinkId, strValue(o.Link.LinkArn), func() error {
+		_, err := nm.DeleteLink(o.Link.LinkId)
+		return err
+	})
+	return o, nil
 }
 
 // DeleteLink function
@@ -261,16 +306,23 @@ func (nm *NMgr) DeleteLink(l *string) (*networkmanager.DeleteLinkOutput, error)
 		GlobalNetworkId: nm.GlobalNetworkID,
 		LinkId:          l,
 	}
-	return nm.ClientNMgr.DeleteLink(nm.ctx, input)
+	o, err := nm.ClientNMgr.DeleteLink(nm.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	nm.inv.links.remove(*l)
+	nm.deleted("link", nmNameTag(o.Link.Tags), *l, strValue(o.Link.LinkArn))
+	return o, nil
 }
 
 // DeleteLinks function
 func (nm *NMgr) DeleteLinks() error {
-	r, err := nm.GetLinks()
+	c, err := nm.linkInventory()
 	if err != nil {
 		log.Error(err)
+		return err
 	}
-	for _, l := range r.Links {
+	for _, l := range c.all() {
 		log.Infof("Delete link: %s", *l.LinkId)
 		_, err := nm.DeleteLink(l.LinkId)
 		if err != nil {
@@ -282,50 +334,129 @@ func (nm *NMgr) DeleteLinks() error {
 
 // DescribeGlobalNetworks function
 func (nm *NMgr) DescribeGlobalNetworks() (*networkmanager.DescribeGlobalNetworksOutput, error) {
-	input := &networkmanager.DescribeGlobalNetworksInput{}
-	return nm.ClientNMgr.DescribeGlobalNetworks(nm.ctx, input)
+	o := &networkmanager.DescribeGlobalNetworksOutput{}
+	err := paginate(func(token *string) (*string, error) {
+		input := &networkmanager.DescribeGlobalNetworksInput{
+			NextToken: token,
+		}
+		r, err := nm.ClientNMgr.DescribeGlobalNetworks(nm.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		o.GlobalNetworks = append(o.GlobalNetworks, r.GlobalNetworks...)
+		return r.NextToken, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
 }
 
 // GetSites function
 func (nm *NMgr) GetSites() (*networkmanager.GetSitesOutput, error) {
-	input := &networkmanager.GetSitesInput{
-		GlobalNetworkId: nm.GlobalNetworkID,
+	o := &networkmanager.GetSitesOutput{}
+	err := paginate(func(token *string) (*string, error) {
+		input := &networkmanager.GetSitesInput{
+			GlobalNetworkId: nm.GlobalNetworkID,
+			NextToken:       token,
+		}
+		r, err := nm.ClientNMgr.GetSites(nm.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		o.Sites = append(o.Sites, r.Sites...)
+		return r.NextToken, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nm.ClientNMgr.GetSites(nm.ctx, input)
+	return o, nil
 }
 
 // GetDevices function
 func (nm *NMgr) GetDevices() (*networkmanager.GetDevicesOutput, error) {
-	input := &networkmanager.GetDevicesInput{
-		GlobalNetworkId: nm.GlobalNetworkID,
+	o := &networkmanager.GetDevicesOutput{}
+	err := paginate(func(token *string) (*string, error) {
+		input := &networkmanager.GetDevicesInput{
+			GlobalNetworkId: nm.GlobalNetworkID,
+			NextToken:       token,
+		}
+		r, err := nm.ClientNMgr.GetDevices(nm.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		o.Devices = append(o.Devices, r.Devices...)
+		return r.NextToken, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nm.ClientNMgr.GetDevices(nm.ctx, input)
+	return o, nil
 }
 
 // GetDevice function
 func (nm *NMgr) GetDevice(siteID *string) (*networkmanager.GetDevicesOutput, error) {
-	input := &networkmanager.GetDevicesInput{
-		GlobalNetworkId: nm.GlobalNetworkID,
-		SiteId:          siteID,
+	o := &networkmanager.GetDevicesOutput{}
+	err := paginate(func(token *string) (*string, error) {
+		input := &networkmanager.GetDevicesInput{
+			GlobalNetworkId: nm.GlobalNetworkID,
+			SiteId:          siteID,
+			NextToken:       token,
+		}
+		r, err := nm.ClientNMgr.GetDevices(nm.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		o.Devices = append(o.Devices, r.Devices...)
+		return r.NextToken, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nm.ClientNMgr.GetDevices(nm.ctx, input)
+	return o, nil
 }
 
 // GetLinks function
 func (nm *NMgr) GetLinks() (*networkmanager.GetLinksOutput, error) {
-	input := &networkmanager.GetLinksInput{
-		GlobalNetworkId: nm.GlobalNetworkID,
+	o := &networkmanager.GetLinksOutput{}
+	err := paginate(func(token *string) (*string, error) {
+		input := &networkmanager.GetLinksInput{
+			GlobalNetworkId: nm.GlobalNetworkID,
+			NextToken:       token,
+		}
+		r, err := nm.ClientNMgr.GetLinks(nm.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		o.Links = append(o.Links, r.Links...)
+		return r.NextToken, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nm.ClientNMgr.GetLinks(nm.ctx, input)
+	return o, nil
 }
 
 // GetLink function
 func (nm *NMgr) GetLink(siteID *string) (*networkmanager.GetLinksOutput, error) {
-	input := &networkmanager.GetLinksInput{
-		GlobalNetworkId: nm.GlobalNetworkID,
-		SiteId:          siteID,
+	o := &networkmanager.GetLinksOutput{}
+	err := paginate(func(token *string) (*string, error) {
+		input := &networkmanager.GetLinksInput{
+			GlobalNetworkId: nm.GlobalNetworkID,
+			SiteId:          siteID,
+			NextToken:       token,
+		}
+		r, err := nm.ClientNMgr.GetLinks(nm.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		o.Links = append(o.Links, r.Links...)
+		return r.NextToken, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nm.ClientNMgr.GetLinks(nm.ctx, input)
+	return o, nil
 }
 
 // RegisterTransitGateway function
@@ -348,10 +479,23 @@ func (nm *NMgr) DeregisterTransitGateway(arn *string) (*networkmanager.Deregiste
 
 // GetTransitGatewayRegistrations fucntion
 func (nm *NMgr) GetTransitGatewayRegistrations() (*networkmanager.GetTransitGatewayRegistrationsOutput, error) {
-	input := &networkmanager.GetTransitGatewayRegistrationsInput{
-		GlobalNetworkId:   nm.GlobalNetworkID,
+	o := &networkmanager.GetTransitGatewayRegistrationsOutput{}
+	err := paginate(func(token *string) (*string, error) {
+		input := &networkmanager.GetTransitGatewayRegistrationsInput{
+			GlobalNetworkId: nm.GlobalNetworkID,
+			NextToken:       token,
+		}
+		r, err := nm.ClientNMgr.GetTransitGatewayRegistrations(nm.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		o.TransitGatewayRegistrations = append(o.TransitGatewayRegistrations, r.TransitGatewayRegistrations...)
+		return r.NextToken, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nm.ClientNMgr.GetTransitGatewayRegistrations(nm.ctx, input)
+	return o, nil
 }
 
 // AssociateCustomerGateway function
@@ -396,8 +540,31 @@ func (nm *NMgr) DisassociateLink(dID, lID *string) (*networkmanager.Disassociate
 
 // GetCustomerGatewayAssociations function
 func (nm *NMgr) GetCustomerGatewayAssociations() (*networkmanager.GetCustomerGatewayAssociationsOutput, error) {
-	input := &networkmanager.GetCustomerGatewayAssociationsInput{
-		GlobalNetworkId: nm.GlobalNetworkID,
+	o := &networkmanager.GetCustomerGatewayAssociationsOutput{}
+	err := paginate(func(token *string) (*string, error) {
+		input := &networkmanager.GetCustomerGatewayAssociationsInput{
+			GlobalNetworkId: nm.GlobalNetworkID,
+			NextToken:       token,
+		}
+		r, err := nm.ClientNMgr.GetCustomerGatewayAssociations(nm.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		o.CustomerGatewayAssociations = append(o.CustomerGatewayAssociations, r.CustomerGatewayAssociations...)
+		return r.NextToken, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// TagResourceName function
+func (nm *NMgr) TagResourceName(arn, name *string) (*networkmanager.TagResourceOutput, error) {
+	tagKey := "Name"
+	input := &networkmanager.TagResourceInput{
+		ResourceArn: arn,
+		Tags:        nm.createNetwTags(&tagKey, name, nil),
 	}
-	return nm.ClientNMgr.GetCustomerGatewayAssociations(nm.ctx, input)
+	return nm.ClientNMgr.TagResource(nm.ctx, input)
 }
@@ -2,12 +2,26 @@ package awsnmgr
 
 import (
 	"encoding/xml"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	log "github.com/sirupsen/logrus"
 )
 
+// amazon side ASN of the transit gateways
+var tgwAsn int64 = 64512
+
+// phase 2 lifetime in seconds of the tunnels, the IPsec SA lifetime of the VSD IKE
+// encryption profile
+const phase2Lifetime int32 = 3600
+
 // VpnConnection struct
 type VpnConnection struct {
 	XMLName                 xml.Name `xml:"vpn_connection"`
@@ -74,17 +88,22 @@ type VpnConnection struct {
 	} `xml:"ipsec_tunnel"`
 }
 
-func createEC2Tags(tagKey, tagValue *string) (tags []types.Tag) {
+func (nm *NMgr) createEC2Tags(tagKey, tagValue *string, extra map[string]string) (tags []types.Tag) {
 	tag := types.Tag{
 		Key:   tagKey,
 		Value: tagValue,
 	}
 	tags = append(tags, tag)
+	tags = append(tags, ec2Tags(extra)...)
+	if nm.runID != "" {
+		runIDKey := RunIDTagKey
+		tags = append(tags, types.Tag{Key: &runIDKey, Value: &nm.runID})
+	}
 	return tags
 }
 
-func createEC2TagSpecs(tagKey, tagValue *string, rt types.ResourceType) (tspecs []types.TagSpecification) {
-	t := createEC2Tags(tagKey, tagValue)
+func (nm *NMgr) createEC2TagSpecs(tagKey, tagValue *string, rt types.ResourceType, extra map[string]string) (tspecs []types.TagSpecification) {
+	t := nm.createEC2Tags(tagKey, tagValue, extra)
 
 	tspec := types.TagSpecification{
 		ResourceType: rt,
@@ -107,12 +126,14 @@ func createEC2Filter(tagKey, tagValue *string) (filters []types.Filter) {
 
 }
 
-// CreateTransitGateway fucntion
-func (nm *NMgr) CreateTransitGateway(region, name *string) (*ec2.CreateTransitGatewayOutput, error) {
+// CreateTransitGateway fucntion, when segmented the attachments are associated with the
+// named route tables and the default route table association and propagation of the
+// tgw are disabled, also on an existing tgw
+func (nm *NMgr) CreateTransitGateway(region, name *string, tags map[string]string, segmented bool) (*ec2.CreateTransitGatewayOutput, error) {
 	
 	r, err := nm.DescribeTransitGateways(region, name)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	for i, t := range r.TransitGateways {
@@ -120,6 +141,17 @@ func (nm *NMgr) CreateTransitGateway(region, name *string) (*ec2.CreateTransitGa
 			
 		} else {
 			log.Infof("Transit Gateway exists")
+			nm.syncEC2Tags("transit gateway", *name, *region, t.TransitGatewayId, t.Tags, tags)
+			if segmented && t.Options != nil && (t.Options.DefaultRouteTableAssociation == types.DefaultRouteTableAssociationValueEnable ||
+				t.Options.DefaultRouteTablePropagation == types.DefaultRouteTablePropagationValueEnable) {
+				log.Infof("Disable the default route table association and propagation of Transit Gateway: %s", *t.TransitGatewayId)
+				m, err := nm.DisableTransitGatewayDefaultRouteTable(region, t.TransitGatewayId)
+				if err != nil {
+					return nil, err
+				}
+				nm.updated("transit gateway", *name, *t.TransitGatewayId, strValue(t.TransitGatewayArn))
+				r.TransitGateways[i] = *m.TransitGateway
+			}
 			o := &ec2.CreateTransitGatewayOutput{
 				TransitGateway: &r.TransitGateways[i],
 			}
@@ -129,10 +161,10 @@ func (nm *NMgr) CreateTransitGateway(region, name *string) (*ec2.CreateTransitGa
 
 
 	tagKey := "Name"
-	tspecs := createEC2TagSpecs(&tagKey, name, types.ResourceTypeTransitGateway)
+	tspecs := nm.createEC2TagSpecs(&tagKey, name, types.ResourceTypeTransitGateway, tags)
 
 	o := &types.TransitGatewayRequestOptions{
-		AmazonSideAsn:                64512,
+		AmazonSideAsn:                tgwAsn,
 		AutoAcceptSharedAttachments:  types.AutoAcceptSharedAttachmentsValueDisable,
 		DefaultRouteTableAssociation: types.DefaultRouteTableAssociationValueEnable,
 		DefaultRouteTablePropagation: types.DefaultRouteTablePropagationValueEnable,
@@ -140,6 +172,10 @@ func (nm *NMgr) CreateTransitGateway(region, name *string) (*ec2.CreateTransitGa
 		MulticastSupport:             types.MulticastSupportValueDisable,
 		VpnEcmpSupport:               types.VpnEcmpSupportValueEnable,
 	}
+	if segmented {
+		o.DefaultRouteTableAssociation = types.DefaultRouteTableAssociationValueDisable
+		o.DefaultRouteTablePropagation = types.DefaultRouteTablePropagationValueDisable
+	}
 
 	input := &ec2.CreateTransitGatewayInput{
 		Description:       name,
@@ -147,7 +183,27 @@ func (nm *NMgr) CreateTransitGateway(region, name *string) (*ec2.CreateTransitGa
 		TagSpecifications: tspecs,
 	}
 
-	return nm.ClientEC2[*region].CreateTransitGateway(nm.ctx, input)
+	tgw, err := nm.ClientEC2[*region].CreateTransitGateway(nm.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	nm.journal.record("transit gateway", *name, *tgw.TransitGateway.TransitGatewayId, strValue(tgw.TransitGateway.TransitGatewayArn), func() error {
+		_, err := nm.DeleteTransitGateway(region, tgw.TransitGateway.TransitGatewayId)
+		return err
+	})
+	return tgw, nil
+}
+
+// DisableTransitGatewayDefaultRouteTable function
+func (nm *NMgr) DisableTransitGatewayDefaultRouteTable(region, id *string) (*ec2.ModifyTransitGatewayOutput, error) {
+	input := &ec2.ModifyTransitGatewayInput{
+		TransitGatewayId: id,
+		Options: &types.ModifyTransitGatewayOptions{
+			DefaultRouteTableAssociation: types.DefaultRouteTableAssociationValueDisable,
+			DefaultRouteTablePropagation: types.DefaultRouteTablePropagationValueDisable,
+		},
+	}
+	return nm.ClientEC2[*region].ModifyTransitGateway(nm.ctx, input)
 }
 
 // DescribeTransitGateways function
@@ -155,8 +211,29 @@ func (nm *NMgr) DescribeTransitGateways(region, name *string) (*ec2.DescribeTran
 	tagKey := "tag:Name"
 	filters := createEC2Filter(&tagKey, name)
 
+	o := &ec2.DescribeTransitGatewaysOutput{}
+	err := paginate(func(token *string) (*string, error) {
+		input := &ec2.DescribeTransitGatewaysInput{
+			Filters:   filters,
+			NextToken: token,
+		}
+		r, err := nm.ClientEC2[*region].DescribeTransitGateways(nm.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		o.TransitGateways = append(o.TransitGateways, r.TransitGateways...)
+		return r.NextToken, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// DescribeTransitGateway function
+func (nm *NMgr) DescribeTransitGateway(region, id *string) (*ec2.DescribeTransitGatewaysOutput, error) {
 	input := &ec2.DescribeTransitGatewaysInput{
-		Filters: filters,
+		TransitGatewayIds: []string{*id},
 	}
 	return nm.ClientEC2[*region].DescribeTransitGateways(nm.ctx, input)
 }
@@ -166,18 +243,62 @@ func (nm *NMgr) DeleteTransitGateway(region, id *string) (*ec2.DeleteTransitGate
 	input := &ec2.DeleteTransitGatewayInput{
 		TransitGatewayId: id,
 	}
-	return nm.ClientEC2[*region].DeleteTransitGateway(nm.ctx, input)
+	o, err := nm.ClientEC2[*region].DeleteTransitGateway(nm.ctx, input)
+	if err == nil {
+		nm.deleted("transit gateway", "", strValue(id), "")
+	}
+	return o, err
+}
+
+// awsIdentity returns a function that resolves the caller identity of the credentials
+// of the config once, at the first call
+func (nm *NMgr) awsIdentity(cfg aws.Config) func() (*sts.GetCallerIdentityOutput, error) {
+	var once sync.Once
+	var identity *sts.GetCallerIdentityOutput
+	var err error
+	client := sts.NewFromConfig(cfg)
+	return func() (*sts.GetCallerIdentityOutput, error) {
+		once.Do(func() {
+			identity, err = client.GetCallerIdentity(nm.ctx, &sts.GetCallerIdentityInput{})
+			if err != nil {
+				log.Warnf("Error getting the AWS caller identity: %s", err)
+			}
+		})
+		return identity, err
+	}
+}
+
+// customerGatewayARN returns the ARN of the customer gateway in the partition and account
+// of the caller identity of the EC2 client of the region
+func (nm *NMgr) customerGatewayARN(region, cgwID string) (string, error) {
+	r, err := nm.identityEC2[region]()
+	if err != nil {
+		return "", fmt.Errorf("unable to get the AWS account of region %s: %s", region, err)
+	}
+	caller, err := arn.Parse(strValue(r.Arn))
+	if err != nil {
+		return "", err
+	}
+	a := arn.ARN{
+		Partition: caller.Partition,
+		Service:   "ec2",
+		Region:    region,
+		AccountID: strValue(r.Account),
+		Resource:  "customer-gateway/" + cgwID,
+	}
+	return a.String(), nil
 }
 
 // CreateCustomerGateway fucntion
-func (nm *NMgr) CreateCustomerGateway(region, name, ip *string, asn *int32) (*ec2.CreateCustomerGatewayOutput, error) {
+func (nm *NMgr) CreateCustomerGateway(region, name, ip *string, asn *int32, tags map[string]string) (*ec2.CreateCustomerGatewayOutput, error) {
 	r, err := nm.DescribeCustomerGateways(region, name)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	if len(r.CustomerGateways) > 0 {
 		// TransitGateway exists
 		log.Infof("Customer Gateway exists")
+		nm.syncEC2Tags("customer gateway", *name, *region, r.CustomerGateways[0].CustomerGatewayId, r.CustomerGateways[0].Tags, tags)
 		o := &ec2.CreateCustomerGatewayOutput{
 			CustomerGateway: &r.CustomerGateways[0],
 		}
@@ -185,7 +306,7 @@ func (nm *NMgr) CreateCustomerGateway(region, name, ip *string, asn *int32) (*ec
 	}
 
 	tagKey := "Name"
-	tspecs := createEC2TagSpecs(&tagKey, name, types.ResourceTypeCustomerGateway)
+	tspecs := nm.createEC2TagSpecs(&tagKey, name, types.ResourceTypeCustomerGateway, tags)
 
 	input := &ec2.CreateCustomerGatewayInput{
 		BgpAsn:            *asn,
@@ -194,7 +315,15 @@ func (nm *NMgr) CreateCustomerGateway(region, name, ip *string, asn *int32) (*ec
 		PublicIp:          ip,
 		TagSpecifications: tspecs,
 	}
-	return nm.ClientEC2[*region].CreateCustomerGateway(nm.ctx, input)
+	o, err := nm.ClientEC2[*region].CreateCustomerGateway(nm.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	nm.journal.record("customer gateway", *name, *o.CustomerGateway.CustomerGatewayId, "", func() error {
+		_, err := nm.DeleteCustomerGateway(region, o.CustomerGateway.CustomerGatewayId)
+		return err
+	})
+	return o, nil
 }
 
 // DescribeCustomerGateways function
@@ -208,42 +337,95 @@ func (nm *NMgr) DescribeCustomerGateways(region, name *string) (*ec2.DescribeCus
 	return nm.ClientEC2[*region].DescribeCustomerGateways(nm.ctx, input)
 }
 
+// DescribeCustomerGateway function
+func (nm *NMgr) DescribeCustomerGateway(region, id *string) (*ec2.DescribeCustomerGatewaysOutput, error) {
+	input := &ec2.DescribeCustomerGatewaysInput{
+		CustomerGatewayIds: []string{*id},
+	}
+	return nm.ClientEC2[*region].DescribeCustomerGateways(nm.ctx, input)
+}
+
 // DeleteCustomerGateway function
 func (nm *NMgr) DeleteCustomerGateway(region, id *string) (*ec2.DeleteCustomerGatewayOutput, error) {
 	input := &ec2.DeleteCustomerGatewayInput{
 		CustomerGatewayId: id,
 	}
-	return nm.ClientEC2[*region].DeleteCustomerGateway(nm.ctx, input)
+	o, err := nm.ClientEC2[*region].DeleteCustomerGateway(nm.ctx, input)
+	if err == nil {
+		nm.deleted("customer gateway", "", strValue(id), "")
+	}
+	return o, err
 }
 
 // CreateVpnConnection function
-func (nm *NMgr) CreateVpnConnection(region, name, cgwID, tgwID, cidr *string) (*ec2.CreateVpnConnectionOutput, error) {
+func (nm *NMgr) CreateVpnConnection(cgwID, tgwID *string, ep *Endpoint) (*ec2.CreateVpnConnectionOutput, error) {
+	region := &ep.Region
+	name := &ep.Name
+	ikeVersion := &ep.IKEVersion
+
 	r, err := nm.DescribeVpnConnections(region, name)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	if len(r.VpnConnections) > 0 {
+	for i, v := range r.VpnConnections {
+		if v.State == types.VpnStateDeleted || v.State == types.VpnStateDeleting {
+			continue
+		}
+		if !vpnConnectionMatches(&r.VpnConnections[i], ep) {
+			// the IKE version, acceleration, routing and tunnel inside cidrs of a vpn
+			// connection cannot be changed in place, the vpn connection is replaced
+			log.Infof("VPN connection exists with a different IKE version, acceleration, routing or tunnel inside cidr, replacing it: %s", *v.VpnConnectionId)
+			if _, err := nm.DeleteVpnConnection(region, v.VpnConnectionId); err != nil {
+				return nil, err
+			}
+			nm.journal.replaced("vpn connection", *name, *v.VpnConnectionId)
+			// the tunnel inside cidrs are only released when the vpn connection is deleted
+			if err := nm.waitVpnConnectionDeleted(region, v.VpnConnectionId); err != nil {
+				return nil, err
+			}
+			continue
+		}
 		// VPN connection exists
 		log.Infof("VPN connection exists")
+		nm.syncEC2Tags("vpn connection", *name, *region, v.VpnConnectionId, v.Tags, ep.Tags)
+		modified, err := nm.syncTunnelOptions(&r.VpnConnections[i], ep)
+		if err != nil {
+			return nil, err
+		}
+		if modified {
+			// the configuration of the vpn connection changed with the tunnel options
+			r, err = nm.DescribeVpnConnection(region, v.VpnConnectionId)
+			if err != nil {
+				return nil, err
+			}
+			if len(r.VpnConnections) == 0 {
+				return nil, fmt.Errorf("vpn connection not found: %s", *v.VpnConnectionId)
+			}
+			i = 0
+		}
 		o := &ec2.CreateVpnConnectionOutput{
-			VpnConnection: &r.VpnConnections[0],
+			VpnConnection: &r.VpnConnections[i],
 		}
 		return o, nil
 	}
 
 	tagKey := "Name"
-	tspecs := createEC2TagSpecs(&tagKey, name, types.ResourceTypeVpnConnection)
-
-	tunnelOption := types.VpnTunnelOptionsSpecification{
-		PreSharedKey: &psk,
-	}
+	tspecs := nm.createEC2TagSpecs(&tagKey, name, types.ResourceTypeVpnConnection, ep.Tags)
 
+	// both tunnels use the same psk and IKE version as the VSD IKE gateways
 	var tunnelOptions []types.VpnTunnelOptionsSpecification
-	tunnelOptions = append(tunnelOptions, tunnelOption)
+	for i := 0; i < 2; i++ {
+		t := TunnelConfig{}
+		if i < len(ep.Tunnels) {
+			t = ep.Tunnels[i]
+		}
+		tunnelOptions = append(tunnelOptions, createTunnelOptions(t, ikeVersion))
+	}
 
 	options := &types.VpnConnectionOptionsSpecification{
-		LocalIpv4NetworkCidr: cidr,
-		StaticRoutesOnly:     true,
+		EnableAcceleration:   ep.Accelerated,
+		LocalIpv4NetworkCidr: &ep.Cidr,
+		StaticRoutesOnly:     !ep.BGP,
 		TunnelOptions:        tunnelOptions,
 	}
 
@@ -256,7 +438,190 @@ func (nm *NMgr) CreateVpnConnection(region, name, cgwID, tgwID, cidr *string) (*
 		TagSpecifications: tspecs,
 		Options:           options,
 	}
-	return nm.ClientEC2[*region].CreateVpnConnection(nm.ctx, input)
+	o, err := nm.ClientEC2[*region].CreateVpnConnection(nm.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	nm.journal.record("vpn connection", *name, *o.VpnConnection.VpnConnectionId, "", func() error {
+		_, err := nm.DeleteVpnConnection(region, o.VpnConnection.VpnConnectionId)
+		return err
+	})
+	return o, nil
+}
+
+// createTunnelOptions returns the AWS tunnel options for the tunnel config,
+// options that are not set in the config are left to the AWS defaults
+func createTunnelOptions(t TunnelConfig, ikeVersion *string) types.VpnTunnelOptionsSpecification {
+	awsIKEVersion := "ike" + strings.ToLower(*ikeVersion)
+	o := types.VpnTunnelOptionsSpecification{
+		PreSharedKey: &psk,
+		IKEVersions: []types.IKEVersionsRequestListValue{
+			{Value: &awsIKEVersion},
+		},
+		DPDTimeoutSeconds:      t.DPDTimeout,
+		Phase2LifetimeSeconds:  phase2Lifetime,
+		RekeyMarginTimeSeconds: t.RekeyMarginTime,
+		ReplayWindowSize:       t.ReplayWindowSize,
+	}
+	if t.InsideCidr != "" {
+		o.TunnelInsideCidr = &t.InsideCidr
+	}
+	if t.DPDTimeoutAction != "" {
+		o.DPDTimeoutAction = &t.DPDTimeoutAction
+	}
+	if t.StartupAction != "" {
+		o.StartupAction = &t.StartupAction
+	}
+	return o
+}
+
+// vpnConnectionMatches checks if the acceleration, routing and configured tunnel inside
+// cidrs of the vpn connection match the endpoint and if all tunnels only allow the IKE
+// version of the endpoint
+func vpnConnectionMatches(v *types.VpnConnection, ep *Endpoint) bool {
+	if v.Options == nil {
+		return false
+	}
+	if v.Options.EnableAcceleration != ep.Accelerated || v.Options.StaticRoutesOnly == ep.BGP {
+		return false
+	}
+	if _, ok := matchTunnels(ep.Tunnels, v.Options.TunnelOptions); !ok {
+		return false
+	}
+	awsIKEVersion := "ike" + strings.ToLower(ep.IKEVersion)
+	for _, t := range v.Options.TunnelOptions {
+		if len(t.IkeVersions) != 1 || t.IkeVersions[0].Value == nil || *t.IkeVersions[0].Value != awsIKEVersion {
+			return false
+		}
+	}
+	return true
+}
+
+// syncTunnelOptions modifies the tunnels of the vpn connection whose options differ from
+// the tunnel config of the endpoint, one tunnel at a time as the vpn connection only
+// accepts a modification when it is available. It returns if a tunnel was modified
+func (nm *NMgr) syncTunnelOptions(v *types.VpnConnection, ep *Endpoint) (bool, error) {
+	modified := false
+	tunnels, _ := matchTunnels(ep.Tunnels, v.Options.TunnelOptions)
+	for i, o := range v.Options.TunnelOptions {
+		t := tunnels[i]
+		if tunnelOptionsMatch(t, o) {
+			continue
+		}
+		if modified {
+			if err := nm.waitVpnConnectionAvailable(&ep.Region, v.VpnConnectionId); err != nil {
+				return modified, err
+			}
+		}
+		log.Infof("VPN connection tunnel %s has different options, modifying it: %s", strValue(o.OutsideIpAddress), *v.VpnConnectionId)
+		if _, err := nm.ModifyVpnTunnelOptions(&ep.Region, v.VpnConnectionId, o.OutsideIpAddress, modifyTunnelOptions(t, &ep.IKEVersion)); err != nil {
+			return modified, err
+		}
+		nm.updated("vpn tunnel options", ep.Name, *v.VpnConnectionId, "")
+		modified = true
+	}
+	if modified {
+		return modified, nm.waitVpnConnectionAvailable(&ep.Region, v.VpnConnectionId)
+	}
+	return modified, nil
+}
+
+// matchTunnels returns the tunnel config of every tunnel of the vpn connection, AWS does
+// not keep the tunnels in the order of the config. A config with an inside cidr belongs
+// to the tunnel with that inside cidr, a config without one goes to a remaining tunnel
+// whose options it matches, else to the first remaining tunnel. ok is false when a
+// configured inside cidr is not used by a tunnel of the vpn connection
+func matchTunnels(tunnels []TunnelConfig, options []types.TunnelOption) ([]TunnelConfig, bool) {
+	matched := make([]TunnelConfig, len(options))
+	assigned := make([]bool, len(options))
+	assign := func(t TunnelConfig, match func(types.TunnelOption) bool) bool {
+		for i, o := range options {
+			if !assigned[i] && match(o) {
+				matched[i], assigned[i] = t, true
+				return true
+			}
+		}
+		return false
+	}
+	ok := true
+	var rest []TunnelConfig
+	for _, t := range tunnels {
+		if t.InsideCidr == "" {
+			rest = append(rest, t)
+			continue
+		}
+		if !assign(t, func(o types.TunnelOption) bool { return strValue(o.TunnelInsideCidr) == t.InsideCidr }) {
+			ok = false
+		}
+	}
+	var unmatched []TunnelConfig
+	for _, t := range rest {
+		if !assign(t, func(o types.TunnelOption) bool { return tunnelOptionsMatch(t, o) }) {
+			unmatched = append(unmatched, t)
+		}
+	}
+	for _, t := range unmatched {
+		assign(t, func(types.TunnelOption) bool { return true })
+	}
+	return matched, ok
+}
+
+// strValue returns the value of an optional string
+func strValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// tunnelOptionsMatch checks if the options that are set in the tunnel config and the
+// phase 2 lifetime match the options of the tunnel
+func tunnelOptionsMatch(t TunnelConfig, o types.TunnelOption) bool {
+	if o.Phase2LifetimeSeconds != phase2Lifetime {
+		return false
+	}
+	if t.DPDTimeout != 0 && t.DPDTimeout != o.DpdTimeoutSeconds {
+		return false
+	}
+	if t.DPDTimeoutAction != "" && t.DPDTimeoutAction != strValue(o.DpdTimeoutAction) {
+		return false
+	}
+	if t.RekeyMarginTime != 0 && t.RekeyMarginTime != o.RekeyMarginTimeSeconds {
+		return false
+	}
+	if t.ReplayWindowSize != 0 && t.ReplayWindowSize != o.ReplayWindowSize {
+		return false
+	}
+	if t.StartupAction != "" && t.StartupAction != strValue(o.StartupAction) {
+		return false
+	}
+	return true
+}
+
+// modifyTunnelOptions returns the AWS tunnel modification for the tunnel config, the
+// same options as createTunnelOptions without the inside cidr
+func modifyTunnelOptions(t TunnelConfig, ikeVersion *string) *types.ModifyVpnTunnelOptionsSpecification {
+	c := createTunnelOptions(t, ikeVersion)
+	return &types.ModifyVpnTunnelOptionsSpecification{
+		PreSharedKey:           c.PreSharedKey,
+		IKEVersions:            c.IKEVersions,
+		DPDTimeoutSeconds:      c.DPDTimeoutSeconds,
+		DPDTimeoutAction:       c.DPDTimeoutAction,
+		Phase2LifetimeSeconds:  c.Phase2LifetimeSeconds,
+		RekeyMarginTimeSeconds: c.RekeyMarginTimeSeconds,
+		ReplayWindowSize:       c.ReplayWindowSize,
+		StartupAction:          c.StartupAction,
+	}
+}
+
+// ModifyVpnTunnelOptions function
+func (nm *NMgr) ModifyVpnTunnelOptions(region, id, outsideIP *string, options *types.ModifyVpnTunnelOptionsSpecification) (*ec2.ModifyVpnTunnelOptionsOutput, error) {
+	input := &ec2.ModifyVpnTunnelOptionsInput{
+		VpnConnectionId:           id,
+		VpnTunnelOutsideIpAddress: outsideIP,
+		TunnelOptions:             options,
+	}
+	return nm.ClientEC2[*region].ModifyVpnTunnelOptions(nm.ctx, input)
 }
 
 // DescribeVpnConnections function
@@ -270,10 +635,487 @@ func (nm *NMgr) DescribeVpnConnections(region, name *string) (*ec2.DescribeVpnCo
 	return nm.ClientEC2[*region].DescribeVpnConnections(nm.ctx, input)
 }
 
+// DescribeVpnConnection function
+func (nm *NMgr) DescribeVpnConnection(region, id *string) (*ec2.DescribeVpnConnectionsOutput, error) {
+	input := &ec2.DescribeVpnConnectionsInput{
+		VpnConnectionIds: []string{*id},
+	}
+	return nm.ClientEC2[*region].DescribeVpnConnections(nm.ctx, input)
+}
+
+// DescribeVpnConnectionsByCustomerGateway function
+func (nm *NMgr) DescribeVpnConnectionsByCustomerGateway(region, cgwID *string) (*ec2.DescribeVpnConnectionsOutput, error) {
+	filterKey := "customer-gateway-id"
+	filters := createEC2Filter(&filterKey, cgwID)
+
+	input := &ec2.DescribeVpnConnectionsInput{
+		Filters: filters,
+	}
+	return nm.ClientEC2[*region].DescribeVpnConnections(nm.ctx, input)
+}
+
+// CreateEC2NameTag function
+func (nm *NMgr) CreateEC2NameTag(region, id, name *string) (*ec2.CreateTagsOutput, error) {
+	tagKey := "Name"
+	input := &ec2.CreateTagsInput{
+		Resources: []string{*id},
+		Tags:      nm.createEC2Tags(&tagKey, name, nil),
+	}
+	return nm.ClientEC2[*region].CreateTags(nm.ctx, input)
+}
+
 // DeleteVpnConnection function
 func (nm *NMgr) DeleteVpnConnection(region, id *string) (*ec2.DeleteVpnConnectionOutput, error) {
 	input := &ec2.DeleteVpnConnectionInput{
 		VpnConnectionId: id,
 	}
-	return nm.ClientEC2[*region].DeleteVpnConnection(nm.ctx, input)
+	o, err := nm.ClientEC2[*region].DeleteVpnConnection(nm.ctx, input)
+	if err == nil {
+		nm.deleted("vpn connection", "", strValue(id), "")
+	}
+	return o, err
+}
+
+// CreateTransitGatewayPeeringAttachment function
+func (nm *NMgr) CreateTransitGatewayPeeringAttachment(region, name, tgwID, peerTgwID, peerRegion, peerAccountID *string) (*ec2.CreateTransitGatewayPeeringAttachmentOutput, error) {
+	r, err := nm.DescribeTransitGatewayPeeringAttachments(region, name)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, a := range r.TransitGatewayPeeringAttachments {
+		if a.State == types.TransitGatewayAttachmentStateDeleted || a.State == types.TransitGatewayAttachmentStateDeleting ||
+			a.State == types.TransitGatewayAttachmentStateFailed || a.State == types.TransitGatewayAttachmentStateRejected {
+			continue
+		}
+		log.Infof("Transit Gateway peering attachment exists")
+		o := &ec2.CreateTransitGatewayPeeringAttachmentOutput{
+			TransitGatewayPeeringAttachment: &r.TransitGatewayPeeringAttachments[i],
+		}
+		return o, nil
+	}
+
+	tagKey := "Name"
+	tspecs := nm.createEC2TagSpecs(&tagKey, name, types.ResourceTypeTransitGatewayAttachment, nil)
+
+	input := &ec2.CreateTransitGatewayPeeringAttachmentInput{
+		TransitGatewayId:     tgwID,
+		PeerTransitGatewayId: peerTgwID,
+		PeerRegion:           peerRegion,
+		PeerAccountId:        peerAccountID,
+		TagSpecifications:    tspecs,
+	}
+	o, err := nm.ClientEC2[*region].CreateTransitGatewayPeeringAttachment(nm.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	id := o.TransitGatewayPeeringAttachment.TransitGatewayAttachmentId
+	nm.journal.record("tgw peering attachment", *name, *id, "", func() error {
+		if _, err := nm.DeleteTransitGatewayPeeringAttachment(region, id); err != nil {
+			return err
+		}
+		// the tgw cannot be deleted before the attachment
+		return nm.waitTransitGatewayAttachmentDeleted(region, id)
+	})
+	return o, nil
+}
+
+// DescribeTransitGatewayPeeringAttachments function
+func (nm *NMgr) DescribeTransitGatewayPeeringAttachments(region, name *string) (*ec2.DescribeTransitGatewayPeeringAttachmentsOutput, error) {
+	tagKey := "tag:Name"
+	filters := createEC2Filter(&tagKey, name)
+
+	o := &ec2.DescribeTransitGatewayPeeringAttachmentsOutput{}
+	err := paginate(func(token *string) (*string, error) {
+		input := &ec2.DescribeTransitGatewayPeeringAttachmentsInput{
+			Filters:   filters,
+			NextToken: token,
+		}
+		r, err := nm.ClientEC2[*region].DescribeTransitGatewayPeeringAttachments(nm.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		o.TransitGatewayPeeringAttachments = append(o.TransitGatewayPeeringAttachments, r.TransitGatewayPeeringAttachments...)
+		return r.NextToken, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// DescribeTransitGatewayPeeringAttachment function
+func (nm *NMgr) DescribeTransitGatewayPeeringAttachment(region, id *string) (*ec2.DescribeTransitGatewayPeeringAttachmentsOutput, error) {
+	input := &ec2.DescribeTransitGatewayPeeringAttachmentsInput{
+		TransitGatewayAttachmentIds: []string{*id},
+	}
+	return nm.ClientEC2[*region].DescribeTransitGatewayPeeringAttachments(nm.ctx, input)
+}
+
+// AcceptTransitGatewayPeeringAttachment function
+func (nm *NMgr) AcceptTransitGatewayPeeringAttachment(region, id *string) (*ec2.AcceptTransitGatewayPeeringAttachmentOutput, error) {
+	input := &ec2.AcceptTransitGatewayPeeringAttachmentInput{
+		TransitGatewayAttachmentId: id,
+	}
+	return nm.ClientEC2[*region].AcceptTransitGatewayPeeringAttachment(nm.ctx, input)
+}
+
+// DeleteTransitGatewayPeeringAttachment function
+func (nm *NMgr) DeleteTransitGatewayPeeringAttachment(region, id *string) (*ec2.DeleteTransitGatewayPeeringAttachmentOutput, error) {
+	input := &ec2.DeleteTransitGatewayPeeringAttachmentInput{
+		TransitGatewayAttachmentId: id,
+	}
+	o, err := nm.ClientEC2[*region].DeleteTransitGatewayPeeringAttachment(nm.ctx, input)
+	if err == nil {
+		nm.deleted("tgw peering attachment", "", strValue(id), "")
+	}
+	return o, err
+}
+
+// CreateTransitGatewayStaticRoute function
+func (nm *NMgr) CreateTransitGatewayStaticRoute(region, rtID, cidr, attachmentID *string) (*ec2.CreateTransitGatewayRouteOutput, error) {
+	input := &ec2.CreateTransitGatewayRouteInput{
+		TransitGatewayRouteTableId: rtID,
+		DestinationCidrBlock:       cidr,
+		TransitGatewayAttachmentId: attachmentID,
+	}
+	o, err := nm.ClientEC2[*region].CreateTransitGatewayRoute(nm.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	nm.journal.record("tgw route", *cidr, *rtID, "", func() error {
+		_, err := nm.DeleteTransitGatewayStaticRoute(region, rtID, cidr)
+		return err
+	})
+	return o, nil
+}
+
+// SearchTransitGatewayStaticRoutes function, the search has no next token and returns
+// at most maxRouteSearchResults routes. When more routes are available the search is
+// split into the 2 halves of the searched prefix till every search returns all its routes
+func (nm *NMgr) SearchTransitGatewayStaticRoutes(region, rtID *string) (*ec2.SearchTransitGatewayRoutesOutput, error) {
+	o := &ec2.SearchTransitGatewayRoutesOutput{}
+	seen := make(map[string]bool)
+	if err := nm.searchTransitGatewayStaticRoutes(region, rtID, nil, o, seen); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// maximum number of routes of a tgw route search
+var maxRouteSearchResults int32 = 1000
+
+// searchTransitGatewayStaticRoutes adds the static routes in the prefix to the output,
+// all static routes when the prefix is nil
+func (nm *NMgr) searchTransitGatewayStaticRoutes(region, rtID *string, prefix *net.IPNet, o *ec2.SearchTransitGatewayRoutesOutput, seen map[string]bool) error {
+	search := func(match string, prefix *net.IPNet) (*ec2.SearchTransitGatewayRoutesOutput, error) {
+		filterKey := "type"
+		filterValue := "static"
+		filters := createEC2Filter(&filterKey, &filterValue)
+		if prefix != nil {
+			cidr := prefix.String()
+			filters = append(filters, createEC2Filter(&match, &cidr)...)
+		}
+		input := &ec2.SearchTransitGatewayRoutesInput{
+			TransitGatewayRouteTableId: rtID,
+			Filters:                    filters,
+			MaxResults:                 maxRouteSearchResults,
+		}
+		return nm.ClientEC2[*region].SearchTransitGatewayRoutes(nm.ctx, input)
+	}
+	add := func(routes []types.TransitGatewayRoute) {
+		for _, route := range routes {
+			if !seen[strValue(route.DestinationCidrBlock)] {
+				seen[strValue(route.DestinationCidrBlock)] = true
+				o.Routes = append(o.Routes, route)
+			}
+		}
+	}
+
+	r, err := search("route-search.subnet-of-match", prefix)
+	if err != nil {
+		return err
+	}
+	if !r.AdditionalRoutesAvailable {
+		add(r.Routes)
+		return nil
+	}
+	var halves []*net.IPNet
+	if prefix == nil {
+		_, ipv4, _ := net.ParseCIDR("0.0.0.0/0")
+		_, ipv6, _ := net.ParseCIDR("::/0")
+		halves = []*net.IPNet{ipv4, ipv6}
+	} else {
+		log.Debugf("Transit Gateway route table %s has more than %d static routes in %s, splitting the search", *rtID, maxRouteSearchResults, prefix)
+		// the route of the prefix itself is in neither half
+		r, err := search("route-search.exact-match", prefix)
+		if err != nil {
+			return err
+		}
+		add(r.Routes)
+		halves = splitPrefix(prefix)
+	}
+	for _, h := range halves {
+		if err := nm.searchTransitGatewayStaticRoutes(region, rtID, h, o, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitPrefix returns the 2 halves of the prefix, a host prefix has no halves
+func splitPrefix(prefix *net.IPNet) []*net.IPNet {
+	ones, bits := prefix.Mask.Size()
+	if ones == bits {
+		return nil
+	}
+	mask := net.CIDRMask(ones+1, bits)
+	low := &net.IPNet{IP: prefix.IP.Mask(prefix.Mask), Mask: mask}
+	high := &net.IPNet{IP: make(net.IP, len(low.IP)), Mask: mask}
+	copy(high.IP, low.IP)
+	high.IP[ones/8] |= 0x80 >> uint(ones%8)
+	return []*net.IPNet{low, high}
+}
+
+// DeleteTransitGatewayStaticRoute function
+func (nm *NMgr) DeleteTransitGatewayStaticRoute(region, rtID, cidr *string) (*ec2.DeleteTransitGatewayRouteOutput, error) {
+	input := &ec2.DeleteTransitGatewayRouteInput{
+		TransitGatewayRouteTableId: rtID,
+		DestinationCidrBlock:       cidr,
+	}
+	return nm.ClientEC2[*region].DeleteTransitGatewayRoute(nm.ctx, input)
+}
+
+// CreateTransitGatewayRouteTable function
+func (nm *NMgr) CreateTransitGatewayRouteTable(region, name, tgwID *string) (*ec2.CreateTransitGatewayRouteTableOutput, error) {
+	r, err := nm.DescribeTransitGatewayRouteTables(region, name)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, rt := range r.TransitGatewayRouteTables {
+		if rt.State == types.TransitGatewayRouteTableStateDeleted || rt.State == types.TransitGatewayRouteTableStateDeleting {
+			continue
+		}
+		log.Infof("Transit Gateway route table exists")
+		o := &ec2.CreateTransitGatewayRouteTableOutput{
+			TransitGatewayRouteTable: &r.TransitGatewayRouteTables[i],
+		}
+		return o, nil
+	}
+
+	tagKey := "Name"
+	tspecs := nm.createEC2TagSpecs(&tagKey, name, types.ResourceTypeTransitGatewayRouteTable, nil)
+
+	input := &ec2.CreateTransitGatewayRouteTableInput{
+		TransitGatewayId:  tgwID,
+		TagSpecifications: tspecs,
+	}
+	o, err := nm.ClientEC2[*region].CreateTransitGatewayRouteTable(nm.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	id := o.TransitGatewayRouteTable.TransitGatewayRouteTableId
+	nm.journal.record("tgw route table", *name, *id, "", func() error {
+		return nm.deleteRouteTable(*region, *name, id)
+	})
+	return o, nil
+}
+
+// DescribeTransitGatewayRouteTables function
+func (nm *NMgr) DescribeTransitGatewayRouteTables(region, name *string) (*ec2.DescribeTransitGatewayRouteTablesOutput, error) {
+	tagKey := "tag:Name"
+	filters := createEC2Filter(&tagKey, name)
+
+	o := &ec2.DescribeTransitGatewayRouteTablesOutput{}
+	err := paginate(func(token *string) (*string, error) {
+		input := &ec2.DescribeTransitGatewayRouteTablesInput{
+			Filters:   filters,
+			NextToken: token,
+		}
+		r, err := nm.ClientEC2[*region].DescribeTransitGatewayRouteTables(nm.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		o.TransitGatewayRouteTables = append(o.TransitGatewayRouteTables, r.TransitGatewayRouteTables...)
+		return r.NextToken, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// DescribeTransitGatewayRouteTable function
+func (nm *NMgr) DescribeTransitGatewayRouteTable(region, id *string) (*ec2.DescribeTransitGatewayRouteTablesOutput, error) {
+	input := &ec2.DescribeTransitGatewayRouteTablesInput{
+		TransitGatewayRouteTableIds: []string{*id},
+	}
+	return nm.ClientEC2[*region].DescribeTransitGatewayRouteTables(nm.ctx, input)
+}
+
+// DeleteTransitGatewayRouteTable function
+func (nm *NMgr) DeleteTransitGatewayRouteTable(region, id *string) (*ec2.DeleteTransitGatewayRouteTableOutput, error) {
+	input := &ec2.DeleteTransitGatewayRouteTableInput{
+		TransitGatewayRouteTableId: id,
+	}
+	o, err := nm.ClientEC2[*region].DeleteTransitGatewayRouteTable(nm.ctx, input)
+	if err == nil {
+		nm.deleted("tgw route table", "", strValue(id), "")
+	}
+	return o, err
+}
+
+// DescribeTransitGatewayAttachment function
+func (nm *NMgr) DescribeTransitGatewayAttachment(region, id *string) (*ec2.DescribeTransitGatewayAttachmentsOutput, error) {
+	input := &ec2.DescribeTransitGatewayAttachmentsInput{
+		TransitGatewayAttachmentIds: []string{*id},
+	}
+	return nm.ClientEC2[*region].DescribeTransitGatewayAttachments(nm.ctx, input)
+}
+
+// DescribeTransitGatewayAttachmentsByResource function
+func (nm *NMgr) DescribeTransitGatewayAttachmentsByResource(region, resourceID *string) (*ec2.DescribeTransitGatewayAttachmentsOutput, error) {
+	filterKey := "resource-id"
+	filters := createEC2Filter(&filterKey, resourceID)
+
+	o := &ec2.DescribeTransitGatewayAttachmentsOutput{}
+	err := paginate(func(token *string) (*string, error) {
+		input := &ec2.DescribeTransitGatewayAttachmentsInput{
+			Filters:   filters,
+			NextToken: token,
+		}
+		r, err := nm.ClientEC2[*region].DescribeTransitGatewayAttachments(nm.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		o.TransitGatewayAttachments = append(o.TransitGatewayAttachments, r.TransitGatewayAttachments...)
+		return r.NextToken, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// DescribeTransitGatewayAttachmentsByTgw function, an empty resource type returns all attachments
+func (nm *NMgr) DescribeTransitGatewayAttachmentsByTgw(region, tgwID *string, resourceType string) (*ec2.DescribeTransitGatewayAttachmentsOutput, error) {
+	filterKey := "transit-gateway-id"
+	filters := createEC2Filter(&filterKey, tgwID)
+	if resourceType != "" {
+		filterKey := "resource-type"
+		filters = append(filters, createEC2Filter(&filterKey, &resourceType)...)
+	}
+
+	o := &ec2.DescribeTransitGatewayAttachmentsOutput{}
+	err := paginate(func(token *string) (*string, error) {
+		input := &ec2.DescribeTransitGatewayAttachmentsInput{
+			Filters:   filters,
+			NextToken: token,
+		}
+		r, err := nm.ClientEC2[*region].DescribeTransitGatewayAttachments(nm.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		o.TransitGatewayAttachments = append(o.TransitGatewayAttachments, r.TransitGatewayAttachments...)
+		return r.NextToken, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// AssociateTransitGatewayRouteTable function
+func (nm *NMgr) AssociateTransitGatewayRouteTable(region, rtID, attachmentID *string) (*ec2.AssociateTransitGatewayRouteTableOutput, error) {
+	input := &ec2.AssociateTransitGatewayRouteTableInput{
+		TransitGatewayRouteTableId: rtID,
+		TransitGatewayAttachmentId: attachmentID,
+	}
+	o, err := nm.ClientEC2[*region].AssociateTransitGatewayRouteTable(nm.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	nm.journal.record("tgw route table association", *attachmentID, *rtID, "", func() error {
+		_, err := nm.DisassociateTransitGatewayRouteTable(region, rtID, attachmentID)
+		return err
+	})
+	return o, nil
+}
+
+// DisassociateTransitGatewayRouteTable function
+func (nm *NMgr) DisassociateTransitGatewayRouteTable(region, rtID, attachmentID *string) (*ec2.DisassociateTransitGatewayRouteTableOutput, error) {
+	input := &ec2.DisassociateTransitGatewayRouteTableInput{
+		TransitGatewayRouteTableId: rtID,
+		TransitGatewayAttachmentId: attachmentID,
+	}
+	return nm.ClientEC2[*region].DisassociateTransitGatewayRouteTable(nm.ctx, input)
+}
+
+// GetTransitGatewayRouteTableAssociations function
+func (nm *NMgr) GetTransitGatewayRouteTableAssociations(region, rtID *string) (*ec2.GetTransitGatewayRouteTableAssociationsOutput, error) {
+	o := &ec2.GetTransitGatewayRouteTableAssociationsOutput{}
+	err := paginate(func(token *string) (*string, error) {
+		input := &ec2.GetTransitGatewayRouteTableAssociationsInput{
+			TransitGatewayRouteTableId: rtID,
+			NextToken:                  token,
+		}
+		r, err := nm.ClientEC2[*region].GetTransitGatewayRouteTableAssociations(nm.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		o.Associations = append(o.Associations, r.Associations...)
+		return r.NextToken, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// EnableTransitGatewayRouteTablePropagation function
+func (nm *NMgr) EnableTransitGatewayRouteTablePropagation(region, rtID, attachmentID *string) (*ec2.EnableTransitGatewayRouteTablePropagationOutput, error) {
+	input := &ec2.EnableTransitGatewayRouteTablePropagationInput{
+		TransitGatewayRouteTableId: rtID,
+		TransitGatewayAttachmentId: attachmentID,
+	}
+	o, err := nm.ClientEC2[*region].EnableTransitGatewayRouteTablePropagation(nm.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	nm.journal.record("tgw route table propagation", *attachmentID, *rtID, "", func() error {
+		_, err := nm.DisableTransitGatewayRouteTablePropagation(region, rtID, attachmentID)
+		return err
+	})
+	return o, nil
+}
+
+// DisableTransitGatewayRouteTablePropagation function
+func (nm *NMgr) DisableTransitGatewayRouteTablePropagation(region, rtID, attachmentID *string) (*ec2.DisableTransitGatewayRouteTablePropagationOutput, error) {
+	input := &ec2.DisableTransitGatewayRouteTablePropagationInput{
+		TransitGatewayRouteTableId: rtID,
+		TransitGatewayAttachmentId: attachmentID,
+	}
+	return nm.ClientEC2[*region].DisableTransitGatewayRouteTablePropagation(nm.ctx, input)
+}
+
+// GetTransitGatewayRouteTablePropagations function
+func (nm *NMgr) GetTransitGatewayRouteTablePropagations(region, rtID *string) (*ec2.GetTransitGatewayRouteTablePropagationsOutput, error) {
+	o := &ec2.GetTransitGatewayRouteTablePropagationsOutput{}
+	err := paginate(func(token *string) (*string, error) {
+		input := &ec2.GetTransitGatewayRouteTablePropagationsInput{
+			TransitGatewayRouteTableId: rtID,
+			NextToken:                  token,
+		}
+		r, err := nm.ClientEC2[*region].GetTransitGatewayRouteTablePropagations(nm.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		o.TransitGatewayRouteTablePropagations = append(o.TransitGatewayRouteTablePropagations, r.TransitGatewayRouteTablePropagations...)
+		return r.NextToken, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
 }
@@ -0,0 +1,99 @@
+package awsnmgr
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func TestMatchTunnels(t *testing.T) {
+	tunnel := func(cidr string, dpd int32) types.TunnelOption {
+		return types.TunnelOption{TunnelInsideCidr: aws.String(cidr), DpdTimeoutSeconds: dpd, Phase2LifetimeSeconds: phase2Lifetime}
+	}
+	for _, tc := range []struct {
+		name    string
+		tunnels []TunnelConfig
+		options []types.TunnelOption
+		want    []TunnelConfig
+		ok      bool
+	}{
+		{
+			name:    "by inside cidr",
+			tunnels: []TunnelConfig{{InsideCidr: "169.254.100.0/30", DPDTimeout: 30}, {InsideCidr: "169.254.100.4/30", DPDTimeout: 40}},
+			options: []types.TunnelOption{tunnel("169.254.100.4/30", 40), tunnel("169.254.100.0/30", 30)},
+			want:    []TunnelConfig{{InsideCidr: "169.254.100.4/30", DPDTimeout: 40}, {InsideCidr: "169.254.100.0/30", DPDTimeout: 30}},
+			ok:      true,
+		},
+		{
+			name:    "unknown inside cidr",
+			tunnels: []TunnelConfig{{InsideCidr: "169.254.100.8/30"}},
+			options: []types.TunnelOption{tunnel("169.254.100.4/30", 30), tunnel("169.254.100.0/30", 30)},
+			want:    []TunnelConfig{{}, {}},
+		},
+		{
+			name:    "without inside cidr by options",
+			tunnels: []TunnelConfig{{DPDTimeout: 30}, {DPDTimeout: 40}},
+			options: []types.TunnelOption{tunnel("169.254.100.4/30", 40), tunnel("169.254.100.0/30", 30)},
+			want:    []TunnelConfig{{DPDTimeout: 40}, {DPDTimeout: 30}},
+			ok:      true,
+		},
+		{
+			name:    "without inside cidr in order",
+			tunnels: []TunnelConfig{{DPDTimeout: 30}, {DPDTimeout: 40}},
+			options: []types.TunnelOption{tunnel("169.254.100.4/30", 50), tunnel("169.254.100.0/30", 50)},
+			want:    []TunnelConfig{{DPDTimeout: 30}, {DPDTimeout: 40}},
+			ok:      true,
+		},
+		{
+			name:    "mixed",
+			tunnels: []TunnelConfig{{DPDTimeout: 40}, {InsideCidr: "169.254.100.4/30"}},
+			options: []types.TunnelOption{tunnel("169.254.100.4/30", 30), tunnel("169.254.100.0/30", 30)},
+			want:    []TunnelConfig{{InsideCidr: "169.254.100.4/30"}, {DPDTimeout: 40}},
+			ok:      true,
+		},
+		{
+			name:    "no config",
+			options: []types.TunnelOption{tunnel("169.254.100.4/30", 30), tunnel("169.254.100.0/30", 30)},
+			want:    []TunnelConfig{{}, {}},
+			ok:      true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := matchTunnels(tc.tunnels, tc.options)
+			if ok != tc.ok {
+				t.Errorf("matchTunnels ok = %t, want %t", ok, tc.ok)
+			}
+			if !tc.ok {
+				return
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("matchTunnels = %+v, want %+v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("tunnel %d = %+v, want %+v", i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestVpnConnectionMatchesSwappedTunnels(t *testing.T) {
+	ike := []types.IKEVersionsListValue{{Value: aws.String("ikev2")}}
+	v := &types.VpnConnection{Options: &types.VpnConnectionOptions{
+		StaticRoutesOnly: true,
+		TunnelOptions: []types.TunnelOption{
+			{TunnelInsideCidr: aws.String("169.254.100.4/30"), IkeVersions: ike},
+			{TunnelInsideCidr: aws.String("169.254.100.0/30"), IkeVersions: ike},
+		},
+	}}
+	ep := &Endpoint{IKEVersion: "V2", Tunnels: []TunnelConfig{{InsideCidr: "169.254.100.0/30"}, {InsideCidr: "169.254.100.4/30"}}}
+	if !vpnConnectionMatches(v, ep) {
+		t.Error("vpn connection with the tunnels in another order does not match")
+	}
+	ep.Tunnels[1].InsideCidr = "169.254.100.8/30"
+	if vpnConnectionMatches(v, ep) {
+		t.Error("vpn connection with a changed inside cidr matches")
+	}
+}
@@ -11,7 +11,7 @@ func (nm *NMgr) CreateVpc(region, name, cidr *string) (*ec2.CreateVpcOutput, err
 
 	r, err := nm.DescribeVpcs(region, name)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	if len(r.Vpcs) > 0 {
@@ -23,14 +23,22 @@ func (nm *NMgr) CreateVpc(region, name, cidr *string) (*ec2.CreateVpcOutput, err
 	}
 
 	tagKey := "Name"
-	tspecs := createEC2TagSpecs(&tagKey, name, types.ResourceTypeVpc)
+	tspecs := nm.createEC2TagSpecs(&tagKey, name, types.ResourceTypeVpc, nil)
 
 	input := &ec2.CreateVpcInput{
 		CidrBlock:         cidr,
 		TagSpecifications: tspecs,
 	}
 
-	return nm.ClientEC2[*region].CreateVpc(nm.ctx, input)
+	o, err := nm.ClientEC2[*region].CreateVpc(nm.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	nm.journal.record("vpc", *name, *o.Vpc.VpcId, "", func() error {
+		_, err := nm.DeleteVpc(region, o.Vpc.VpcId)
+		return err
+	})
+	return o, nil
 }
 
 // DescribeVpcs function
@@ -38,8 +46,241 @@ func (nm *NMgr) DescribeVpcs(region, name *string) (*ec2.DescribeVpcsOutput, err
 	tagKey := "tag:Name"
 	filters := createEC2Filter(&tagKey, name)
 
+	o := &ec2.DescribeVpcsOutput{}
+	err := paginate(func(token *string) (*string, error) {
+		input := &ec2.DescribeVpcsInput{
+			Filters:   filters,
+			NextToken: token,
+		}
+		r, err := nm.ClientEC2[*region].DescribeVpcs(nm.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		o.Vpcs = append(o.Vpcs, r.Vpcs...)
+		return r.NextToken, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// DescribeVpc function
+func (nm *NMgr) DescribeVpc(region, id *string) (*ec2.DescribeVpcsOutput, error) {
 	input := &ec2.DescribeVpcsInput{
-		Filters: filters,
+		VpcIds: []string{*id},
 	}
 	return nm.ClientEC2[*region].DescribeVpcs(nm.ctx, input)
 }
+
+// DeleteVpc function
+func (nm *NMgr) DeleteVpc(region, id *string) (*ec2.DeleteVpcOutput, error) {
+	input := &ec2.DeleteVpcInput{
+		VpcId: id,
+	}
+	return nm.ClientEC2[*region].DeleteVpc(nm.ctx, input)
+}
+
+// CreateSubnet function
+func (nm *NMgr) CreateSubnet(region, name, vpcID, az, cidr *string) (*ec2.CreateSubnetOutput, error) {
+	r, err := nm.DescribeSubnets(region, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.Subnets) > 0 {
+		log.Infof("Subnet exists")
+		o := &ec2.CreateSubnetOutput{
+			Subnet: &r.Subnets[0],
+		}
+		return o, nil
+	}
+
+	tagKey := "Name"
+	tspecs := nm.createEC2TagSpecs(&tagKey, name, types.ResourceTypeSubnet, nil)
+
+	input := &ec2.CreateSubnetInput{
+		VpcId:             vpcID,
+		AvailabilityZone:  az,
+		CidrBlock:         cidr,
+		TagSpecifications: tspecs,
+	}
+	o, err := nm.ClientEC2[*region].CreateSubnet(nm.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	nm.journal.record("subnet", *name, *o.Subnet.SubnetId, "", func() error {
+		_, err := nm.DeleteSubnet(region, o.Subnet.SubnetId)
+		return err
+	})
+	return o, nil
+}
+
+// DescribeSubnets function
+func (nm *NMgr) DescribeSubnets(region, name *string) (*ec2.DescribeSubnetsOutput, error) {
+	tagKey := "tag:Name"
+	filters := createEC2Filter(&tagKey, name)
+
+	o := &ec2.DescribeSubnetsOutput{}
+	err := paginate(func(token *string) (*string, error) {
+		input := &ec2.DescribeSubnetsInput{
+			Filters:   filters,
+			NextToken: token,
+		}
+		r, err := nm.ClientEC2[*region].DescribeSubnets(nm.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		o.Subnets = append(o.Subnets, r.Subnets...)
+		return r.NextToken, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// DescribeSubnet function
+func (nm *NMgr) DescribeSubnet(region, id *string) (*ec2.DescribeSubnetsOutput, error) {
+	input := &ec2.DescribeSubnetsInput{
+		SubnetIds: []string{*id},
+	}
+	return nm.ClientEC2[*region].DescribeSubnets(nm.ctx, input)
+}
+
+// DeleteSubnet function
+func (nm *NMgr) DeleteSubnet(region, id *string) (*ec2.DeleteSubnetOutput, error) {
+	input := &ec2.DeleteSubnetInput{
+		SubnetId: id,
+	}
+	return nm.ClientEC2[*region].DeleteSubnet(nm.ctx, input)
+}
+
+// CreateTransitGatewayVpcAttachment function
+func (nm *NMgr) CreateTransitGatewayVpcAttachment(region, name, tgwID, vpcID *string, subnetIDs []string) (*ec2.CreateTransitGatewayVpcAttachmentOutput, error) {
+	r, err := nm.DescribeTransitGatewayVpcAttachments(region, name)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, a := range r.TransitGatewayVpcAttachments {
+		if a.State == types.TransitGatewayAttachmentStateDeleted || a.State == types.TransitGatewayAttachmentStateDeleting {
+			continue
+		}
+		log.Infof("Transit Gateway VPC attachment exists")
+		o := &ec2.CreateTransitGatewayVpcAttachmentOutput{
+			TransitGatewayVpcAttachment: &r.TransitGatewayVpcAttachments[i],
+		}
+		return o, nil
+	}
+
+	tagKey := "Name"
+	tspecs := nm.createEC2TagSpecs(&tagKey, name, types.ResourceTypeTransitGatewayAttachment, nil)
+
+	input := &ec2.CreateTransitGatewayVpcAttachmentInput{
+		TransitGatewayId:  tgwID,
+		VpcId:             vpcID,
+		SubnetIds:         subnetIDs,
+		TagSpecifications: tspecs,
+	}
+	o, err := nm.ClientEC2[*region].CreateTransitGatewayVpcAttachment(nm.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	id := o.TransitGatewayVpcAttachment.TransitGatewayAttachmentId
+	nm.journal.record("tgw vpc attachment", *name, *id, "", func() error {
+		if _, err := nm.DeleteTransitGatewayVpcAttachment(region, id); err != nil {
+			return err
+		}
+		// the subnets and the VPC cannot be deleted before the attachment
+		return nm.waitTransitGatewayAttachmentDeleted(region, id)
+	})
+	return o, nil
+}
+
+// DescribeTransitGatewayVpcAttachments function
+func (nm *NMgr) DescribeTransitGatewayVpcAttachments(region, name *string) (*ec2.DescribeTransitGatewayVpcAttachmentsOutput, error) {
+	tagKey := "tag:Name"
+	filters := createEC2Filter(&tagKey, name)
+
+	o := &ec2.DescribeTransitGatewayVpcAttachmentsOutput{}
+	err := paginate(func(token *string) (*string, error) {
+		input := &ec2.DescribeTransitGatewayVpcAttachmentsInput{
+			Filters:   filters,
+			NextToken: token,
+		}
+		r, err := nm.ClientEC2[*region].DescribeTransitGatewayVpcAttachments(nm.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		o.TransitGatewayVpcAttachments = append(o.TransitGatewayVpcAttachments, r.TransitGatewayVpcAttachments...)
+		return r.NextToken, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// DeleteTransitGatewayVpcAttachment function
+func (nm *NMgr) DeleteTransitGatewayVpcAttachment(region, id *string) (*ec2.DeleteTransitGatewayVpcAttachmentOutput, error) {
+	input := &ec2.DeleteTransitGatewayVpcAttachmentInput{
+		TransitGatewayAttachmentId: id,
+	}
+	o, err := nm.ClientEC2[*region].DeleteTransitGatewayVpcAttachment(nm.ctx, input)
+	if err == nil {
+		nm.deleted("tgw vpc attachment", "", strValue(id), "")
+	}
+	return o, err
+}
+
+// DescribeRouteTables function
+func (nm *NMgr) DescribeRouteTables(region, vpcID *string) (*ec2.DescribeRouteTablesOutput, error) {
+	filterKey := "vpc-id"
+	filters := createEC2Filter(&filterKey, vpcID)
+
+	o := &ec2.DescribeRouteTablesOutput{}
+	err := paginate(func(token *string) (*string, error) {
+		input := &ec2.DescribeRouteTablesInput{
+			Filters:   filters,
+			NextToken: token,
+		}
+		r, err := nm.ClientEC2[*region].DescribeRouteTables(nm.ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		o.RouteTables = append(o.RouteTables, r.RouteTables...)
+		return r.NextToken, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// CreateTransitGatewayRoute creates a route in the VPC route table towards the transit gateway
+func (nm *NMgr) CreateTransitGatewayRoute(region, rtID, cidr, tgwID *string) (*ec2.CreateRouteOutput, error) {
+	input := &ec2.CreateRouteInput{
+		RouteTableId:         rtID,
+		DestinationCidrBlock: cidr,
+		TransitGatewayId:     tgwID,
+	}
+	o, err := nm.ClientEC2[*region].CreateRoute(nm.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	nm.journal.record("vpc route", *cidr, *rtID, "", func() error {
+		_, err := nm.DeleteRoute(region, rtID, cidr)
+		return err
+	})
+	return o, nil
+}
+
+// DeleteRoute function
+func (nm *NMgr) DeleteRoute(region, rtID, cidr *string) (*ec2.DeleteRouteOutput, error) {
+	input := &ec2.DeleteRouteInput{
+		RouteTableId:         rtID,
+		DestinationCidrBlock: cidr,
+	}
+	return nm.ClientEC2[*region].DeleteRoute(nm.ctx, input)
+}
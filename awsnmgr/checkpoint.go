@@ -0,0 +1,140 @@
+package awsnmgr
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// deploy phases of the sites, in the order they run
+const (
+	phaseGlobalNetwork        = "global-network"
+	phaseSites                = "sites"
+	phaseDevicesLinks         = "devices-links"
+	phaseRedundancy           = "nuage-redundancy"
+	phaseRouteTables          = "route-tables"
+	phaseVpcs                 = "vpcs"
+	phasePeerings             = "peerings"
+	phaseVpnConnections       = "vpn-connections"
+	phaseNuageDomains         = "nuage-domains"
+	phaseVpnWait              = "vpn-wait"
+	phaseRouteTableAssocs     = "route-table-associations"
+	phaseCustomerGatewayAssoc = "customer-gateway-associations"
+)
+
+// WithCheckpoint function, the deploy checkpoints each completed phase and the resource
+// ids to the state file, with resume the deploy continues from the phases in the file
+func WithCheckpoint(file string, resume bool) Option {
+	return func(nm *NMgr) {
+		nm.stateFile = file
+		nm.resume = resume
+	}
+}
+
+// loadCheckpoint reads the state file when the deploy resumes, otherwise the deploy
+// starts from an empty state. A deploy of selected branches keeps the ids of the other
+// branches in the state file
+func (nm *NMgr) loadCheckpoint() error {
+	nm.state = NewState()
+	nm.phaseStart = time.Now()
+	if nm.stateFile == "" || (!nm.resume && nm.selectedAll()) {
+		return nil
+	}
+	s, err := LoadState(nm.stateFile)
+	if err != nil {
+		return err
+	}
+	nm.state = s
+	if !nm.resume {
+		s.Phases = nil
+		return nil
+	}
+	log.Infof("Resume deploy, completed phases: %v", s.Phases)
+	return nil
+}
+
+// checkGlobalNetworkCheckpoint drops the completed phases when they belong to another global network
+func (nm *NMgr) checkGlobalNetworkCheckpoint() {
+	id := strValue(nm.GlobalNetworkID)
+	if nm.state.GlobalNetworkID != "" && nm.state.GlobalNetworkID != id {
+		log.Infof("State file belongs to global network %s, starting from the first phase", nm.state.GlobalNetworkID)
+		nm.state = NewState()
+	}
+	nm.state.GlobalNetworkID = id
+}
+
+// phaseDone returns if the phase completed in a previous run of a resumed deploy, it is
+// false for all phases after a phase whose checkpointed ids no longer match
+func (nm *NMgr) phaseDone(phase string) bool {
+	return nm.resume && contains(nm.state.Phases, phase)
+}
+
+// checkpoint records the completed phase, its duration and the resource ids in the state file
+func (nm *NMgr) checkpoint(phase string) {
+	if nm.state.PhaseDurations == nil {
+		nm.state.PhaseDurations = make(map[string]float64)
+	}
+	nm.state.PhaseDurations[phase] = time.Since(nm.phaseStart).Seconds()
+	nm.phaseStart = time.Now()
+	if nm.stateFile == "" {
+		return
+	}
+	if !contains(nm.state.Phases, phase) {
+		nm.state.Phases = append(nm.state.Phases, phase)
+	}
+	for name, s := range nm.Sites {
+		if s.SiteID != nil {
+			nm.state.Sites[name] = *s.SiteID
+		}
+	}
+	for name, d := range nm.Devices {
+		if d.DeviceID == nil {
+			continue
+		}
+		if d.Kind == "tgw" {
+			nm.state.TransitGateways[name] = *d.DeviceID
+			for rtName, rtID := range d.RouteTables {
+				if rtID != nil {
+					nm.state.RouteTables[name+"-"+rtName] = *rtID
+				}
+			}
+			continue
+		}
+		nm.state.Devices[name] = *d.DeviceID
+		for _, ep := range d.Endpoints {
+			if ep.LinkID != nil {
+				nm.state.Links[ep.Name] = *ep.LinkID
+			}
+			if ep.CustomerGatewayID != nil {
+				nm.state.CustomerGateways[ep.Name] = *ep.CustomerGatewayID
+			}
+			if ep.VpnConnectionID != nil {
+				nm.state.VpnConnections[ep.Name] = *ep.VpnConnectionID
+			}
+		}
+	}
+	for name, vpc := range nm.Vpcs {
+		if vpc.VpcID != nil {
+			nm.state.Vpcs[name] = *vpc.VpcID
+		}
+		if vpc.AttachmentID != nil {
+			nm.state.VpcAttachments[name] = *vpc.AttachmentID
+		}
+	}
+	if err := nm.state.Save(nm.stateFile); err != nil {
+		log.Errorf("Error writing checkpoint to %s: %s", nm.stateFile, err)
+		return
+	}
+	log.Debugf("Checkpoint phase %s to %s", phase, nm.stateFile)
+}
+
+// resetCheckpoint drops the completed phases, used when a rollback removed resources
+func (nm *NMgr) resetCheckpoint() {
+	if nm.stateFile == "" || nm.state == nil {
+		return
+	}
+	nm.state = NewState()
+	if err := nm.state.Save(nm.stateFile); err != nil {
+		log.Errorf("Error writing checkpoint to %s: %s", nm.stateFile, err)
+	}
+}
@@ -0,0 +1,172 @@
+package awsnmgr
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// checkpointNMgr returns a topology with a site, an sdwan device with a link and a tgw
+// with a route table, all with ids, that checkpoints to the file
+func checkpointNMgr(file string, resume bool) *NMgr {
+	nm := &NMgr{GlobalNetworkID: aws.String("global-network-1")}
+	WithCheckpoint(file, resume)(nm)
+	site := &Site{Name: "home1", SiteID: aws.String("site-1")}
+	nsg := &Device{Name: "nsg1", Kind: "sdwan", DeviceID: aws.String("device-1"), Endpoints: make(map[string]*Endpoint)}
+	nsg.Endpoints["home1-nsg1-port1"] = &Endpoint{Name: "home1-nsg1-port1", LinkID: aws.String("link-1"), CustomerGatewayID: aws.String("cgw-1")}
+	tgw := &Device{Name: "tgw-euc1", Kind: "tgw", DeviceID: aws.String("tgw-1"), RouteTables: map[string]*string{"corp": aws.String("tgw-rtb-1")}}
+	nm.Sites = map[string]*Site{site.Name: site}
+	nm.Devices = map[string]*Device{nsg.Name: nsg, tgw.Name: tgw}
+	nm.Vpcs = map[string]*Vpc{"workload": {Name: "workload", VpcID: aws.String("vpc-1"), AttachmentID: aws.String("tgw-attach-1")}}
+	return nm
+}
+
+func TestCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "state.yml")
+
+	nm := checkpointNMgr(file, false)
+	if err := nm.loadCheckpoint(); err != nil {
+		t.Fatalf("loadCheckpoint: %v", err)
+	}
+	nm.checkGlobalNetworkCheckpoint()
+	nm.checkpoint(phaseSites)
+	nm.checkpoint(phaseDevicesLinks)
+	nm.checkpoint(phaseDevicesLinks)
+
+	s, err := LoadState(file)
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	for _, tc := range []struct {
+		name string
+		got  interface{}
+		want string
+	}{
+		{"global network", s.GlobalNetworkID, "global-network-1"},
+		{"phases", s.Phases, "[sites devices-links]"},
+		{"sites", s.Sites, "map[home1:site-1]"},
+		{"devices", s.Devices, "map[nsg1:device-1]"},
+		{"links", s.Links, "map[home1-nsg1-port1:link-1]"},
+		{"customer gateways", s.CustomerGateways, "map[home1-nsg1-port1:cgw-1]"},
+		{"transit gateways", s.TransitGateways, "map[tgw-euc1:tgw-1]"},
+		{"route tables", s.RouteTables, "map[tgw-euc1-corp:tgw-rtb-1]"},
+		{"vpcs", s.Vpcs, "map[workload:vpc-1]"},
+		{"vpc attachments", s.VpcAttachments, "map[workload:tgw-attach-1]"},
+	} {
+		if got := fmt.Sprint(tc.got); got != tc.want {
+			t.Errorf("checkpointed %s = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+
+	// a new deploy of the whole topology starts from an empty state
+	nm = checkpointNMgr(file, false)
+	if err := nm.loadCheckpoint(); err != nil {
+		t.Fatalf("loadCheckpoint: %v", err)
+	}
+	if len(nm.state.Sites) != 0 || nm.phaseDone(phaseSites) {
+		t.Errorf("deploy without resume loaded the state: %+v", nm.state)
+	}
+
+	// a deploy of selected branches keeps the ids but not the phases
+	nm = checkpointNMgr(file, false)
+	nm.selection = &selection{}
+	if err := nm.loadCheckpoint(); err != nil {
+		t.Fatalf("loadCheckpoint: %v", err)
+	}
+	if nm.state.Sites["home1"] != "site-1" || len(nm.state.Phases) != 0 {
+		t.Errorf("selected deploy state = %+v, want the ids without phases", nm.state)
+	}
+
+	// a resumed deploy skips the completed phases
+	nm = checkpointNMgr(file, true)
+	if err := nm.loadCheckpoint(); err != nil {
+		t.Fatalf("loadCheckpoint: %v", err)
+	}
+	nm.checkGlobalNetworkCheckpoint()
+	if !nm.phaseDone(phaseSites) || !nm.phaseDone(phaseDevicesLinks) || nm.phaseDone(phaseRouteTables) {
+		t.Errorf("resumed phases = %v, want [sites devices-links]", nm.state.Phases)
+	}
+
+	// the phases of another global network are dropped
+	nm = checkpointNMgr(file, true)
+	nm.GlobalNetworkID = aws.String("global-network-2")
+	if err := nm.loadCheckpoint(); err != nil {
+		t.Fatalf("loadCheckpoint: %v", err)
+	}
+	nm.checkGlobalNetworkCheckpoint()
+	if nm.phaseDone(phaseSites) || len(nm.state.Sites) != 0 {
+		t.Errorf("state of another global network is used: %+v", nm.state)
+	}
+}
+
+func TestResumePhase(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		phases   []string
+		restored bool
+		err      error
+		skip     bool
+		resume   bool
+	}{
+		{name: "not completed", phases: []string{phaseSites}, restored: true, resume: true},
+		{name: "restored", phases: []string{phaseSites, phaseDevicesLinks}, restored: true, skip: true, resume: true},
+		{name: "ids do not match", phases: []string{phaseSites, phaseDevicesLinks}},
+		{name: "restore error", phases: []string{phaseSites, phaseDevicesLinks}, err: fmt.Errorf("throttled"), resume: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			nm := &NMgr{resume: true, state: &State{Phases: tc.phases}}
+			skip, err := nm.resumePhase(phaseDevicesLinks, func() (bool, error) { return tc.restored, tc.err })
+			if err != tc.err {
+				t.Errorf("resumePhase error = %v, want %v", err, tc.err)
+			}
+			if skip != tc.skip {
+				t.Errorf("resumePhase skip = %t, want %t", skip, tc.skip)
+			}
+			if nm.resume != tc.resume {
+				t.Errorf("resume = %t, want %t", nm.resume, tc.resume)
+			}
+			// the phases after a phase whose ids do not match run again
+			if skip := nm.skipPhase(phaseSites); skip != tc.resume {
+				t.Errorf("skipPhase of a completed phase = %t, want %t", skip, tc.resume)
+			}
+		})
+	}
+}
+
+func TestRestoreSites(t *testing.T) {
+	f := &fakeNMgrHTTP{pages: map[string]string{
+		"": `{"Sites":[{"SiteId":"site-1","State":"AVAILABLE"},{"SiteId":"site-2","State":"DELETING"}]}`,
+	}}
+	for _, tc := range []struct {
+		id   string
+		want bool
+	}{
+		{id: "site-1", want: true},
+		{id: "site-2"},
+		{id: "site-3"},
+	} {
+		nm := newFakeNMgr(f)
+		nm.Sites = map[string]*Site{"home1": {Name: "home1"}}
+		nm.state = NewState()
+		nm.state.Sites["home1"] = tc.id
+		ok, err := nm.restoreSites()
+		if err != nil {
+			t.Fatalf("restoreSites: %v", err)
+		}
+		if ok != tc.want {
+			t.Errorf("restoreSites of %s = %t, want %t", tc.id, ok, tc.want)
+		}
+		if ok && strValue(nm.Sites["home1"].SiteID) != tc.id {
+			t.Errorf("restored site id = %s, want %s", strValue(nm.Sites["home1"].SiteID), tc.id)
+		}
+	}
+}
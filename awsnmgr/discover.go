@@ -0,0 +1,229 @@
+package awsnmgr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/henderiw/nuage-wrapper/pkg/vspk"
+	"github.com/nuagenetworks/go-bambou/bambou"
+	log "github.com/sirupsen/logrus"
+)
+
+// DiscoverTopology reads the network ports, vlans, uplink connections and NAT-T public
+// addresses of the NSGs from VSD and returns a topology with the sites, devices and
+// connections towards the tgw, the topology is meant to be edited before it is deployed
+func (nm *NMgr) DiscoverTopology(enterpriseName string, nsgNames []string, tgw, region string) (*Config, error) {
+	enterprise, err := nm.getEnterprise(enterpriseName)
+	if err != nil {
+		return nil, err
+	}
+	if enterprise == nil {
+		return nil, fmt.Errorf("Nuage enterprise does not exist: %s", enterpriseName)
+	}
+
+	cfg := &Config{
+		Name:  nm.Config.Name,
+		Nuage: Nuage{Enterprise: enterpriseName, URL: nm.Config.Nuage.URL},
+		Aws:   nm.Config.Aws,
+		Topology: Topology{
+			Sites: make(map[string]SiteConfig),
+			DeviceKinds: map[string]DeviceConfig{
+				"sdwan": {Vendor: "nuage"},
+			},
+			Devices: map[string]DeviceConfig{
+				tgw: {Kind: "tgw", Region: region},
+			},
+		},
+	}
+
+	for _, nsgName := range nsgNames {
+		log.Infof("Discover Nuage NSG: %s", nsgName)
+		nsg, err := nm.findNsg(nsgName, enterprise)
+		if err != nil {
+			return nil, err
+		}
+
+		siteName := nsgName
+		cfg.Topology.Sites[siteName] = nm.discoverSite(nsg)
+		cfg.Topology.Devices[nsgName] = DeviceConfig{
+			Kind:   "sdwan",
+			Model:  nsg.Family,
+			Serial: nsg.SerialNumber,
+		}
+
+		publicIPs := nm.discoverPublicIPs(nsg)
+
+		var ports vspk.NSPortsList
+		bErr := nm.retryVSD("nsports", func() *bambou.Error {
+			var bErr *bambou.Error
+			ports, bErr = nsg.NSPorts(&bambou.FetchingInfo{})
+			return bErr
+		})
+		if bErr != nil {
+			return nil, fmt.Errorf("unable to read ports of NSG %s: %s", nsgName, bErr.Description)
+		}
+		for _, port := range ports {
+			if port.PortType != "NETWORK" {
+				continue
+			}
+			var vlans vspk.VLANsList
+			bErr := nm.retryVSD("vlans", func() *bambou.Error {
+				var bErr *bambou.Error
+				vlans, bErr = port.VLANs(&bambou.FetchingInfo{})
+				return bErr
+			})
+			if bErr != nil {
+				return nil, fmt.Errorf("unable to read vlans of NSG %s port %s: %s", nsgName, port.Name, bErr.Description)
+			}
+			for _, vlan := range vlans {
+				bwUp := nm.discoverUploadRate(vlan)
+				var uplinks vspk.UplinkConnectionsList
+				bErr := nm.retryVSD("uplinkconnections", func() *bambou.Error {
+					var bErr *bambou.Error
+					uplinks, bErr = vlan.UplinkConnections(&bambou.FetchingInfo{})
+					return bErr
+				})
+				if bErr != nil {
+					return nil, fmt.Errorf("unable to read uplink connections of NSG %s port %s vlan %d: %s", nsgName, port.Name, vlan.Value, bErr.Description)
+				}
+				for j, uplink := range uplinks {
+					log.Debugf("Uplink connection: %s %s %d %s %s", nsgName, port.Name, vlan.Value, uplink.Mode, uplink.Role)
+					// the endpoint name has the NSG, port and vlan so the names of the
+					// customer gateways and vpn connections of the NSGs are unique
+					epName := nsgName + "-" + port.Name
+					if vlan.Value != 0 {
+						epName += "-" + strconv.Itoa(vlan.Value)
+					}
+					if j > 0 {
+						epName += "-" + strconv.Itoa(j)
+					}
+					labels := map[string]string{"port": port.Name}
+					if uplink.AssociatedUnderlayName != "" {
+						labels["provider"] = uplink.AssociatedUnderlayName
+					}
+					labels["kind"] = "broadband"
+					if uplink.Mode == "LTE" {
+						labels["kind"] = "lte"
+					}
+					if vlan.Value != 0 {
+						labels["vlan"] = strconv.Itoa(vlan.Value)
+					}
+					if uplink.DownloadRateLimit > 0 {
+						labels["bwdown"] = strconv.Itoa(int(math.Ceil(uplink.DownloadRateLimit)))
+					}
+					if bwUp > 0 {
+						labels["bwup"] = strconv.Itoa(bwUp)
+					}
+					publicIP := publicIPs[strconv.Itoa(uplink.UplinkID)]
+					if publicIP == "" {
+						publicIP = publicIPs[uplink.Address]
+					}
+					if publicIP == "" {
+						log.Infof("No NAT-T public address found for NSG %s port %s vlan %d", nsgName, port.Name, vlan.Value)
+					} else {
+						labels["public-ip"] = publicIP
+					}
+					cfg.Topology.Connections = append(cfg.Topology.Connections, ConnectionConfig{
+						Endpoints: []string{siteName + ":" + nsgName + ":" + epName, tgw},
+						Labels:    labels,
+					})
+				}
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// findNsg returns the NSG with the exact name in the enterprise
+func (nm *NMgr) findNsg(name string, enterprise *vspk.Enterprise) (*vspk.NSGateway, error) {
+	var nsgs vspk.NSGatewaysList
+	bErr := nm.retryVSD("nsgateways", func() *bambou.Error {
+		var bErr *bambou.Error
+		nsgs, bErr = enterprise.NSGateways(&bambou.FetchingInfo{Filter: name})
+		return bErr
+	})
+	if bErr != nil {
+		return nil, fmt.Errorf("unable to read NSG %s: %s", name, bErr.Description)
+	}
+	for _, nsg := range nsgs {
+		if nsg.Name == name {
+			return nsg, nil
+		}
+	}
+	return nil, fmt.Errorf("Nuage NSG device does not exist: %s", name)
+}
+
+// discoverSite returns the site address from the location of the NSG
+func (nm *NMgr) discoverSite(nsg *vspk.NSGateway) SiteConfig {
+	site := SiteConfig{}
+	var locations vspk.LocationsList
+	bErr := nm.retryVSD("locations", func() *bambou.Error {
+		var bErr *bambou.Error
+		locations, bErr = nsg.Locations(&bambou.FetchingInfo{})
+		return bErr
+	})
+	if bErr != nil {
+		log.Errorf("Unable to read location of NSG %s: %s", nsg.Name, bErr.Description)
+		return site
+	}
+	if len(locations) > 0 {
+		site.Street = locations[0].Address
+		site.City = locations[0].Locality
+		site.State = locations[0].State
+		site.Country = locations[0].Country
+	}
+	return site
+}
+
+// discoverUploadRate returns the upload bandwidth in Mbps of the vlan, the peak rate of
+// the rate limiter of its egress QoS policy, 0 when the vlan has no rate limit
+func (nm *NMgr) discoverUploadRate(vlan *vspk.VLAN) int {
+	if vlan.AssociatedEgressQOSPolicyID == "" {
+		return 0
+	}
+	policy := &vspk.EgressQOSPolicy{ID: vlan.AssociatedEgressQOSPolicyID}
+	if bErr := nm.retryVSD("egressqospolicies", policy.Fetch); bErr != nil {
+		log.Errorf("Unable to read egress QoS policy of vlan %d: %s", vlan.Value, bErr.Description)
+		return 0
+	}
+	if policy.ParentQueueAssociatedRateLimiterID == "" {
+		return 0
+	}
+	limiter := &vspk.RateLimiter{ID: policy.ParentQueueAssociatedRateLimiterID}
+	if bErr := nm.retryVSD("ratelimiters", limiter.Fetch); bErr != nil {
+		log.Errorf("Unable to read rate limiter of vlan %d: %s", vlan.Value, bErr.Description)
+		return 0
+	}
+	rate, err := strconv.ParseFloat(limiter.PeakInformationRate, 64)
+	if err != nil || rate <= 0 {
+		return 0
+	}
+	return int(math.Ceil(rate))
+}
+
+// discoverPublicIPs returns the NAT-T public addresses the NSG reports for its uplinks,
+// indexed by datapath uplink id and by private address
+func (nm *NMgr) discoverPublicIPs(nsg *vspk.NSGateway) map[string]string {
+	publicIPs := make(map[string]string)
+	var list vspk.SysmonUplinkConnectionsList
+	bErr := nm.retryVSD("sysmonuplinkconnections", func() *bambou.Error {
+		return bambou.CurrentSession().FetchChildren(nsg, vspk.SysmonUplinkConnectionIdentity, &list, &bambou.FetchingInfo{})
+	})
+	if bErr != nil {
+		log.Errorf("Unable to read uplink connections status of NSG %s: %s", nsg.Name, bErr.Description)
+		return publicIPs
+	}
+	for _, c := range list {
+		if c.PublicIP == "" {
+			continue
+		}
+		if c.DatapathUplinkId != "" {
+			publicIPs[c.DatapathUplinkId] = c.PublicIP
+		}
+		if c.PrivateIP != "" {
+			publicIPs[c.PrivateIP] = c.PublicIP
+		}
+	}
+	return publicIPs
+}
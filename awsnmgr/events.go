@@ -0,0 +1,364 @@
+package awsnmgr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// EventSchemaVersion is the version of the JSON schema of the events
+const EventSchemaVersion = "v1"
+
+// Event types that are posted to the webhooks
+const (
+	EventDeployStarted      = "deploy.started"
+	EventDeployFinished     = "deploy.finished"
+	EventResourceCreated    = "resource.created"
+	EventResourceDeleted    = "resource.deleted"
+	EventDriftDetected      = "drift.detected"
+	EventTunnelStateChanged = "tunnel.state-changed"
+	EventWebhookTest        = "webhook.test"
+)
+
+const (
+	defaultWebhookTimeout = 10 * time.Second
+	allEvents             = "*"
+	// posts that wait for the webhooks, further events are dropped
+	eventQueueSize = 1024
+	// time the command waits at exit for the queued posts
+	eventFlushTimeout = 30 * time.Second
+)
+
+// WebhookConfig is a webhook of the topology that receives the events, when events is
+// empty all events are posted
+type WebhookConfig struct {
+	URL     string            `json:"url"`
+	Events  []string          `json:"events,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Timeout string            `json:"timeout,omitempty"`
+}
+
+// Event is the JSON payload that is posted to the webhooks, only the section of the
+// event type is set
+type Event struct {
+	Version  string          `json:"version"`
+	Type     string          `json:"type"`
+	Time     time.Time       `json:"time"`
+	RunID    string          `json:"runId,omitempty"`
+	Command  string          `json:"command,omitempty"`
+	Network  string          `json:"network,omitempty"`
+	Deploy   *DeployEvent    `json:"deploy,omitempty"`
+	Resource *ResourceResult `json:"resource,omitempty"`
+	Tunnel   *TunnelEvent    `json:"tunnel,omitempty"`
+}
+
+// DeployEvent is the start or end of a deploy
+type DeployEvent struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Created int    `json:"created"`
+	Updated int    `json:"updated"`
+}
+
+// TunnelEvent is a change of the state of a tunnel between two status polls
+type TunnelEvent struct {
+	Connection      string `json:"connection"`
+	Region          string `json:"region"`
+	VpnConnectionID string `json:"vpnConnectionId"`
+	Tunnel          int    `json:"tunnel"`
+	OutsideIP       string `json:"outsideIp"`
+	PreviousStatus  string `json:"previousStatus"`
+	Status          string `json:"status"`
+	TunnelsUp       int    `json:"tunnelsUp"`
+}
+
+// matches returns if the webhook receives the event type
+func (w *WebhookConfig) matches(eventType string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == eventType || e == allEvents {
+			return true
+		}
+	}
+	return false
+}
+
+// validateWebhooks checks the webhooks of the topology
+func (nm *NMgr) validateWebhooks() error {
+	for i, w := range nm.Config.Webhooks {
+		if w.URL == "" {
+			return fmt.Errorf("webhook %d: url is not set", i)
+		}
+		if w.Timeout != "" {
+			if _, err := time.ParseDuration(w.Timeout); err != nil {
+				return fmt.Errorf("webhook %s: invalid timeout %s: %s", w.URL, w.Timeout, err)
+			}
+		}
+		for _, e := range w.Events {
+			switch e {
+			case EventDeployStarted, EventDeployFinished, EventResourceCreated, EventResourceDeleted,
+				EventDriftDetected, EventTunnelStateChanged, EventWebhookTest, allEvents:
+			default:
+				return fmt.Errorf("webhook %s: unknown event type %s", w.URL, e)
+			}
+		}
+	}
+	return nil
+}
+
+// newEvent returns an event of the type for the run
+func (nm *NMgr) newEvent(eventType string) *Event {
+	return &Event{
+		Version: EventSchemaVersion,
+		Type:    eventType,
+		Time:    time.Now().UTC(),
+		RunID:   nm.runID,
+		Command: nm.command,
+		Network: nm.Config.Name,
+	}
+}
+
+// webhookPost is an event that waits to be posted to a webhook
+type webhookPost struct {
+	webhook WebhookConfig
+	event   *Event
+}
+
+// eventQueue posts the events to the webhooks in the background in the order they are
+// queued, so a slow webhook does not hold up the command
+type eventQueue struct {
+	mu     sync.Mutex
+	posts  chan webhookPost
+	done   chan struct{}
+	closed bool
+}
+
+func newEventQueue() *eventQueue {
+	q := &eventQueue{
+		posts: make(chan webhookPost, eventQueueSize),
+		done:  make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// run posts the queued events till the queue is closed, a failed post is logged as a
+// warning and does not fail the command
+func (q *eventQueue) run() {
+	defer close(q.done)
+	for p := range q.posts {
+		if err := postEvent(p.webhook, p.event); err != nil {
+			log.WithField("webhook", p.webhook.URL).Warnf("Error posting %s event to webhook %s: %s", p.event.Type, p.webhook.URL, err)
+		}
+	}
+}
+
+// add queues the post, it is dropped when the queue is full or closed
+func (q *eventQueue) add(p webhookPost) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		log.WithField("webhook", p.webhook.URL).Warnf("Dropping %s event to webhook %s, the events are flushed", p.event.Type, p.webhook.URL)
+		return
+	}
+	select {
+	case q.posts <- p:
+	default:
+		log.WithField("webhook", p.webhook.URL).Warnf("Dropping %s event to webhook %s, %d events are queued", p.event.Type, p.webhook.URL, eventQueueSize)
+	}
+}
+
+// flush closes the queue and waits till the queued events are posted or the timeout
+// expires, it returns the number of events that are not posted
+func (q *eventQueue) flush(timeout time.Duration) int {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.posts)
+	}
+	q.mu.Unlock()
+	select {
+	case <-q.done:
+		return 0
+	case <-time.After(timeout):
+		return len(q.posts)
+	}
+}
+
+// notify queues the event for the webhooks that receive its type
+func (nm *NMgr) notify(e *Event) {
+	for _, w := range nm.Config.Webhooks {
+		if !w.matches(e.Type) {
+			continue
+		}
+		nm.eventsOnce.Do(func() { nm.events = newEventQueue() })
+		if nm.events == nil {
+			// the events are flushed before any was queued
+			log.WithField("webhook", w.URL).Warnf("Dropping %s event to webhook %s, the events are flushed", e.Type, w.URL)
+			continue
+		}
+		nm.events.add(webhookPost{webhook: w, event: e})
+	}
+}
+
+// FlushEvents waits till the queued events are posted to the webhooks, at most
+// eventFlushTimeout, the command calls it before it exits
+func (nm *NMgr) FlushEvents() {
+	nm.eventsOnce.Do(func() {})
+	if nm.events == nil {
+		return
+	}
+	if n := nm.events.flush(eventFlushTimeout); n > 0 {
+		log.Warnf("%d events are not posted to the webhooks within %s", n, eventFlushTimeout)
+	}
+}
+
+// postEvent posts the event to the webhook, the webhook has to answer with a 2xx status
+func postEvent(w WebhookConfig, e *Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	timeout := defaultWebhookTimeout
+	if w.Timeout != "" {
+		if timeout, err = time.ParseDuration(w.Timeout); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Nuage-Aws-Event", e.Type)
+	if e.RunID != "" {
+		req.Header.Set("X-Nuage-Aws-Run-Id", e.RunID)
+	}
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// NotifyDeployStarted posts the deploy.started event, a fatal log after the start posts
+// a failed deploy.finished event with the fatal message before the process exits
+func (nm *NMgr) NotifyDeployStarted() {
+	e := nm.newEvent(EventDeployStarted)
+	e.Deploy = &DeployEvent{Status: "started"}
+	nm.notify(e)
+	log.RegisterExitHandler(func() {
+		if !nm.deployFinished {
+			nm.NotifyDeployFinished(fmt.Errorf("%s", nm.lastError()))
+		}
+		nm.FlushEvents()
+	})
+}
+
+// lastError returns the last logged error of the run
+func (nm *NMgr) lastError() string {
+	nm.result.mu.Lock()
+	defer nm.result.mu.Unlock()
+	if len(nm.result.Errors) == 0 {
+		return "deploy exited"
+	}
+	return nm.result.Errors[len(nm.result.Errors)-1]
+}
+
+// NotifyDeployFinished posts the deploy.finished event with the error of a failed deploy
+func (nm *NMgr) NotifyDeployFinished(err error) {
+	e := nm.newEvent(EventDeployFinished)
+	e.Deploy = &DeployEvent{Status: "succeeded"}
+	if err != nil {
+		e.Deploy.Status = "failed"
+		e.Deploy.Error = err.Error()
+	}
+	nm.result.mu.Lock()
+	e.Deploy.Updated = len(nm.result.Updated)
+	e.Deploy.Created = len(nm.result.Created) - len(nm.journal.undone)
+	nm.result.mu.Unlock()
+	nm.deployFinished = true
+	nm.notify(e)
+}
+
+// TestWebhooks posts the webhook.test event to the webhooks of the topology file, it
+// does not connect to AWS or the VSD so the webhooks can be checked against a local receiver
+func TestWebhooks(file, runID, command string) error {
+	nm := &NMgr{Config: new(Config), runID: runID, command: command}
+	if err := nm.GetTopology(file); err != nil {
+		return err
+	}
+	if len(nm.Config.Webhooks) == 0 {
+		return fmt.Errorf("no webhooks in %s", file)
+	}
+	if err := nm.validateWebhooks(); err != nil {
+		return err
+	}
+	e := nm.newEvent(EventWebhookTest)
+	for _, w := range nm.Config.Webhooks {
+		if err := postEvent(w, e); err != nil {
+			return fmt.Errorf("webhook %s: %s", w.URL, err)
+		}
+		log.Infof("Posted %s event to webhook %s", e.Type, w.URL)
+	}
+	return nil
+}
+
+// notifyResource posts a resource.created, resource.deleted or drift.detected event
+func (nm *NMgr) notifyResource(eventType, kind, name, id, arn string) {
+	e := nm.newEvent(eventType)
+	e.Resource = &ResourceResult{Kind: kind, Name: name, ID: id, ARN: arn}
+	nm.notify(e)
+}
+
+// notifyTunnelTransitions posts a tunnel.state-changed event for the tunnels whose status
+// changed since the previous poll, the first poll only records the status
+func (nm *NMgr) notifyTunnelTransitions(status []*ConnectionStatus) {
+	if nm.tunnelStatus == nil {
+		nm.tunnelStatus = make(map[string]string)
+	}
+	for _, cs := range status {
+		up := 0
+		for _, t := range cs.Tunnels {
+			if t.Status == "UP" {
+				up++
+			}
+		}
+		for i, t := range cs.Tunnels {
+			key := fmt.Sprintf("%s/%d", cs.VpnConnectionID, i)
+			prev, ok := nm.tunnelStatus[key]
+			nm.tunnelStatus[key] = t.Status
+			if !ok || prev == t.Status {
+				continue
+			}
+			log.WithFields(log.Fields{"region": cs.Region, "aws_id": cs.VpnConnectionID}).Infof("Tunnel %d of %s changed from %s to %s", i, cs.Name, prev, t.Status)
+			e := nm.newEvent(EventTunnelStateChanged)
+			e.Tunnel = &TunnelEvent{
+				Connection:      cs.Name,
+				Region:          cs.Region,
+				VpnConnectionID: cs.VpnConnectionID,
+				Tunnel:          i,
+				OutsideIP:       t.OutsideIP,
+				PreviousStatus:  prev,
+				Status:          t.Status,
+				TunnelsUp:       up,
+			}
+			nm.notify(e)
+		}
+	}
+}
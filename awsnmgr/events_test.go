@@ -0,0 +1,154 @@
+package awsnmgr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventReceiver is a webhook that records the events it receives
+type eventReceiver struct {
+	mu      sync.Mutex
+	events  []*Event
+	headers []http.Header
+	status  int
+	delay   time.Duration
+}
+
+func (r *eventReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	time.Sleep(r.delay)
+	e := &Event{}
+	if err := json.NewDecoder(req.Body).Decode(e); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.mu.Lock()
+	r.events = append(r.events, e)
+	r.headers = append(r.headers, req.Header.Clone())
+	status := r.status
+	r.mu.Unlock()
+	if status != 0 {
+		w.WriteHeader(status)
+	}
+}
+
+func TestPostEvent(t *testing.T) {
+	rcv := &eventReceiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	w := WebhookConfig{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer token"}}
+	e := &Event{Version: EventSchemaVersion, Type: EventDeployStarted, RunID: "run-1", Deploy: &DeployEvent{Status: "started"}}
+	if err := postEvent(w, e); err != nil {
+		t.Fatalf("postEvent: %v", err)
+	}
+	if len(rcv.events) != 1 {
+		t.Fatalf("got %d events, want 1", len(rcv.events))
+	}
+	got, h := rcv.events[0], rcv.headers[0]
+	if got.Type != EventDeployStarted || got.RunID != "run-1" || got.Deploy == nil || got.Deploy.Status != "started" {
+		t.Errorf("unexpected event: %+v", got)
+	}
+	for k, want := range map[string]string{
+		"Content-Type":       "application/json",
+		"X-Nuage-Aws-Event":  EventDeployStarted,
+		"X-Nuage-Aws-Run-Id": "run-1",
+		"Authorization":      "Bearer token",
+	} {
+		if h.Get(k) != want {
+			t.Errorf("header %s = %q, want %q", k, h.Get(k), want)
+		}
+	}
+}
+
+func TestPostEventErrors(t *testing.T) {
+	rcv := &eventReceiver{status: http.StatusInternalServerError}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+	e := &Event{Version: EventSchemaVersion, Type: EventWebhookTest}
+	if err := postEvent(WebhookConfig{URL: srv.URL}, e); err == nil {
+		t.Error("postEvent succeeded on a 500 answer")
+	}
+
+	slow := &eventReceiver{delay: 200 * time.Millisecond}
+	slowSrv := httptest.NewServer(slow)
+	defer slowSrv.Close()
+	if err := postEvent(WebhookConfig{URL: slowSrv.URL, Timeout: "20ms"}, e); err == nil {
+		t.Error("postEvent succeeded after the timeout")
+	}
+}
+
+func TestNotifyTunnelTransitions(t *testing.T) {
+	rcv := &eventReceiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+	nm := &NMgr{Config: &Config{Name: "net", Webhooks: []WebhookConfig{
+		{URL: srv.URL, Events: []string{EventTunnelStateChanged}},
+	}}, runID: "run-1"}
+
+	poll := func(s0, s1 string) []*ConnectionStatus {
+		return []*ConnectionStatus{{
+			Name:            "site1-nsg1-port1",
+			Region:          "us-west-2",
+			VpnConnectionID: "vpn-1",
+			Tunnels:         []TunnelStatus{{OutsideIP: "1.1.1.1", Status: s0}, {OutsideIP: "2.2.2.2", Status: s1}},
+		}}
+	}
+	// the first poll only records the status
+	nm.notifyTunnelTransitions(poll("DOWN", "UP"))
+	nm.notifyTunnelTransitions(poll("UP", "UP"))
+	nm.notifyTunnelTransitions(poll("UP", "DOWN"))
+	nm.FlushEvents()
+
+	if len(rcv.events) != 2 {
+		t.Fatalf("got %d events, want 2", len(rcv.events))
+	}
+	for i, want := range []TunnelEvent{
+		{Connection: "site1-nsg1-port1", Region: "us-west-2", VpnConnectionID: "vpn-1", Tunnel: 0, OutsideIP: "1.1.1.1", PreviousStatus: "DOWN", Status: "UP", TunnelsUp: 2},
+		{Connection: "site1-nsg1-port1", Region: "us-west-2", VpnConnectionID: "vpn-1", Tunnel: 1, OutsideIP: "2.2.2.2", PreviousStatus: "UP", Status: "DOWN", TunnelsUp: 1},
+	} {
+		e := rcv.events[i]
+		if e.Type != EventTunnelStateChanged || e.Network != "net" || e.RunID != "run-1" {
+			t.Errorf("event %d: unexpected event %+v", i, e)
+		}
+		if e.Tunnel == nil || *e.Tunnel != want {
+			t.Errorf("event %d: tunnel = %+v, want %+v", i, e.Tunnel, want)
+		}
+	}
+}
+
+func TestNotifyFiltersAndFlushes(t *testing.T) {
+	rcv := &eventReceiver{delay: 100 * time.Millisecond}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+	nm := &NMgr{Config: &Config{Webhooks: []WebhookConfig{
+		{URL: srv.URL, Events: []string{EventResourceCreated}},
+	}}}
+
+	// the posts are queued and do not wait for the slow webhook
+	start := time.Now()
+	for _, id := range []string{"a", "b", "c"} {
+		e := nm.newEvent(EventResourceCreated)
+		e.Resource = &ResourceResult{Kind: "site", ID: id}
+		nm.notify(e)
+	}
+	nm.notify(nm.newEvent(EventDeployStarted))
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("notify waited %s for the webhook", d)
+	}
+	nm.FlushEvents()
+
+	if len(rcv.events) != 3 {
+		t.Fatalf("got %d events, want 3", len(rcv.events))
+	}
+	for i, id := range []string{"a", "b", "c"} {
+		if rcv.events[i].Resource == nil || rcv.events[i].Resource.ID != id {
+			t.Errorf("event %d: resource = %+v, want id %s", i, rcv.events[i].Resource, id)
+		}
+	}
+	// events after the flush are dropped
+	nm.notify(nm.newEvent(EventResourceCreated))
+}
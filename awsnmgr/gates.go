@@ -0,0 +1,157 @@
+package awsnmgr
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	nmtypes "github.com/aws/aws-sdk-go-v2/service/networkmanager/types"
+)
+
+// defaultWaitTimeout bounds the waits of a run without timeout
+const defaultWaitTimeout = 30 * time.Minute
+
+// waitExpired returns an error when the wait that started at start runs longer than the
+// timeout of the run, the waits check it before every sleep
+func (nm *NMgr) waitExpired(what string, start time.Time) error {
+	timeout := nm.timeout
+	if timeout <= 0 {
+		timeout = defaultWaitTimeout
+	}
+	if time.Since(start) > timeout {
+		return fmt.Errorf("timeout after %s waiting for %s", timeout, what)
+	}
+	return nil
+}
+
+// WaitTransitGatewaysReady waits till the tgws of the topology are available and
+// registered in the global network, tgws that are not registered yet are registered
+func (nm *NMgr) WaitTransitGatewaysReady() error {
+	for deviceName, d := range nm.Devices {
+		if d.Kind != "tgw" {
+			continue
+		}
+		if err := nm.waitTransitGatewayAvailable(d); err != nil {
+			return err
+		}
+		if err := nm.waitTransitGatewayRegistered(d); err != nil {
+			return err
+		}
+		deviceLog(d).Infof("Transit GW ready: %s %s", deviceName, *d.DeviceID)
+	}
+	return nil
+}
+
+// waitTransitGatewayAvailable waits till the tgw is in available state
+func (nm *NMgr) waitTransitGatewayAvailable(d *Device) error {
+	what := "transit gateway available " + d.Name
+	start := time.Now()
+	defer nm.trackWait(what, start)
+	i := 0
+	for {
+		r, err := nm.DescribeTransitGateways(&d.Region, &d.Name)
+		if err != nil {
+			return err
+		}
+		found := false
+		for j, t := range r.TransitGateways {
+			if t.State == types.TransitGatewayStateDeleted || t.State == types.TransitGatewayStateDeleting {
+				continue
+			}
+			found = true
+			nm.setTransitGateway(d, &r.TransitGateways[j])
+			if t.State == types.TransitGatewayStateAvailable {
+				return nil
+			}
+		}
+		if !found {
+			return fmt.Errorf("transit GW does not exist: %s", d.Name)
+		}
+		if err := nm.waitExpired(what, start); err != nil {
+			return err
+		}
+		i++
+		deviceLog(d).Infof("Wait 30 seconds to check if the transit GW %s is in available state, total (%d sec)", d.Name, i*30)
+		time.Sleep(30 * time.Second)
+	}
+}
+
+// waitTransitGatewayRegistered waits till the registration of the tgw in the global network is available
+func (nm *NMgr) waitTransitGatewayRegistered(d *Device) error {
+	what := "transit gateway registered " + d.Name
+	start := time.Now()
+	defer nm.trackWait(what, start)
+	i := 0
+	for {
+		r, err := nm.GetTransitGatewayRegistrations()
+		if err != nil {
+			return err
+		}
+		var state nmtypes.TransitGatewayRegistrationState
+		for _, t := range r.TransitGatewayRegistrations {
+			if strValue(t.TransitGatewayArn) == *d.DeviceARN && t.State != nil {
+				state = t.State.Code
+			}
+		}
+		switch state {
+		case nmtypes.TransitGatewayRegistrationStateAvailable:
+			return nil
+		case nmtypes.TransitGatewayRegistrationStateFailed:
+			return fmt.Errorf("registration of transit GW %s failed", d.Name)
+		case "", nmtypes.TransitGatewayRegistrationStateDeleted:
+			deviceLog(d).Infof("Register Transit GW: %s", d.Name)
+			if _, err := nm.RegisterTransitGateway(d.DeviceARN); err != nil {
+				return err
+			}
+		}
+		if err := nm.waitExpired(what, start); err != nil {
+			return err
+		}
+		i++
+		deviceLog(d).Infof("Wait 30 seconds to check if the registration of transit GW %s is available, total (%d sec)", d.Name, i*30)
+		time.Sleep(30 * time.Second)
+	}
+}
+
+// WaitVpnAttachmentsDeleted waits till the VPN attachments of the tgws are deleted, the
+// tgws cannot be deleted before
+func (nm *NMgr) WaitVpnAttachmentsDeleted() error {
+	what := "vpn attachments deleted"
+	start := time.Now()
+	defer nm.trackWait(what, start)
+	for _, d := range nm.Devices {
+		if d.Kind != "tgw" {
+			continue
+		}
+		found, err := nm.findTransitGateway(d)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		i := 0
+		for {
+			r, err := nm.DescribeTransitGatewayAttachmentsByTgw(&d.Region, d.DeviceID, string(types.TransitGatewayAttachmentResourceTypeVpn))
+			if err != nil {
+				return err
+			}
+			remaining := 0
+			for _, a := range r.TransitGatewayAttachments {
+				if a.State != types.TransitGatewayAttachmentStateDeleted {
+					remaining++
+				}
+			}
+			if remaining == 0 {
+				break
+			}
+			if err := nm.waitExpired(what, start); err != nil {
+				return err
+			}
+			i++
+			deviceLog(d).Infof("Wait 30 seconds to check if the %d VPN attachments of transit GW %s are deleted, total (%d sec)", remaining, d.Name, i*30)
+			time.Sleep(30 * time.Second)
+		}
+	}
+	return nil
+}
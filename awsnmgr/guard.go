@@ -0,0 +1,263 @@
+package awsnmgr
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	nmtypes "github.com/aws/aws-sdk-go-v2/service/networkmanager/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// findGlobalNetwork returns the global network with the Name tag of the topology
+func (nm *NMgr) findGlobalNetwork() (*nmtypes.GlobalNetwork, error) {
+	r, err := nm.DescribeGlobalNetworks()
+	if err != nil {
+		return nil, err
+	}
+	for i, g := range r.GlobalNetworks {
+		if nmNameTag(g.Tags) == nm.Config.Name {
+			return &r.GlobalNetworks[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// DestroyPlan returns the resources a destroy deletes, sites selects the sites and their
+// connections and network the tgws and the global network. The destroy is refused when
+// it deletes a protected device or global network, or a tgw with attachments that were
+// not created by the tool
+func (nm *NMgr) DestroyPlan(sites, network bool) ([]string, error) {
+	var plan []string
+	g, err := nm.findGlobalNetwork()
+	if err != nil {
+		return nil, err
+	}
+	if g != nil {
+		nm.GlobalNetworkID = g.GlobalNetworkId
+	}
+
+	if sites {
+		p, err := nm.destroySitesPlan(g != nil)
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, p...)
+	}
+
+	if network {
+		p, err := nm.destroyNetworkPlan()
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, p...)
+		if g != nil {
+			if nm.Config.Protect {
+				return nil, fmt.Errorf("global network %s is protected", nm.Config.Name)
+			}
+			plan = append(plan, "global network "+nm.Config.Name+" "+strValue(g.GlobalNetworkId))
+		}
+	}
+	return plan, nil
+}
+
+// destroySitesPlan returns the Network Manager, EC2 and VSD resources of the sites
+func (nm *NMgr) destroySitesPlan(globalNetwork bool) ([]string, error) {
+	var plan []string
+	if globalNetwork {
+		sites, err := nm.siteInventory()
+		if err != nil {
+			return nil, err
+		}
+		devices, err := nm.deviceInventory()
+		if err != nil {
+			return nil, err
+		}
+		links, err := nm.linkInventory()
+		if err != nil {
+			return nil, err
+		}
+		if nm.selectedAll() {
+			// destroy sites removes all sites, devices and links of the global network
+			for _, l := range links.all() {
+				plan = append(plan, "link "+nmNameTag(l.Tags)+" "+strValue(l.LinkId))
+			}
+			for _, d := range devices.all() {
+				name := nmNameTag(d.Tags)
+				if dev, ok := nm.Devices[name]; ok && dev.Protect {
+					return nil, fmt.Errorf("device %s is protected", name)
+				}
+				plan = append(plan, "device "+name+" "+strValue(d.DeviceId))
+			}
+			for _, s := range sites.all() {
+				plan = append(plan, "site "+nmNameTag(s.Tags)+" "+strValue(s.SiteId))
+			}
+		} else {
+			for siteName := range nm.Sites {
+				sa := sites.name(siteName)
+				if sa == nil {
+					continue
+				}
+				for deviceName, d := range nm.Devices {
+					if d.Kind != "sdwan" {
+						continue
+					}
+					for _, da := range devices.named(deviceName) {
+						if strValue(da.SiteId) != strValue(sa.SiteId) {
+							continue
+						}
+						for epName := range d.Endpoints {
+							for _, la := range links.named(epName) {
+								if strValue(la.SiteId) == strValue(sa.SiteId) {
+									plan = append(plan, "link "+epName+" "+strValue(la.LinkId))
+								}
+							}
+						}
+						if nm.fullySelectedDevice(deviceName) {
+							if d.Protect {
+								return nil, fmt.Errorf("device %s is protected", deviceName)
+							}
+							plan = append(plan, "device "+deviceName+" "+strValue(da.DeviceId))
+						}
+					}
+				}
+				if nm.fullySelectedSite(siteName) {
+					plan = append(plan, "site "+siteName+" "+strValue(sa.SiteId))
+				}
+			}
+		}
+	}
+
+	for i := 0; i < len(nm.Connections); i++ {
+		ep := nm.Connections[i].A
+		if ep.Device.Kind != "sdwan" || ep.PublicIP == "" {
+			continue
+		}
+		rv, err := nm.DescribeVpnConnections(&ep.Region, &ep.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range rv.VpnConnections {
+			if v.State == types.VpnStateDeleted {
+				continue
+			}
+			plan = append(plan, "vpn connection "+ep.Name+" "+strValue(v.VpnConnectionId))
+			for j := 0; j < 2; j++ {
+				plan = append(plan, "VSD IKE gateway, profile and connection TGWCGW"+ep.Region+ep.Device.Name+ep.Name+strconv.Itoa(j))
+			}
+		}
+		rc, err := nm.DescribeCustomerGateways(&ep.Region, &ep.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range rc.CustomerGateways {
+			if strValue(c.State) == "deleted" {
+				continue
+			}
+			plan = append(plan, "customer gateway "+ep.Name+" "+strValue(c.CustomerGatewayId))
+		}
+	}
+
+	if nm.selectedAll() {
+		for _, vpc := range nm.Vpcs {
+			plan = append(plan, "vpc attachment "+vpc.AttachmentName)
+		}
+		for _, p := range nm.Peerings {
+			plan = append(plan, "tgw peering "+p.Name)
+		}
+		for deviceName, d := range nm.Devices {
+			if d.Kind != "tgw" {
+				continue
+			}
+			for rtName := range nm.Config.Topology.RouteTables {
+				plan = append(plan, "tgw route table "+deviceName+"-"+rtName)
+			}
+		}
+	}
+	return plan, nil
+}
+
+// destroyNetworkPlan returns the tgws and refuses the destroy of protected tgws and of
+// tgws with attachments that were not created by the tool
+func (nm *NMgr) destroyNetworkPlan() ([]string, error) {
+	var plan []string
+	for deviceName, d := range nm.Devices {
+		if d.Kind != "tgw" {
+			continue
+		}
+		found, err := nm.findTransitGateway(d)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		if d.Protect {
+			return nil, fmt.Errorf("transit GW %s is protected", deviceName)
+		}
+		if err := nm.checkForeignAttachments(d); err != nil {
+			return nil, err
+		}
+		plan = append(plan, "transit gateway "+deviceName+" "+strValue(d.DeviceID))
+	}
+	return plan, nil
+}
+
+// checkForeignAttachments returns an error when the tgw has attachments that are not
+// the VPN connections, VPC attachments or peerings of the topology
+func (nm *NMgr) checkForeignAttachments(d *Device) error {
+	known := make(map[string]bool)
+	for i := 0; i < len(nm.Connections); i++ {
+		ep := nm.Connections[i].A
+		if ep.Device.Kind != "sdwan" || nm.Connections[i].B.Device != d {
+			continue
+		}
+		r, err := nm.DescribeVpnConnections(&ep.Region, &ep.Name)
+		if err != nil {
+			return err
+		}
+		for _, v := range r.VpnConnections {
+			known[strValue(v.VpnConnectionId)] = true
+		}
+	}
+	for _, vpc := range nm.Vpcs {
+		if vpc.Tgw != d {
+			continue
+		}
+		r, err := nm.DescribeTransitGatewayVpcAttachments(&vpc.Region, &vpc.AttachmentName)
+		if err != nil {
+			return err
+		}
+		for _, a := range r.TransitGatewayVpcAttachments {
+			known[strValue(a.TransitGatewayAttachmentId)] = true
+		}
+	}
+	for _, p := range nm.Peerings {
+		if p.A != d && p.B != d {
+			continue
+		}
+		r, err := nm.DescribeTransitGatewayPeeringAttachments(&p.A.Region, &p.Name)
+		if err != nil {
+			return err
+		}
+		for _, a := range r.TransitGatewayPeeringAttachments {
+			known[strValue(a.TransitGatewayAttachmentId)] = true
+		}
+	}
+
+	r, err := nm.DescribeTransitGatewayAttachmentsByTgw(&d.Region, d.DeviceID, "")
+	if err != nil {
+		return err
+	}
+	for _, a := range r.TransitGatewayAttachments {
+		if a.State == types.TransitGatewayAttachmentStateDeleted || a.State == types.TransitGatewayAttachmentStateDeleting {
+			continue
+		}
+		if known[strValue(a.TransitGatewayAttachmentId)] || known[strValue(a.ResourceId)] {
+			continue
+		}
+		log.Errorf("Attachment not created by the tool: %s %s %s %s", d.Name, strValue(a.TransitGatewayAttachmentId), a.ResourceType, strValue(a.ResourceId))
+		return fmt.Errorf("transit GW %s has attachment %s (%s %s) that was not created by the tool", d.Name, strValue(a.TransitGatewayAttachmentId), a.ResourceType, strValue(a.ResourceId))
+	}
+	return nil
+}
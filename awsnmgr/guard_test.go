@@ -0,0 +1,116 @@
+package awsnmgr
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// guardNMgr returns a topology with a tgw, a branch with a vpn connection to the tgw and
+// a VPC attached to the tgw, the tgw has the attachments of the answer
+func guardNMgr(attachments string) *NMgr {
+	nf := &fakeNMgrHTTP{pages: map[string]string{
+		"": `{"GlobalNetworks":[{"GlobalNetworkId":"global-network-1","Tags":[{"Key":"Name","Value":"lab"}]}],` +
+			`"Devices":[{"DeviceId":"device-1","Tags":[{"Key":"Name","Value":"nsg1"}]}]}`,
+	}}
+	answers := map[string]string{
+		"DescribeTransitGateways":              `<DescribeTransitGatewaysResponse><transitGatewaySet><item><transitGatewayId>tgw-1</transitGatewayId><state>available</state></item></transitGatewaySet></DescribeTransitGatewaysResponse>`,
+		"DescribeVpnConnections":               `<DescribeVpnConnectionsResponse><vpnConnectionSet><item><vpnConnectionId>vpn-1</vpnConnectionId><state>available</state></item></vpnConnectionSet></DescribeVpnConnectionsResponse>`,
+		"DescribeTransitGatewayVpcAttachments": `<DescribeTransitGatewayVpcAttachmentsResponse><transitGatewayVpcAttachments><item><transitGatewayAttachmentId>tgw-attach-vpc</transitGatewayAttachmentId></item></transitGatewayVpcAttachments></DescribeTransitGatewayVpcAttachmentsResponse>`,
+		"DescribeTransitGatewayAttachments":    `<DescribeTransitGatewayAttachmentsResponse><transitGatewayAttachments>` + attachments + `</transitGatewayAttachments></DescribeTransitGatewayAttachmentsResponse>`,
+	}
+	ef := &fakeEC2HTTP{answer: func(form url.Values) string { return answers[form.Get("Action")] }}
+
+	nm := newFakeNMgr(nf)
+	nm.ClientEC2 = newFakeEC2(ef).ClientEC2
+	nm.inv = newInventory()
+	nm.Config = &Config{Name: "lab"}
+	tgw := &Device{Name: "tgw-usw2", Kind: "tgw", Region: "us-west-2"}
+	nsg := &Device{Name: "nsg1", Kind: "sdwan"}
+	nm.Devices = map[string]*Device{tgw.Name: tgw, nsg.Name: nsg}
+	nm.Connections = map[int]*Connection{
+		0: {A: &Endpoint{Name: "home1-nsg1-port1", Device: nsg, Region: "us-west-2"}, B: &Endpoint{Name: tgw.Name, Device: tgw}},
+	}
+	nm.Vpcs = map[string]*Vpc{"workload": {Name: "workload", Tgw: tgw, Region: "us-west-2", AttachmentName: "workload-tgw-usw2"}}
+	return nm
+}
+
+// tgwAttachment returns the XML of a tgw attachment
+func tgwAttachment(id, resourceType, resourceID, state string) string {
+	return "<item><transitGatewayAttachmentId>" + id + "</transitGatewayAttachmentId><resourceType>" + resourceType +
+		"</resourceType><resourceId>" + resourceID + "</resourceId><state>" + state + "</state></item>"
+}
+
+func TestDestroyPlanNetwork(t *testing.T) {
+	known := tgwAttachment("tgw-attach-vpn", "vpn", "vpn-1", "available") +
+		tgwAttachment("tgw-attach-vpc", "vpc", "vpc-1", "available") +
+		tgwAttachment("tgw-attach-old", "vpc", "vpc-2", "deleted")
+	foreign := tgwAttachment("tgw-attach-other", "vpc", "vpc-3", "available")
+
+	for _, tc := range []struct {
+		name        string
+		attachments string
+		protect     func(nm *NMgr)
+		err         string
+		plan        string
+	}{
+		{
+			name:        "plan",
+			attachments: known,
+			plan:        "transit gateway tgw-usw2 tgw-1, global network lab global-network-1",
+		},
+		{
+			name:        "foreign attachment",
+			attachments: known + foreign,
+			err:         "transit GW tgw-usw2 has attachment tgw-attach-other (vpc vpc-3) that was not created by the tool",
+		},
+		{
+			name:        "protected tgw",
+			attachments: known,
+			protect:     func(nm *NMgr) { nm.Devices["tgw-usw2"].Protect = true },
+			err:         "transit GW tgw-usw2 is protected",
+		},
+		{
+			name:        "protected global network",
+			attachments: known,
+			protect:     func(nm *NMgr) { nm.Config.Protect = true },
+			err:         "global network lab is protected",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			nm := guardNMgr(tc.attachments)
+			if tc.protect != nil {
+				tc.protect(nm)
+			}
+			plan, err := nm.DestroyPlan(false, true)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Errorf("DestroyPlan error = %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DestroyPlan: %v", err)
+			}
+			if got := strings.Join(plan, ", "); got != tc.plan {
+				t.Errorf("plan = %s, want %s", got, tc.plan)
+			}
+		})
+	}
+}
+
+func TestDestroyPlanSitesProtected(t *testing.T) {
+	nm := guardNMgr("")
+	nm.Devices["nsg1"].Protect = true
+	if _, err := nm.DestroyPlan(true, false); err == nil || err.Error() != "device nsg1 is protected" {
+		t.Errorf("DestroyPlan error = %v, want device nsg1 is protected", err)
+	}
+	nm.Devices["nsg1"].Protect = false
+	plan, err := nm.DestroyPlan(true, false)
+	if err != nil {
+		t.Fatalf("DestroyPlan: %v", err)
+	}
+	if got, want := strings.Join(plan, ", "), "device nsg1 device-1, vpc attachment workload-tgw-usw2"; got != want {
+		t.Errorf("plan = %s, want %s", got, want)
+	}
+}
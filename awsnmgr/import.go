@@ -0,0 +1,355 @@
+package awsnmgr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	nmtypes "github.com/aws/aws-sdk-go-v2/service/networkmanager/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	log "github.com/sirupsen/logrus"
+)
+
+// ImportGlobalNetwork reads the global network (by id or name) with its tgw registrations,
+// sites, devices, links and customer gateway associations and returns the equivalent
+// topology and the state with the ids of the resources. Deploy and destroy find the
+// resources by their Name tag, not by the ids in the state, when adopt is set the
+// resources get a Name tag with their topology name so the deploy and destroy flows find them
+func (nm *NMgr) ImportGlobalNetwork(ref string, adopt bool) (*Config, *State, error) {
+	nm.ClientEC2 = make(map[string]*ec2.Client)
+	nm.identityEC2 = make(map[string]func() (*sts.GetCallerIdentityOutput, error))
+	state := NewState()
+
+	rg, err := nm.DescribeGlobalNetworks()
+	if err != nil {
+		return nil, nil, err
+	}
+	var gn *nmtypes.GlobalNetwork
+	for i, g := range rg.GlobalNetworks {
+		if (g.GlobalNetworkId != nil && *g.GlobalNetworkId == ref) || nmNameTag(g.Tags) == ref ||
+			(g.Description != nil && *g.Description == ref) {
+			gn = &rg.GlobalNetworks[i]
+			break
+		}
+	}
+	if gn == nil {
+		return nil, nil, fmt.Errorf("global network not found: %s", ref)
+	}
+	name := nmName(gn.Tags, gn.Description, gn.GlobalNetworkId)
+	log.Infof("Import Global Network: %s %s", name, *gn.GlobalNetworkId)
+	nm.GlobalNetworkID = gn.GlobalNetworkId
+	state.GlobalNetworkID = *gn.GlobalNetworkId
+	if adopt {
+		nm.adoptNetwResource(gn.Tags, gn.GlobalNetworkArn, name)
+	}
+
+	cfg := &Config{
+		Name:  name,
+		Nuage: nm.Config.Nuage,
+		Aws:   nm.Config.Aws,
+		Topology: Topology{
+			Sites:       make(map[string]SiteConfig),
+			DeviceKinds: make(map[string]DeviceConfig),
+			Devices:     make(map[string]DeviceConfig),
+		},
+	}
+
+	// transit gateways
+	tgws := make(map[string]string)
+	rr, err := nm.GetTransitGatewayRegistrations()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, reg := range rr.TransitGatewayRegistrations {
+		region, id, err := parseEC2Arn(reg.TransitGatewayArn)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := nm.newEC2Client(region); err != nil {
+			return nil, nil, err
+		}
+		r, err := nm.DescribeTransitGateway(&region, &id)
+		if err != nil {
+			return nil, nil, err
+		}
+		tgwName := id
+		for _, t := range r.TransitGateways {
+			tgwName = ec2Name(t.Tags, id)
+			if adopt && ec2NameTag(t.Tags) != tgwName {
+				nm.adoptEC2Resource(region, id, tgwName)
+			}
+		}
+		log.Infof("Import TGW: %s %s %s", tgwName, region, id)
+		tgws[id] = tgwName
+		state.TransitGateways[tgwName] = id
+		cfg.Topology.Devices[tgwName] = DeviceConfig{Kind: "tgw", Region: region}
+	}
+
+	// sites
+	sites := make(map[string]string)
+	rs, err := nm.GetSites()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, s := range rs.Sites {
+		siteName := nmName(s.Tags, s.Description, s.SiteId)
+		log.Infof("Import Site: %s %s", siteName, *s.SiteId)
+		sites[*s.SiteId] = siteName
+		state.Sites[siteName] = *s.SiteId
+		cfg.Topology.Sites[siteName] = parseSiteLocation(s.Location)
+		if adopt {
+			nm.adoptNetwResource(s.Tags, s.SiteArn, siteName)
+		}
+	}
+
+	// devices
+	devices := make(map[string]*nmtypes.Device)
+	deviceNames := make(map[string]string)
+	rd, err := nm.GetDevices()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, d := range rd.Devices {
+		deviceName := nmName(d.Tags, d.Description, d.DeviceId)
+		log.Infof("Import Device: %s %s", deviceName, *d.DeviceId)
+		devices[*d.DeviceId] = &rd.Devices[i]
+		deviceNames[*d.DeviceId] = deviceName
+		state.Devices[deviceName] = *d.DeviceId
+		dc := DeviceConfig{Kind: "sdwan"}
+		if d.Vendor != nil {
+			dc.Vendor = *d.Vendor
+		}
+		if d.Model != nil {
+			dc.Model = *d.Model
+		}
+		if d.SerialNumber != nil {
+			dc.Serial = *d.SerialNumber
+		}
+		cfg.Topology.Devices[deviceName] = dc
+		if adopt {
+			nm.adoptNetwResource(d.Tags, d.DeviceArn, deviceName)
+		}
+	}
+
+	// links
+	links := make(map[string]*nmtypes.Link)
+	rl, err := nm.GetLinks()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, l := range rl.Links {
+		links[*l.LinkId] = &rl.Links[i]
+	}
+
+	// connections through the customer gateway associations
+	linked := make(map[string]bool)
+	rc, err := nm.GetCustomerGatewayAssociations()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, c := range rc.CustomerGatewayAssociations {
+		d, ok := devices[strValue(c.DeviceId)]
+		if !ok {
+			log.Infof("Customer gateway association without device skipped: %s", strValue(c.CustomerGatewayArn))
+			continue
+		}
+		l, ok := links[strValue(c.LinkId)]
+		if !ok {
+			log.Infof("Customer gateway association without link skipped: %s", strValue(c.CustomerGatewayArn))
+			continue
+		}
+		siteName, ok := sites[strValue(d.SiteId)]
+		if !ok {
+			log.Infof("Customer gateway association of a device without site skipped: %s", strValue(c.CustomerGatewayArn))
+			continue
+		}
+		deviceName := deviceNames[*d.DeviceId]
+		linkName := nmName(l.Tags, l.Description, l.LinkId)
+		epName := siteName + "-" + deviceName + "-" + linkName
+		linked[*l.LinkId] = true
+		if adopt {
+			nm.adoptNetwResource(l.Tags, l.LinkArn, linkName)
+		}
+
+		labels := map[string]string{}
+		if l.Provider != nil {
+			labels["provider"] = *l.Provider
+		}
+		if l.Type != nil {
+			labels["kind"] = *l.Type
+		}
+		if l.Bandwidth != nil && l.Bandwidth.DownloadSpeed != nil {
+			labels["bwdown"] = strconv.Itoa(int(*l.Bandwidth.DownloadSpeed))
+		}
+		if l.Bandwidth != nil && l.Bandwidth.UploadSpeed != nil {
+			labels["bwup"] = strconv.Itoa(int(*l.Bandwidth.UploadSpeed))
+		}
+
+		region, cgwID, err := parseEC2Arn(c.CustomerGatewayArn)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := nm.newEC2Client(region); err != nil {
+			return nil, nil, err
+		}
+		rcgw, err := nm.DescribeCustomerGateway(&region, &cgwID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, cgw := range rcgw.CustomerGateways {
+			labels["public-ip"] = strValue(cgw.IpAddress)
+			labels["asn"] = strValue(cgw.BgpAsn)
+			if adopt && ec2NameTag(cgw.Tags) != epName {
+				nm.adoptEC2Resource(region, cgwID, epName)
+			}
+		}
+		state.Links[epName] = *l.LinkId
+		state.CustomerGateways[epName] = cgwID
+
+		cc := ConnectionConfig{
+			Endpoints: []string{siteName + ":" + deviceName + ":" + linkName},
+			Labels:    labels,
+		}
+		rv, err := nm.DescribeVpnConnectionsByCustomerGateway(&region, &cgwID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, v := range rv.VpnConnections {
+			if v.State == types.VpnStateDeleted || v.State == types.VpnStateDeleting {
+				continue
+			}
+			tgwName, ok := tgws[strValue(v.TransitGatewayId)]
+			if !ok {
+				log.Infof("VPN connection %s terminates on a tgw which is not registered in the global network", *v.VpnConnectionId)
+				continue
+			}
+			cc.Endpoints = append(cc.Endpoints, tgwName)
+			// the connection has a single branch cidr, the local network cidr of the vpn
+			// or its only static route
+			if v.Options != nil && strValue(v.Options.LocalIpv4NetworkCidr) != "" && *v.Options.LocalIpv4NetworkCidr != "0.0.0.0/0" {
+				labels["cidr"] = *v.Options.LocalIpv4NetworkCidr
+			} else if len(v.Routes) == 1 {
+				labels["cidr"] = strValue(v.Routes[0].DestinationCidrBlock)
+			} else if len(v.Routes) > 1 {
+				log.Infof("VPN connection %s has %d static routes, only one cidr per connection is supported, the cidr is not imported", *v.VpnConnectionId, len(v.Routes))
+			}
+			if v.Options != nil {
+				cc.Accelerated = v.Options.EnableAcceleration
+			}
+			state.VpnConnections[epName] = *v.VpnConnectionId
+			if adopt && ec2NameTag(v.Tags) != epName {
+				nm.adoptEC2Resource(region, *v.VpnConnectionId, epName)
+			}
+			break
+		}
+		if len(cc.Endpoints) != 2 {
+			log.Infof("Customer gateway %s has no VPN connection to a registered tgw, connection skipped", cgwID)
+			continue
+		}
+		log.Infof("Import Connection: %s -> %s", cc.Endpoints[0], cc.Endpoints[1])
+		cfg.Topology.Connections = append(cfg.Topology.Connections, cc)
+	}
+	for id, l := range links {
+		if !linked[id] {
+			log.Infof("Link without customer gateway association is not imported: %s", nmName(l.Tags, l.Description, l.LinkId))
+		}
+	}
+
+	return cfg, state, nil
+}
+
+// adoptNetwResource sets the Name tag of a Network Manager resource to its topology name
+func (nm *NMgr) adoptNetwResource(tags []nmtypes.Tag, arn *string, name string) {
+	if nmNameTag(tags) == name || arn == nil {
+		return
+	}
+	log.Infof("Tag resource: %s Name=%s", *arn, name)
+	if _, err := nm.TagResourceName(arn, &name); err != nil {
+		log.Errorf("Error tagging resource %s: %s", *arn, err)
+		return
+	}
+	nm.updated("name tag", name, "", *arn)
+}
+
+// adoptEC2Resource sets the Name tag of an EC2 resource to its topology name
+func (nm *NMgr) adoptEC2Resource(region, id, name string) {
+	log.Infof("Tag resource: %s Name=%s", id, name)
+	if _, err := nm.CreateEC2NameTag(&region, &id, &name); err != nil {
+		log.Errorf("Error tagging resource %s: %s", id, err)
+		return
+	}
+	nm.updated("name tag", name, id, "")
+}
+
+// nmNameTag returns the value of the Name tag of a Network Manager resource
+func nmNameTag(tags []nmtypes.Tag) string {
+	for _, t := range tags {
+		if t.Key != nil && *t.Key == "Name" && t.Value != nil {
+			return *t.Value
+		}
+	}
+	return ""
+}
+
+// nmName returns the topology name of a Network Manager resource, the Name tag,
+// the description or the id
+func nmName(tags []nmtypes.Tag, description, id *string) string {
+	name := nmNameTag(tags)
+	if name == "" && description != nil {
+		name = *description
+	}
+	if name == "" {
+		name = strValue(id)
+	}
+	return strings.ReplaceAll(name, ":", "-")
+}
+
+// ec2NameTag returns the value of the Name tag of an EC2 resource
+func ec2NameTag(tags []types.Tag) string {
+	for _, t := range tags {
+		if t.Key != nil && *t.Key == "Name" && t.Value != nil {
+			return *t.Value
+		}
+	}
+	return ""
+}
+
+// ec2Name returns the topology name of an EC2 resource, the Name tag or the id
+func ec2Name(tags []types.Tag, id string) string {
+	if name := ec2NameTag(tags); name != "" {
+		return strings.ReplaceAll(name, ":", "-")
+	}
+	return id
+}
+
+// parseEC2Arn returns the region and the resource id of an EC2 arn
+// (arn:aws:ec2:<region>:<account>:<resource-type>/<id>)
+func parseEC2Arn(arn *string) (string, string, error) {
+	split := strings.Split(strValue(arn), ":")
+	if len(split) != 6 || !strings.Contains(split[5], "/") {
+		return "", "", fmt.Errorf("arn has wrong syntax: %s", strValue(arn))
+	}
+	return split[3], split[5][strings.Index(split[5], "/")+1:], nil
+}
+
+// parseSiteLocation returns the site address from the location, the address is split
+// in the fields used by CreateSite (street, number, city, state, country) when possible
+func parseSiteLocation(l *nmtypes.Location) SiteConfig {
+	site := SiteConfig{}
+	if l == nil || l.Address == nil {
+		return site
+	}
+	split := strings.Split(*l.Address, ", ")
+	if len(split) != 5 {
+		site.Street = *l.Address
+		return site
+	}
+	site.Street = split[0]
+	site.Number, _ = strconv.Atoi(split[1])
+	site.City = split[2]
+	site.State = split[3]
+	site.Country = split[4]
+	return site
+}
@@ -0,0 +1,173 @@
+package awsnmgr
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/networkmanager/types"
+)
+
+// resourceCache caches the Network Manager resources of one type within a run,
+// indexed by Name tag and by id
+type resourceCache[T any] struct {
+	loaded bool
+	items  []*T
+	byName map[string][]*T
+	byID   map[string]*T
+	id     func(*T) *string
+	tags   func(*T) []types.Tag
+}
+
+func newResourceCache[T any](id func(*T) *string, tags func(*T) []types.Tag) *resourceCache[T] {
+	c := &resourceCache[T]{id: id, tags: tags}
+	c.reset()
+	return c
+}
+
+func (c *resourceCache[T]) reset() {
+	c.loaded = false
+	c.items = nil
+	c.byName = make(map[string][]*T)
+	c.byID = make(map[string]*T)
+}
+
+// load fills the cache with the list of resources once, later calls are served from the cache
+func (c *resourceCache[T]) load(list func() ([]T, error)) error {
+	if c.loaded {
+		return nil
+	}
+	items, err := list()
+	if err != nil {
+		return err
+	}
+	for i := range items {
+		c.add(&items[i])
+	}
+	c.loaded = true
+	return nil
+}
+
+// add adds a created resource to the cache
+func (c *resourceCache[T]) add(item *T) {
+	id := strValue(c.id(item))
+	if _, ok := c.byID[id]; ok {
+		c.remove(id)
+	}
+	c.items = append(c.items, item)
+	c.byID[id] = item
+	if name := nmNameTag(c.tags(item)); name != "" {
+		c.byName[name] = append(c.byName[name], item)
+	}
+}
+
+// remove removes a deleted resource from the cache
+func (c *resourceCache[T]) remove(id string) {
+	item, ok := c.byID[id]
+	if !ok {
+		return
+	}
+	delete(c.byID, id)
+	for i, it := range c.items {
+		if it == item {
+			c.items = append(c.items[:i], c.items[i+1:]...)
+			break
+		}
+	}
+	name := nmNameTag(c.tags(item))
+	for i, it := range c.byName[name] {
+		if it == item {
+			c.byName[name] = append(c.byName[name][:i], c.byName[name][i+1:]...)
+			break
+		}
+	}
+	if len(c.byName[name]) == 0 {
+		delete(c.byName, name)
+	}
+}
+
+// name returns the first resource with the Name tag
+func (c *resourceCache[T]) name(name string) *T {
+	if items := c.byName[name]; len(items) > 0 {
+		return items[0]
+	}
+	return nil
+}
+
+// named returns all resources with the Name tag
+func (c *resourceCache[T]) named(name string) []*T {
+	return c.byName[name]
+}
+
+// all returns a copy of the cached resources, so resources can be removed while iterating
+func (c *resourceCache[T]) all() []*T {
+	return append([]*T(nil), c.items...)
+}
+
+// inventory caches the sites, devices and links of the global network within a run,
+// each resource type is loaded once and updated on create and delete
+type inventory struct {
+	globalNetworkID string
+	sites           *resourceCache[types.Site]
+	devices         *resourceCache[types.Device]
+	links           *resourceCache[types.Link]
+}
+
+func newInventory() *inventory {
+	return &inventory{
+		sites: newResourceCache(
+			func(s *types.Site) *string { return s.SiteId },
+			func(s *types.Site) []types.Tag { return s.Tags }),
+		devices: newResourceCache(
+			func(d *types.Device) *string { return d.DeviceId },
+			func(d *types.Device) []types.Tag { return d.Tags }),
+		links: newResourceCache(
+			func(l *types.Link) *string { return l.LinkId },
+			func(l *types.Link) []types.Tag { return l.Tags }),
+	}
+}
+
+// checkGlobalNetwork resets the inventory when the global network changed
+func (nm *NMgr) checkGlobalNetwork() {
+	if id := strValue(nm.GlobalNetworkID); id != nm.inv.globalNetworkID {
+		nm.inv.globalNetworkID = id
+		nm.inv.sites.reset()
+		nm.inv.devices.reset()
+		nm.inv.links.reset()
+	}
+}
+
+// siteInventory returns the cached sites of the global network
+func (nm *NMgr) siteInventory() (*resourceCache[types.Site], error) {
+	nm.checkGlobalNetwork()
+	err := nm.inv.sites.load(func() ([]types.Site, error) {
+		r, err := nm.GetSites()
+		if err != nil {
+			return nil, err
+		}
+		return r.Sites, nil
+	})
+	return nm.inv.sites, err
+}
+
+// deviceInventory returns the cached devices of the global network
+func (nm *NMgr) deviceInventory() (*resourceCache[types.Device], error) {
+	nm.checkGlobalNetwork()
+	err := nm.inv.devices.load(func() ([]types.Device, error) {
+		r, err := nm.GetDevices()
+		if err != nil {
+			return nil, err
+		}
+		return r.Devices, nil
+	})
+	return nm.inv.devices, err
+}
+
+// linkInventory returns the cached links of the global network
+func (nm *NMgr) linkInventory() (*resourceCache[types.Link], error) {
+	nm.checkGlobalNetwork()
+	err := nm.inv.links.load(func() ([]types.Link, error) {
+		r, err := nm.GetLinks()
+		if err != nil {
+			return nil, err
+		}
+		return r.Links, nil
+	})
+	return nm.inv.links, err
+}
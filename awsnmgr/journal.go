@@ -0,0 +1,100 @@
+package awsnmgr
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// journalStep is a resource the deploy created in this run with the function that undoes it
+type journalStep struct {
+	Kind string
+	Name string
+	ID   string
+	ARN  string
+	undo func() error
+}
+
+// journal records the resources the deploy creates in this run, resources that already
+// existed are not recorded so a rollback never removes what the run did not create
+type journal struct {
+	steps  []*journalStep
+	undone []*journalStep
+	failed []*journalStep
+	// deleted to replace them, a rollback cannot restore these
+	lost []*journalStep
+	// called for every recorded resource
+	onRecord func(*journalStep)
+}
+
+func newJournal() *journal {
+	return &journal{}
+}
+
+// record adds a created resource to the journal
+func (j *journal) record(kind, name, id, arn string, undo func() error) {
+	log.Debugf("Journal: created %s %s %s", kind, name, id)
+	s := &journalStep{Kind: kind, Name: name, ID: id, ARN: arn, undo: undo}
+	j.steps = append(j.steps, s)
+	if j.onRecord != nil {
+		j.onRecord(s)
+	}
+}
+
+// replaced adds a resource the deploy deleted to replace it, a rollback removes the
+// replacement but cannot restore the deleted resource
+func (j *journal) replaced(kind, name, id string) {
+	log.Debugf("Journal: replaced %s %s %s", kind, name, id)
+	j.lost = append(j.lost, &journalStep{Kind: kind, Name: name, ID: id})
+}
+
+// created returns if the resource was created in this run
+func (j *journal) created(kind, id string) bool {
+	for _, s := range j.steps {
+		if s.Kind == kind && s.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Rollback undoes the resources created in this run in reverse order, a step that
+// fails to undo is reported and the rollback continues with the next step
+func (nm *NMgr) Rollback() {
+	j := nm.journal
+	log.Infof("Rolling back %d created resources....", len(j.steps))
+	for i := len(j.steps) - 1; i >= 0; i-- {
+		s := j.steps[i]
+		log.Infof("Undo %s: %s %s", s.Kind, s.Name, s.ID)
+		if err := s.undo(); err != nil {
+			log.Errorf("Error undoing %s %s %s: %s", s.Kind, s.Name, s.ID, err)
+			j.failed = append(j.failed, s)
+			continue
+		}
+		j.undone = append(j.undone, s)
+	}
+	j.steps = nil
+	for _, s := range j.lost {
+		log.Warnf("Cannot restore %s %s %s, it was deleted to replace it", s.Kind, s.Name, s.ID)
+	}
+	if len(j.undone) > 0 {
+		// the completed phases no longer match what exists
+		nm.resetCheckpoint()
+	}
+}
+
+// LogDeployReport logs the resources the deploy created and the resources the rollback undid
+func (nm *NMgr) LogDeployReport() {
+	j := nm.journal
+	for _, s := range j.steps {
+		log.Infof("Created %s: %s %s", s.Kind, s.Name, s.ID)
+	}
+	for _, s := range j.lost {
+		log.Infof("Replaced %s: %s %s", s.Kind, s.Name, s.ID)
+	}
+	for _, s := range j.undone {
+		log.Infof("Undone %s: %s %s", s.Kind, s.Name, s.ID)
+	}
+	for _, s := range j.failed {
+		log.Errorf("Left behind %s: %s %s", s.Kind, s.Name, s.ID)
+	}
+	log.Infof("Deploy report: %d created, %d undone, %d left behind", len(j.steps), len(j.undone), len(j.failed))
+}
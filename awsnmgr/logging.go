@@ -0,0 +1,106 @@
+package awsnmgr
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	log "github.com/sirupsen/logrus"
+)
+
+// RunIDTagKey is the tag with the run id that is set on the created resources
+const RunIDTagKey = "run-id"
+
+// NewRunID returns a random id that correlates the logs, API requests and resources of a run
+func NewRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// RunIDHook adds the run id field to every log entry
+type RunIDHook struct {
+	RunID string
+}
+
+// Levels function
+func (h *RunIDHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire function
+func (h *RunIDHook) Fire(e *log.Entry) error {
+	e.Data["run_id"] = h.RunID
+	return nil
+}
+
+// SetLogFormat sets the text or json format of the logs
+func SetLogFormat(format string) error {
+	switch format {
+	case "", "text":
+		log.SetFormatter(&log.TextFormatter{})
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		return fmt.Errorf("invalid log format %s, supported formats are text and json", format)
+	}
+	return nil
+}
+
+// addRunIDUserAgent adds the run id to the user agent of every AWS request, so the
+// requests of a run can be found in CloudTrail
+func (nm *NMgr) addRunIDUserAgent(cfg *aws.Config) {
+	if nm.runID == "" {
+		return
+	}
+	cfg.APIOptions = append(cfg.APIOptions, awsmiddleware.AddUserAgentKeyValue(RunIDTagKey, nm.runID))
+}
+
+// siteLog returns a log entry with the site field
+func siteLog(site string) *log.Entry {
+	return log.WithField("site", site)
+}
+
+// deviceLog returns a log entry with the site, device, region and AWS resource id fields
+// of the device
+func deviceLog(d *Device) *log.Entry {
+	f := log.Fields{"device": d.Name}
+	if d.Site != nil {
+		f["site"] = d.Site.Name
+	}
+	if d.Region != "" {
+		f["region"] = d.Region
+	}
+	if d.DeviceID != nil {
+		f["aws_id"] = *d.DeviceID
+	}
+	return log.WithFields(f)
+}
+
+// endpointLog returns a log entry with the site, device, endpoint and region fields of
+// the endpoint
+func endpointLog(ep *Endpoint) *log.Entry {
+	f := log.Fields{"endpoint": ep.Name, "region": ep.Region}
+	if ep.Site != nil {
+		f["site"] = ep.Site.Name
+	}
+	if ep.Device != nil {
+		f["device"] = ep.Device.Name
+	}
+	return log.WithFields(f)
+}
+
+// awsLog returns a log entry with the region and AWS resource id fields
+func awsLog(region string, id *string) *log.Entry {
+	return log.WithFields(log.Fields{"region": region, "aws_id": strValue(id)})
+}
+
+// vsdLog returns a log entry with the VSD object id field
+func vsdLog(id string) *log.Entry {
+	return log.WithField("vsd_id", id)
+}
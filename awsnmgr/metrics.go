@@ -0,0 +1,209 @@
+package awsnmgr
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// metricsWriter writes metrics in the Prometheus text format, the help and type of a
+// metric are written before its first sample
+type metricsWriter struct {
+	b    strings.Builder
+	seen map[string]bool
+}
+
+func newMetricsWriter() *metricsWriter {
+	return &metricsWriter{seen: make(map[string]bool)}
+}
+
+// sample writes a sample of the metric, labels are name and value pairs
+func (w *metricsWriter) sample(name, kind, help string, value float64, labels ...string) {
+	if !w.seen[name] {
+		fmt.Fprintf(&w.b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		w.seen[name] = true
+	}
+	w.b.WriteString(name)
+	if len(labels) > 0 {
+		w.b.WriteString("{")
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.b.WriteString(",")
+			}
+			fmt.Fprintf(&w.b, "%s=%s", labels[i], strconv.Quote(labels[i+1]))
+		}
+		w.b.WriteString("}")
+	}
+	fmt.Fprintf(&w.b, " %s\n", strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// CollectMetrics returns the health of the topology in the Prometheus text format: the
+// tunnel state and accepted routes from the VGW telemetry, the Network Manager customer
+// gateway associations, the VSD IKE gateway connections, the phase durations of the last
+// deploy in the state file and the API retry and error counters. A failing source is
+// logged and reported through nuage_aws_collect_success
+func (nm *NMgr) CollectMetrics(stateFile string) []byte {
+	start := time.Now()
+	nm.retries.resetBudget()
+	w := newMetricsWriter()
+	success := true
+
+	status, err := nm.GetConnectionStatus()
+	if err != nil {
+		log.Errorf("Error collecting the vpn connection status: %s", err)
+		success = false
+	} else {
+		nm.notifyTunnelTransitions(status)
+	}
+	nm.writeTunnelMetrics(w, status)
+
+	if err := nm.writeAssociationMetrics(w, status); err != nil {
+		log.Errorf("Error collecting the customer gateway associations: %s", err)
+		success = false
+	}
+	if err := nm.writeIKEMetrics(w); err != nil {
+		log.Errorf("Error collecting the VSD IKE gateway connections: %s", err)
+		success = false
+	}
+	if stateFile != "" {
+		s, err := LoadState(stateFile)
+		if err != nil {
+			log.Errorf("Error reading state file %s: %s", stateFile, err)
+			success = false
+		} else {
+			for _, phase := range sortedKeys(s.PhaseDurations) {
+				w.sample("nuage_aws_deploy_phase_duration_seconds", "gauge", "Duration of the deploy phases of the last deploy.", s.PhaseDurations[phase], "phase", phase)
+			}
+		}
+	}
+	nm.writeAPIMetrics(w)
+
+	w.sample("nuage_aws_collect_success", "gauge", "Whether all sources of the last collection succeeded.", boolValue(success))
+	w.sample("nuage_aws_collect_duration_seconds", "gauge", "Duration of the last collection.", time.Since(start).Seconds())
+	return []byte(w.b.String())
+}
+
+// writeTunnelMetrics writes the state and accepted routes of the tunnels and the number
+// of tunnels up per connection
+func (nm *NMgr) writeTunnelMetrics(w *metricsWriter, status []*ConnectionStatus) {
+	for _, cs := range status {
+		w.sample("nuage_aws_vpn_connection_available", "gauge", "Whether the VPN connection is available.", boolValue(cs.State == "available"),
+			"connection", cs.Name, "region", cs.Region, "vpn_connection", cs.VpnConnectionID)
+		up := 0
+		for i, t := range cs.Tunnels {
+			labels := []string{"connection", cs.Name, "region", cs.Region, "vpn_connection", cs.VpnConnectionID, "tunnel", strconv.Itoa(i), "outside_ip", t.OutsideIP}
+			w.sample("nuage_aws_tunnel_up", "gauge", "Whether the tunnel is UP in the VGW telemetry.", boolValue(t.Status == "UP"), labels...)
+			w.sample("nuage_aws_tunnel_accepted_routes", "gauge", "Number of routes accepted on the tunnel.", float64(t.AcceptedRoutes), labels...)
+			if t.Status == "UP" {
+				up++
+			}
+		}
+		w.sample("nuage_aws_connection_tunnels_up", "gauge", "Number of tunnels of the connection that are UP, 0 when the branch lost both tunnels.", float64(up),
+			"connection", cs.Name, "region", cs.Region)
+	}
+}
+
+// writeAssociationMetrics writes the state of the Network Manager association of the
+// customer gateway of each connection
+func (nm *NMgr) writeAssociationMetrics(w *metricsWriter, status []*ConnectionStatus) error {
+	g, err := nm.findGlobalNetwork()
+	if err != nil {
+		return err
+	}
+	if g == nil {
+		return fmt.Errorf("global network %s not found", nm.Config.Name)
+	}
+	nm.GlobalNetworkID = g.GlobalNetworkId
+	r, err := nm.GetCustomerGatewayAssociations()
+	if err != nil {
+		return err
+	}
+	for _, cs := range status {
+		state := "NOT_ASSOCIATED"
+		for _, a := range r.CustomerGatewayAssociations {
+			if cs.CustomerGateway != "" && strings.HasSuffix(strValue(a.CustomerGatewayArn), "/"+cs.CustomerGateway) {
+				state = string(a.State)
+			}
+		}
+		w.sample("nuage_aws_customer_gateway_association", "gauge", "Network Manager association state of the customer gateway of the connection.", 1,
+			"connection", cs.Name, "region", cs.Region, "state", state)
+	}
+	return nil
+}
+
+// writeIKEMetrics writes the configuration status of the VSD IKE gateway connections
+// of the tunnels
+func (nm *NMgr) writeIKEMetrics(w *metricsWriter) error {
+	enterprise, err := nm.getEnterprise(nm.Config.Nuage.Enterprise)
+	if err != nil {
+		return err
+	}
+	if enterprise == nil {
+		return fmt.Errorf("enterprise does not exist: %s", nm.Config.Nuage.Enterprise)
+	}
+	for i := 0; i < len(nm.Connections); i++ {
+		ep := nm.Connections[i].A
+		if ep.Device.Kind != "sdwan" || ep.PublicIP == "" {
+			continue
+		}
+		if ep.NuageVlan == nil {
+			nsg, err := nm.getNsg(ep.Device.Name, enterprise)
+			if err != nil {
+				return err
+			}
+			if nsg == nil {
+				return fmt.Errorf("Nuage NSG device does not exist: %s", ep.Device.Name)
+			}
+			if ep.NuageVlan, err = nm.getEndpointVlan(ep, nsg); err != nil {
+				return err
+			}
+		}
+		for j := 0; j < 2; j++ {
+			name := "TGWCGW" + ep.Region + ep.Device.Name + ep.Name + strconv.Itoa(j)
+			c, err := nm.getIKEGatewayConnection(name, ep.NuageVlan)
+			if err != nil {
+				return err
+			}
+			state := "NOT_FOUND"
+			if c != nil {
+				state = c.ConfigurationStatus
+			}
+			w.sample("nuage_vsd_ike_gateway_connection", "gauge", "Configuration status of the VSD IKE gateway connection of the tunnel.", 1,
+				"connection", ep.Name, "tunnel", strconv.Itoa(j), "status", state)
+		}
+	}
+	return nil
+}
+
+// writeAPIMetrics writes the retries and the failed calls per API since the start
+func (nm *NMgr) writeAPIMetrics(w *metricsWriter) {
+	nm.retries.mu.Lock()
+	defer nm.retries.mu.Unlock()
+	for _, api := range sortedKeys(nm.retries.counts) {
+		w.sample("nuage_aws_api_retries_total", "counter", "Number of retried AWS and VSD API calls.", float64(nm.retries.counts[api]), "api", api)
+	}
+	for _, api := range sortedKeys(nm.retries.errors) {
+		w.sample("nuage_aws_api_errors_total", "counter", "Number of AWS and VSD API calls that failed after their retries.", float64(nm.retries.errors[api]), "api", api)
+	}
+}
+
+// sortedKeys returns the keys of the map in a stable order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
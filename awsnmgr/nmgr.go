@@ -2,11 +2,14 @@ package awsnmgr
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/networkmanager"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/henderiw/nuage-wrapper/pkg/vspk"
 	"github.com/nuagenetworks/go-bambou/bambou"
 
@@ -28,6 +31,8 @@ type NMgr struct {
 	Sites       map[string]*Site
 	Devices     map[string]*Device
 	Connections map[int]*Connection
+	Vpcs        map[string]*Vpc
+	Peerings    []*Peering
 
 	Region          *string
 	GlobalNetworkID *string
@@ -36,10 +41,36 @@ type NMgr struct {
 	ClientEC2  map[string]*ec2.Client
 	VsdUsr     *vspk.Me
 
+	// caller identity of the EC2 client per region
+	identityEC2 map[string]func() (*sts.GetCallerIdentityOutput, error)
+
+	inv *inventory
+
 	ctx context.Context
 
+	journal     *journal
+	selection   *selection
+	state       *State
+	stateFile   string
+	resume      bool
+	phaseStart  time.Time
+	retryPolicy RetryPolicy
+	retries     *retryStats
+	result      *Result
+	runID       string
+	command     string
+	audit       *auditLog
+	// status of the tunnels at the previous poll, to detect state changes
+	tunnelStatus map[string]string
+	// webhook posts that are sent in the background
+	events         *eventQueue
+	eventsOnce     sync.Once
+	deployFinished bool
+
 	debug   bool
 	timeout time.Duration
+	// error of the options, returned by the constructor
+	optErr error
 }
 
 // Site is a struct that contains the information of a site element
@@ -54,22 +85,66 @@ type Site struct {
 	Country   string
 	Devices   map[string]*Device
 	Endpoints map[string]*Endpoint
+	Tags      map[string]string
+
+	RedundantGroup      string
+	RedundantDevices    []*Device
+	ShuntLink           *ShuntLinkConfig
+	NuageRedundantGroup *vspk.NSRedundantGatewayGroup
 }
 
 // Device is a struct that contains the information of a device element
 type Device struct {
+	Name            string
+	DeviceID        *string
+	DeviceARN       *string
+	NuageNSGateway  *vspk.NSGateway
+	Index           int
+	Kind            string
+	Model           string
+	Serial          string
+	Vendor          string
+	Region          string
+	OwnerID         *string
+	RouteTableID    *string
+	PropagationRTID *string
+	RouteTables     map[string]*string
+	Site            *Site
+	Endpoints       map[string]*Endpoint
+	Protect         bool
+	Tags            map[string]string
+}
+
+// Vpc is a struct that contains the information of a VPC attached to a TGW
+type Vpc struct {
 	Name           string
-	DeviceID       *string
-	DeviceARN      *string
-	NuageNSGateway *vspk.NSGateway
-	Index          int
-	Kind           string
-	Model          string
-	Serial         string
-	Vendor         string
+	ID             string
+	VpcID          *string
+	Cidr           string
 	Region         string
-	Site           *Site
-	Endpoints      map[string]*Endpoint
+	Tgw            *Device
+	Subnets        map[string]*Subnet
+	AttachmentName string
+	AttachmentID   *string
+	RouteTable     string
+}
+
+// Subnet is a struct that contains the information of a VPC subnet in an availability zone
+type Subnet struct {
+	Name     string
+	ID       string
+	SubnetID *string
+	Cidr     string
+	AZ       string
+}
+
+// Peering is a struct that contains the information of a peering between 2 TGWs
+type Peering struct {
+	Name         string
+	A            *Device
+	B            *Device
+	AttachmentID *string
+	RouteTable   string
 }
 
 // Connection is a struct that contains the information of a link between 2 containers
@@ -86,8 +161,10 @@ type Endpoint struct {
 	Name               string
 	LinkID             *string
 	LinkARN            *string
+	Port               string
 	NuagePort          *vspk.NSPort
 	NuageVlan          *vspk.VLAN
+	Vlan               int
 	Provider           string
 	BwUp               int32
 	BwDown             int32
@@ -95,11 +172,19 @@ type Endpoint struct {
 	PublicIP           string
 	Asn                int32
 	Cidr               string
+	IKEVersion         string
+	Tunnels            []TunnelConfig
+	Accelerated        bool
 	Region             string
 	CustomerGatewayID  *string
 	CustomerGatewayARN *string
 	CustomerGatewayIP  []string
 	VPNConnState       string
+	VpnConnectionID    *string
+	RouteTable         string
+	BGP                bool
+	TunnelInsideIPs    []string
+	Tags               map[string]string
 }
 
 // Option struct
@@ -112,6 +197,27 @@ func WithDebug(d bool) Option {
 	}
 }
 
+// WithRunID function
+func WithRunID(id string) Option {
+	return func(nm *NMgr) {
+		nm.runID = id
+	}
+}
+
+// WithCommand function
+func WithCommand(command string) Option {
+	return func(nm *NMgr) {
+		nm.command = command
+	}
+}
+
+// WithAuditLog function
+func WithAuditLog(file string) Option {
+	return func(nm *NMgr) {
+		nm.audit.file = file
+	}
+}
+
 // WithTimeout function
 func WithTimeout(dur time.Duration) Option {
 	return func(nm *NMgr) {
@@ -127,21 +233,44 @@ func WithConfigFile(file string) Option {
 		}
 		log.Info(file)
 		if err := nm.GetTopology(file); err != nil {
-			log.Fatalf("failed to read topology file: %v", err)
+			nm.optErr = fmt.Errorf("failed to read topology file: %v", err)
 		}
 	}
 }
 
+// WithNuageURL function
+func WithNuageURL(url string) Option {
+	return func(nm *NMgr) {
+		if url == "" {
+			return
+		}
+		nm.Config.Nuage.URL = url
+	}
+}
+
 // NewAWsNMgrNuage function defines a new dns-proxy
 func NewAWsNMgrNuage(opts ...Option) (*NMgr, error) {
 	nm := &NMgr{
-		Config:     new(Config),
-		ConfigFile: new(string),
-		ctx:        context.Background(),
+		Config:      new(Config),
+		ConfigFile:  new(string),
+		inv:         newInventory(),
+		journal:     newJournal(),
+		state:       NewState(),
+		retryPolicy: defaultRetryPolicy,
+		retries:     newRetryStats(defaultRetryPolicy.MaxTotal),
+		result:      newResult(),
+		audit:       newAuditLog(""),
+		ctx:         context.Background(),
 	}
 	for _, o := range opts {
 		o(nm)
 	}
+	if nm.optErr != nil {
+		return nil, nm.optErr
+	}
+	nm.journal.onRecord = func(s *journalStep) {
+		nm.created(s.Kind, s.Name, s.ID, s.ARN)
+	}
 
 	if nm.Config.Aws.Profile == "" {
 		nm.Config.Aws.Profile = "default"
@@ -149,18 +278,22 @@ func NewAWsNMgrNuage(opts ...Option) (*NMgr, error) {
 
 	cfg, err := config.LoadDefaultConfig(
 		config.WithRegion("us-west-2"),
-		config.WithSharedConfigProfile(nm.Config.Aws.Profile))
+		config.WithSharedConfigProfile(nm.Config.Aws.Profile),
+		config.WithRetryer(nm.newAWSRetryer()))
 	if err != nil {
-		panic("failed to load config, " + err.Error())
+		return nil, fmt.Errorf("failed to load config, %s", err)
 	}
+	nm.addRunIDUserAgent(&cfg)
+	nm.addAPIErrorCounter(&cfg)
+	nm.addAuditLog(&cfg, nm.awsIdentity(cfg))
 
 	nm.Region = &cfg.Region
 	nm.ClientNMgr = networkmanager.NewFromConfig(cfg)
 
 	var s *bambou.Session
 	s, nm.VsdUsr = vspk.NewSession(vsdUser, vsdPass, vsdEnterprise, nm.Config.Nuage.URL)
-	if err := s.Start(); err != nil {
-		log.Fatalf("Unable to connect to Nuage VSD: %s", err.Description)
+	if err := nm.retryVSD("session", s.Start); err != nil {
+		return nil, fmt.Errorf("Unable to connect to Nuage VSD: %s", err.Description)
 	}
 
 	return nm, nil
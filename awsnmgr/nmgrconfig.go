@@ -4,6 +4,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"strconv"
 	"strings"
 	"time"
@@ -11,6 +12,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/henderiw/nuage-wrapper/pkg/vspk"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 )
@@ -18,12 +21,37 @@ import (
 // supported kinds
 var kinds = []string{"sdwan", "tgw"}
 
+// supported IKE versions, the default is used when no version is configured
+var ikeVersions = []string{"V1", "V2"}
+var defaultIKEVersion = "V2"
+
+// supported routing modes between a Nuage domain and the TGW
+var nuageRoutings = []string{"static", "bgp"}
+
+// supported tunnel option values
+var dpdTimeoutActions = []string{"clear", "none", "restart"}
+var startupActions = []string{"add", "start"}
+
+// tunnel inside cidrs that are reserved by AWS
+var reservedInsideCidrs = []string{
+	"169.254.0.0/30",
+	"169.254.1.0/30",
+	"169.254.2.0/30",
+	"169.254.3.0/30",
+	"169.254.4.0/30",
+	"169.254.5.0/30",
+	"169.254.169.252/30",
+}
+
 // Config defines lab configuration as it is provided in the YAML file
 type Config struct {
-	Name     string   `json:"name,omitempty"`
-	Nuage    Nuage    `json:"nuage,omitempty"`
-	Aws      Aws      `json:"aws,omitempty"`
-	Topology Topology `json:"topology,omitempty"`
+	Name     string            `json:"name,omitempty"`
+	Nuage    Nuage             `json:"nuage,omitempty"`
+	Aws      Aws               `json:"aws,omitempty"`
+	Topology Topology          `json:"topology,omitempty"`
+	Protect  bool              `json:"protect,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	Webhooks []WebhookConfig   `json:"webhooks,omitempty"`
 }
 
 // Aws related information
@@ -39,34 +67,110 @@ type Nuage struct {
 
 // Topology represents a lab topology
 type Topology struct {
-	Sites       map[string]SiteConfig   `yaml:"sites,omitempty"`
-	DeviceKinds map[string]DeviceConfig `yaml:"device-kinds,omitempty"`
-	Devices     map[string]DeviceConfig `yaml:"devices,omitempty"`
-	Connections []ConnectionConfig      `yaml:"connections,omitempty"`
+	Sites       map[string]SiteConfig       `yaml:"sites,omitempty"`
+	DeviceKinds map[string]DeviceConfig     `yaml:"device-kinds,omitempty"`
+	Devices     map[string]DeviceConfig     `yaml:"devices,omitempty"`
+	Connections []ConnectionConfig          `yaml:"connections,omitempty"`
+	IKEVersion  string                      `yaml:"ike-version,omitempty"`
+	Vpcs        map[string]VpcConfig        `yaml:"vpcs,omitempty"`
+	Peerings    []PeeringConfig             `yaml:"peerings,omitempty"`
+	RouteTables map[string]RouteTableConfig `yaml:"route-tables,omitempty"`
+}
+
+// RouteTableConfig represents a named TGW route table (segment) that is created on the tgws,
+// the attachments of the route tables in propagate-from propagate their routes into it
+type RouteTableConfig struct {
+	PropagateFrom []string           `yaml:"propagate-from,omitempty"`
+	Nuage         *NuageDomainConfig `yaml:"nuage,omitempty"`
+}
+
+// NuageDomainConfig represents the Nuage overlay domain that is mapped to a TGW route table,
+// the AWS cidrs of the segment are routed from the domain towards the connections of the segment
+type NuageDomainConfig struct {
+	Domain         string `yaml:"domain,omitempty"`
+	DomainTemplate string `yaml:"domain-template,omitempty"`
+	Zone           string `yaml:"zone,omitempty"`
+	Subnet         string `yaml:"subnet,omitempty"`
+	Routing        string `yaml:"routing,omitempty"`
+}
+
+// PeeringConfig represents a peering between 2 tgw devices, the first endpoint requests
+// the peering and the second endpoint accepts it
+type PeeringConfig struct {
+	Endpoints  []string `yaml:"endpoints,omitempty"`
+	RouteTable string   `yaml:"route-table,omitempty"`
+}
+
+// VpcConfig represents a VPC that is created or referenced (by id) and attached to a TGW
+type VpcConfig struct {
+	ID         string                  `yaml:"id,omitempty"`
+	Tgw        string                  `yaml:"tgw,omitempty"`
+	Cidr       string                  `yaml:"cidr,omitempty"`
+	Subnets    map[string]SubnetConfig `yaml:"subnets,omitempty"`
+	RouteTable string                  `yaml:"route-table,omitempty"`
+}
+
+// SubnetConfig represents a VPC subnet per availability zone, created or referenced (by id)
+type SubnetConfig struct {
+	ID   string `yaml:"id,omitempty"`
+	Cidr string `yaml:"cidr,omitempty"`
 }
 
 // DeviceConfig represents a configuration a given device can have
 type DeviceConfig struct {
-	Kind   string `yaml:"kind,omitempty"`
-	Vendor string `yaml:"vendor,omitempty"`
-	Model  string `yaml:"model,omitempty"`
-	Serial string `yaml:"serial,omitempty"`
-	Region string `yaml:"region,omitempty"`
+	Kind    string            `yaml:"kind,omitempty"`
+	Vendor  string            `yaml:"vendor,omitempty"`
+	Model   string            `yaml:"model,omitempty"`
+	Serial  string            `yaml:"serial,omitempty"`
+	Region  string            `yaml:"region,omitempty"`
+	Protect bool              `yaml:"protect,omitempty"`
+	Tags    map[string]string `yaml:"tags,omitempty"`
 }
 
 // ConnectionConfig struct
 type ConnectionConfig struct {
-	Endpoints []string
-	Labels    map[string]string `yaml:"labels,omitempty"`
+	Endpoints   []string
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Tunnels     []TunnelConfig    `yaml:"tunnels,omitempty"`
+	Accelerated bool              `yaml:"accelerated,omitempty"`
+	RouteTable  string            `yaml:"route-table,omitempty"`
+	Tags        map[string]string `yaml:"tags,omitempty"`
+}
+
+// TunnelConfig represents the AWS options of one of the 2 tunnels of a vpn connection
+type TunnelConfig struct {
+	InsideCidr       string `yaml:"inside-cidr,omitempty"`
+	DPDTimeout       int32  `yaml:"dpd-timeout,omitempty"`
+	DPDTimeoutAction string `yaml:"dpd-timeout-action,omitempty"`
+	RekeyMarginTime  int32  `yaml:"rekey-margin-time,omitempty"`
+	ReplayWindowSize int32  `yaml:"replay-window-size,omitempty"`
+	StartupAction    string `yaml:"startup-action,omitempty"`
 }
 
 // SiteConfig represents a configuration a given site can have
 type SiteConfig struct {
-	Street  string `yaml:"street,omitempty"`
-	Number  int    `yaml:"number,omitempty"`
-	City    string `yaml:"city,omitempty"`
-	State   string `yaml:"state,omitempty"`
-	Country string `yaml:"country,omitempty"`
+	Street     string            `yaml:"street,omitempty"`
+	Number     int               `yaml:"number,omitempty"`
+	City       string            `yaml:"city,omitempty"`
+	State      string            `yaml:"state,omitempty"`
+	Country    string            `yaml:"country,omitempty"`
+	Redundancy *RedundancyConfig `yaml:"redundancy,omitempty"`
+	Tags       map[string]string `yaml:"tags,omitempty"`
+}
+
+// RedundancyConfig represents a redundant NSG pair of a site, the NSGs are grouped in a
+// redundant gateway group and optionally connected through a shunt link
+type RedundancyConfig struct {
+	Group     string           `yaml:"group,omitempty"`
+	Devices   []string         `yaml:"devices,omitempty"`
+	ShuntLink *ShuntLinkConfig `yaml:"shunt-link,omitempty"`
+}
+
+// ShuntLinkConfig represents the shunt link between the NSGs of a redundant pair,
+// the ports are listed in the same order as the devices of the pair
+type ShuntLinkConfig struct {
+	Ports []string `yaml:"ports,omitempty"`
+	Vlan  int      `yaml:"vlan,omitempty"`
 }
 
 // GetTopology parses the topology file into c.Conf structure
@@ -97,7 +201,9 @@ func (nm *NMgr) ParseTopology() error {
 	nm.Sites = make(map[string]*Site)
 	nm.Devices = make(map[string]*Device)
 	nm.Connections = make(map[int]*Connection)
+	nm.Vpcs = make(map[string]*Vpc)
 	nm.ClientEC2 = make(map[string]*ec2.Client)
+	nm.identityEC2 = make(map[string]func() (*sts.GetCallerIdentityOutput, error))
 
 	// initialize the Site information from the topology file
 	idx := 0
@@ -112,17 +218,52 @@ func (nm *NMgr) ParseTopology() error {
 	// initialize the Device information from the topology file
 	idx = 0
 	for name, device := range nm.Config.Topology.Devices {
-		log.Debugf("Device info: %d, %s, %s", idx, name, device)
+		log.Debugf("Device info: %d, %s, %v", idx, name, device)
 
 		if err := nm.NewDevice(name, device, idx); err != nil {
 			return err
 		}
 		idx++
 	}
+	// initialize the VPC information from the topology file
+	for name, vpc := range nm.Config.Topology.Vpcs {
+		log.Debugf("VPC info: %s, %v", name, vpc)
+
+		if err := nm.NewVpc(name, vpc); err != nil {
+			return err
+		}
+	}
+	// initialize the TGW peering information from the topology file
+	for i, p := range nm.Config.Topology.Peerings {
+		log.Debugf("Peering info: %d, %v", i, p)
+
+		if err := nm.NewPeering(p); err != nil {
+			return err
+		}
+	}
 	for i, c := range nm.Config.Topology.Connections {
 		log.Debugf("Connection info: %d, %v", i, c)
 		// i represents the endpoint integer and c provide the connection struct
-		nm.Connections[i] = nm.NewConnection(c)
+		conn, err := nm.NewConnection(c)
+		if err != nil {
+			return err
+		}
+		nm.Connections[i] = conn
+	}
+	if err := nm.validateRedundancy(); err != nil {
+		return err
+	}
+	if err := nm.validateTunnels(); err != nil {
+		return err
+	}
+	if err := nm.validateRouteTables(); err != nil {
+		return err
+	}
+	if err := nm.mergeTopologyTags(); err != nil {
+		return err
+	}
+	if err := nm.validateWebhooks(); err != nil {
+		return err
 	}
 	return nil
 }
@@ -138,6 +279,13 @@ func (nm *NMgr) NewSite(name string, cfg SiteConfig, idx int) error {
 	s.City = cfg.City
 	s.State = cfg.State
 	s.Country = cfg.Country
+	if cfg.Redundancy != nil {
+		s.RedundantGroup = cfg.Redundancy.Group
+		if s.RedundantGroup == "" {
+			s.RedundantGroup = name + "-rgg"
+		}
+		s.ShuntLink = cfg.Redundancy.ShuntLink
+	}
 
 	s.Devices = make(map[string]*Device)
 	s.Endpoints = make(map[string]*Endpoint)
@@ -153,6 +301,7 @@ func (nm *NMgr) NewDevice(name string, cfg DeviceConfig, idx int) error {
 
 	d.Name = name
 	d.Kind = cfg.Kind
+	d.Protect = cfg.Protect
 
 	switch d.Kind {
 	case "sdwan":
@@ -161,13 +310,9 @@ func (nm *NMgr) NewDevice(name string, cfg DeviceConfig, idx int) error {
 		d.Model = cfg.Model
 	case "tgw":
 		d.Region = cfg.Region
-		cfg, err := config.LoadDefaultConfig(
-			config.WithRegion(cfg.Region),
-			config.WithSharedConfigProfile("admin"))
-		if err != nil {
-			panic("failed to load config, " + err.Error())
+		if err := nm.newEC2Client(cfg.Region); err != nil {
+			return err
 		}
-		nm.ClientEC2[cfg.Region] = ec2.NewFromConfig(cfg)
 
 	default:
 		return fmt.Errorf("Node '%s' refers to a kind '%s' which is not supported. Supported kinds are %q", name, d.Kind, kinds)
@@ -180,8 +325,94 @@ func (nm *NMgr) NewDevice(name string, cfg DeviceConfig, idx int) error {
 	return nil
 }
 
+// newEC2Client initializes the EC2 client of the region
+func (nm *NMgr) newEC2Client(region string) error {
+	if _, ok := nm.ClientEC2[region]; ok {
+		return nil
+	}
+	cfg, err := config.LoadDefaultConfig(
+		config.WithRegion(region),
+		config.WithSharedConfigProfile("admin"),
+		config.WithRetryer(nm.newAWSRetryer()))
+	if err != nil {
+		return fmt.Errorf("failed to load config of region %s, %s", region, err)
+	}
+	nm.addRunIDUserAgent(&cfg)
+	nm.addAPIErrorCounter(&cfg)
+	nm.identityEC2[region] = nm.awsIdentity(cfg)
+	nm.addAuditLog(&cfg, nm.identityEC2[region])
+	nm.ClientEC2[region] = ec2.NewFromConfig(cfg)
+	return nil
+}
+
+// NewVpc initializes a new vpc object
+func (nm *NMgr) NewVpc(name string, cfg VpcConfig) error {
+	v := new(Vpc)
+	v.Name = name
+	v.ID = cfg.ID
+	v.Cidr = cfg.Cidr
+
+	d, ok := nm.Devices[cfg.Tgw]
+	if !ok || d.Kind != "tgw" {
+		return fmt.Errorf("VPC '%s' refers to a tgw '%s' which is not specified in the 'topology.devices' section", name, cfg.Tgw)
+	}
+	v.Tgw = d
+	v.Region = d.Region
+	v.AttachmentName = name + "-" + cfg.Tgw
+	v.RouteTable = cfg.RouteTable
+
+	if v.ID == "" && v.Cidr == "" {
+		return fmt.Errorf("VPC '%s' needs an id or a cidr", name)
+	}
+	if len(cfg.Subnets) == 0 {
+		return fmt.Errorf("VPC '%s' needs at least 1 subnet to attach to the tgw", name)
+	}
+	v.Subnets = make(map[string]*Subnet)
+	for az, subnet := range cfg.Subnets {
+		if subnet.ID == "" && subnet.Cidr == "" {
+			return fmt.Errorf("VPC '%s' subnet in '%s' needs an id or a cidr", name, az)
+		}
+		v.Subnets[az] = &Subnet{
+			Name: name + "-" + az,
+			ID:   subnet.ID,
+			Cidr: subnet.Cidr,
+			AZ:   az,
+		}
+	}
+
+	nm.Vpcs[name] = v
+	return nil
+}
+
+// NewPeering initializes a new tgw peering object
+func (nm *NMgr) NewPeering(cfg PeeringConfig) error {
+	if len(cfg.Endpoints) != 2 {
+		return fmt.Errorf("peering %v needs 2 endpoints", cfg.Endpoints)
+	}
+	p := new(Peering)
+	for i, e := range cfg.Endpoints {
+		d, ok := nm.Devices[e]
+		if !ok || d.Kind != "tgw" {
+			return fmt.Errorf("peering %v refers to a tgw '%s' which is not specified in the 'topology.devices' section", cfg.Endpoints, e)
+		}
+		if i == 0 {
+			p.A = d
+		} else {
+			p.B = d
+		}
+	}
+	if p.A == p.B {
+		return fmt.Errorf("peering %v needs 2 different tgw devices", cfg.Endpoints)
+	}
+	p.Name = p.A.Name + "-" + p.B.Name
+	p.RouteTable = cfg.RouteTable
+
+	nm.Peerings = append(nm.Peerings, p)
+	return nil
+}
+
 // NewConnection initializes a new link object
-func (nm *NMgr) NewConnection(cCfg ConnectionConfig) *Connection {
+func (nm *NMgr) NewConnection(cCfg ConnectionConfig) (*Connection, error) {
 	// initialize a new link
 	c := new(Connection)
 	c.Labels = cCfg.Labels
@@ -189,21 +420,196 @@ func (nm *NMgr) NewConnection(cCfg ConnectionConfig) *Connection {
 	for i, d := range cCfg.Endpoints {
 		// i indicates the number and d presents the string, which need to be
 		// split in node and endpoint name
+		ep, err := nm.NewEndpoint(i, d, c.Labels)
+		if err != nil {
+			return nil, err
+		}
 		if i == 0 {
-			c.A = nm.NewEndpoint(i, d, c.Labels)
+			c.A = ep
 		} else {
-			c.B = nm.NewEndpoint(i, d, c.Labels)
+			c.B = ep
 		}
 	}
 	// map the region from link B to link A
 	if c.B.Device.Kind == "tgw" {
 		c.A.Region = c.B.Region
 	}
-	return c
+	c.A.Tunnels = cCfg.Tunnels
+	c.A.Accelerated = cCfg.Accelerated
+	c.A.RouteTable = cCfg.RouteTable
+	c.A.Tags = cCfg.Tags
+	return c, nil
+}
+
+// validateRedundancy checks the redundant NSG pairs of the sites and adds both NSGs
+// to the site, each NSG of a pair needs its own connection towards a tgw
+func (nm *NMgr) validateRedundancy() error {
+	for siteName, site := range nm.Config.Topology.Sites {
+		if site.Redundancy == nil {
+			continue
+		}
+		s := nm.Sites[siteName]
+		if len(site.Redundancy.Devices) != 2 || site.Redundancy.Devices[0] == site.Redundancy.Devices[1] {
+			return fmt.Errorf("site '%s' needs 2 different devices for redundancy", siteName)
+		}
+		if s.ShuntLink != nil && len(s.ShuntLink.Ports) != 2 {
+			return fmt.Errorf("site '%s' needs a shunt link port for each redundant device", siteName)
+		}
+		for _, deviceName := range site.Redundancy.Devices {
+			d, ok := nm.Devices[deviceName]
+			if !ok || d.Kind != "sdwan" {
+				return fmt.Errorf("site '%s' refers to a redundant device '%s' which is not specified as sdwan device in the 'topology.devices' section", siteName, deviceName)
+			}
+			if d.Site.Name != "" && d.Site != s {
+				return fmt.Errorf("redundant device '%s' of site '%s' is connected in site '%s'", deviceName, siteName, d.Site.Name)
+			}
+			connected := false
+			for i := 0; i < len(nm.Connections); i++ {
+				if conn := nm.Connections[i]; conn.A.Device == d && conn.B.Device.Kind == "tgw" {
+					connected = true
+				}
+			}
+			if !connected {
+				return fmt.Errorf("redundant device '%s' of site '%s' has no connection to a tgw", deviceName, siteName)
+			}
+			d.Site = s
+			s.Devices[deviceName] = d
+			s.RedundantDevices = append(s.RedundantDevices, d)
+		}
+	}
+	return nil
+}
+
+// validateTunnels validates the tunnel options of the connections, the inside
+// cidrs need to be unique across all connections
+func (nm *NMgr) validateTunnels() error {
+	_, linkLocal, _ := net.ParseCIDR("169.254.0.0/16")
+	insideCidrs := make(map[string]string)
+	for i := 0; i < len(nm.Connections); i++ {
+		ep := nm.Connections[i].A
+		if len(ep.Tunnels) > 2 {
+			return fmt.Errorf("connection '%s' has %d tunnels, a vpn connection supports 2 tunnels", ep.Name, len(ep.Tunnels))
+		}
+		for j, t := range ep.Tunnels {
+			if t.InsideCidr != "" {
+				ip, n, err := net.ParseCIDR(t.InsideCidr)
+				if err != nil {
+					return fmt.Errorf("connection '%s' tunnel %d: %s", ep.Name, j, err)
+				}
+				if ones, _ := n.Mask.Size(); ones != 30 || !linkLocal.Contains(ip) {
+					return fmt.Errorf("connection '%s' tunnel %d: inside-cidr '%s' is not a /30 in 169.254.0.0/16", ep.Name, j, t.InsideCidr)
+				}
+				for _, r := range reservedInsideCidrs {
+					if n.String() == r {
+						return fmt.Errorf("connection '%s' tunnel %d: inside-cidr '%s' is reserved by AWS", ep.Name, j, t.InsideCidr)
+					}
+				}
+				if other, ok := insideCidrs[n.String()]; ok {
+					return fmt.Errorf("connection '%s' tunnel %d: inside-cidr '%s' is already used by %s", ep.Name, j, t.InsideCidr, other)
+				}
+				insideCidrs[n.String()] = fmt.Sprintf("connection '%s' tunnel %d", ep.Name, j)
+			}
+			if t.DPDTimeoutAction != "" && !contains(dpdTimeoutActions, t.DPDTimeoutAction) {
+				return fmt.Errorf("connection '%s' tunnel %d: dpd-timeout-action '%s' is not supported. Supported actions are %q", ep.Name, j, t.DPDTimeoutAction, dpdTimeoutActions)
+			}
+			if t.StartupAction != "" && !contains(startupActions, t.StartupAction) {
+				return fmt.Errorf("connection '%s' tunnel %d: startup-action '%s' is not supported. Supported actions are %q", ep.Name, j, t.StartupAction, startupActions)
+			}
+			if t.RekeyMarginTime != 0 && (t.RekeyMarginTime < 60 || t.RekeyMarginTime > phase2Lifetime/2) {
+				return fmt.Errorf("connection '%s' tunnel %d: rekey-margin-time needs to be between 60 and %d seconds, half of the phase 2 lifetime", ep.Name, j, phase2Lifetime/2)
+			}
+			if t.DPDTimeout != 0 && t.DPDTimeout < 30 {
+				return fmt.Errorf("connection '%s' tunnel %d: dpd-timeout needs to be 30 seconds or higher", ep.Name, j)
+			}
+			if t.ReplayWindowSize != 0 && (t.ReplayWindowSize < 64 || t.ReplayWindowSize > 2048) {
+				return fmt.Errorf("connection '%s' tunnel %d: replay-window-size needs to be between 64 and 2048", ep.Name, j)
+			}
+		}
+	}
+	return nil
+}
+
+// validateRouteTables validates that the route tables referenced by the connections,
+// VPCs, peerings and propagation rules exist in the topology
+func (nm *NMgr) validateRouteTables() error {
+	rts := nm.Config.Topology.RouteTables
+	exists := func(name string) bool {
+		_, ok := rts[name]
+		return name == "" || ok
+	}
+	for name, rt := range rts {
+		for _, p := range rt.PropagateFrom {
+			if !exists(p) {
+				return fmt.Errorf("route table '%s' propagates from a route table '%s' which is not specified in the 'topology.route-tables' section", name, p)
+			}
+		}
+		if rt.Nuage != nil {
+			if rt.Nuage.Domain == "" {
+				return fmt.Errorf("route table '%s' needs a nuage domain", name)
+			}
+			if rt.Nuage.Routing == "" {
+				rt.Nuage.Routing = "static"
+			}
+			if !contains(nuageRoutings, rt.Nuage.Routing) {
+				return fmt.Errorf("route table '%s' refers to a nuage routing '%s' which is not supported. Supported routings are %q", name, rt.Nuage.Routing, nuageRoutings)
+			}
+			if rt.Nuage.Routing == "bgp" && (rt.Nuage.Zone == "" || rt.Nuage.Subnet == "") {
+				return fmt.Errorf("route table '%s' needs a nuage zone and subnet for bgp routing", name)
+			}
+		}
+	}
+	// the tgws have no default route table association and propagation with route tables,
+	// every attachment needs a route table
+	required := func(kind, name, rtName string) error {
+		if len(rts) > 0 && rtName == "" {
+			return fmt.Errorf("%s '%s' needs a route table, the transit GWs have no default route table when 'topology.route-tables' is set", kind, name)
+		}
+		return nil
+	}
+	for i := 0; i < len(nm.Connections); i++ {
+		ep := nm.Connections[i].A
+		if !exists(ep.RouteTable) {
+			return fmt.Errorf("connection '%s' refers to a route table '%s' which is not specified in the 'topology.route-tables' section", ep.Name, ep.RouteTable)
+		}
+		if ep.Device.Kind == "sdwan" && nm.Connections[i].B.Device.Kind == "tgw" {
+			if err := required("connection", ep.Name, ep.RouteTable); err != nil {
+				return err
+			}
+		}
+		if rt, ok := rts[ep.RouteTable]; ok && rt.Nuage != nil && rt.Nuage.Routing == "bgp" {
+			ep.BGP = true
+		}
+	}
+	for name, vpc := range nm.Vpcs {
+		if !exists(vpc.RouteTable) {
+			return fmt.Errorf("VPC '%s' refers to a route table '%s' which is not specified in the 'topology.route-tables' section", name, vpc.RouteTable)
+		}
+		if err := required("VPC", name, vpc.RouteTable); err != nil {
+			return err
+		}
+	}
+	for _, p := range nm.Peerings {
+		if !exists(p.RouteTable) {
+			return fmt.Errorf("peering '%s' refers to a route table '%s' which is not specified in the 'topology.route-tables' section", p.Name, p.RouteTable)
+		}
+		if err := required("peering", p.Name, p.RouteTable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func contains(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
 
 // NewEndpoint initializes a new endpoint object
-func (nm *NMgr) NewEndpoint(i int, e string, l map[string]string) *Endpoint {
+func (nm *NMgr) NewEndpoint(i int, e string, l map[string]string) (*Endpoint, error) {
 	// initialize a new endpoint
 	endpoint := new(Endpoint)
 
@@ -217,6 +623,11 @@ func (nm *NMgr) NewEndpoint(i int, e string, l map[string]string) *Endpoint {
 		siteName = split[0]   // site name
 		deviceName = split[1] // device name
 		epName = split[2]     // endpoint name
+		// the endpoint is named by its port on the NSG, unless the port label is set
+		endpoint.Port = epName
+		if _, ok := l["port"]; ok {
+			endpoint.Port = l["port"]
+		}
 		if _, ok := l["provider"]; ok {
 			endpoint.Provider = l["provider"]
 		}
@@ -250,15 +661,31 @@ func (nm *NMgr) NewEndpoint(i int, e string, l map[string]string) *Endpoint {
 		if _, ok := l["cidr"]; ok {
 			endpoint.Cidr = l["cidr"]
 		}
+		if _, ok := l["vlan"]; ok {
+			vlan, err := strconv.Atoi(l["vlan"])
+			if err != nil || vlan < 0 || vlan > 4094 {
+				return nil, fmt.Errorf("endpoint %s: vlan '%s' is not a valid vlan id", e, l["vlan"])
+			}
+			endpoint.Vlan = vlan
+		}
+		ikeVersion := nm.Config.Topology.IKEVersion
+		if _, ok := l["ike-version"]; ok {
+			ikeVersion = l["ike-version"]
+		}
+		v, err := parseIKEVersion(ikeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("endpoint %s: %s", e, err)
+		}
+		endpoint.IKEVersion = v
 	case 1: // transit gateway
 		if i == 1 {
 			deviceName = e
 			epName = e
 		} else {
-			log.Fatalf("endpoint %s has wrong syntax", e)
+			return nil, fmt.Errorf("endpoint %s has wrong syntax", e)
 		}
 	default:
-		log.Fatalf("endpoint %s has wrong syntax", e)
+		return nil, fmt.Errorf("endpoint %s has wrong syntax", e)
 	}
 
 	for name, s := range nm.Sites {
@@ -278,7 +705,7 @@ func (nm *NMgr) NewEndpoint(i int, e string, l map[string]string) *Endpoint {
 	}
 
 	if endpoint.Device == nil {
-		log.Fatalf("Not all nodes are specified in the 'topology.nodes' section or the names don't match in the 'links.endpoints' section: %s", deviceName)
+		return nil, fmt.Errorf("Not all nodes are specified in the 'topology.nodes' section or the names don't match in the 'links.endpoints' section: %s", deviceName)
 	}
 	log.Debugf("Endpoints Info: %s, %s, %s", siteName, deviceName, epName)
 
@@ -289,284 +716,1583 @@ func (nm *NMgr) NewEndpoint(i int, e string, l map[string]string) *Endpoint {
 	}
 	endpoint.Device.Endpoints[epName] = endpoint
 
-	return endpoint
+	return endpoint, nil
 }
 
-// CreateAWSNetworkMgrNetwork function
-func (nm *NMgr) CreateAWSNetworkMgrNetwork() error {
-	log.Infof("Create Global Network: %s", nm.Config.Name)
-	respNetw, err := nm.CreateGlobalNetwork(&nm.Config.Name)
-	if err != nil {
-		log.Fatal(err)
+// parseIKEVersion normalizes the IKE version to the VSD notation (V1, V2),
+// an empty version returns the default IKE version
+func parseIKEVersion(v string) (string, error) {
+	if v == "" {
+		return defaultIKEVersion, nil
 	}
-	log.Infof("Global Network Id: %v", *respNetw.GlobalNetwork.GlobalNetworkId)
-	nm.GlobalNetworkID = respNetw.GlobalNetwork.GlobalNetworkId
-
-	for deviceName, device := range nm.Devices {
-		switch device.Kind {
-		case "tgw":
-			log.Infof("Create TGW: %s", deviceName)
-			r, err := nm.CreateTransitGateway(&device.Region, &deviceName)
-			if err != nil {
-				log.Fatalf("Error create device: %s", err)
-			}
-			log.Infof("Device Id: %v", *r.TransitGateway.TransitGatewayId)
-			device.DeviceID = r.TransitGateway.TransitGatewayId
-			device.DeviceARN = r.TransitGateway.TransitGatewayArn
-			_, err = nm.RegisterTransitGateway(device.DeviceARN)
-			if err != nil {
-				log.Errorf("Error associating TGW: %s", err)
-			}
-
+	n := strings.ToUpper(v)
+	n = strings.TrimPrefix(n, "IKE")
+	n = strings.TrimPrefix(n, "V")
+	n = "V" + n
+	for _, k := range ikeVersions {
+		if n == k {
+			return n, nil
 		}
 	}
-	return nil
+	return "", fmt.Errorf("ike-version '%s' is not supported. Supported versions are %q", v, ikeVersions)
 }
 
-// DeleteAWSNetworkMgrNetwork function
-func (nm *NMgr) DeleteAWSNetworkMgrNetwork() error {
-	r, err := nm.DescribeGlobalNetworks()
-	if err != nil {
-		log.Fatal(err)
+// branchCidrs returns the unique cidrs of the sdwan sites connected to the tgw
+func (nm *NMgr) branchCidrs(tgw *Device) []string {
+	var cidrs []string
+	for i := 0; i < len(nm.Connections); i++ {
+		conn := nm.Connections[i]
+		if conn.B.Device == tgw && conn.A.Cidr != "" && !contains(cidrs, conn.A.Cidr) {
+			cidrs = append(cidrs, conn.A.Cidr)
+		}
 	}
+	return cidrs
+}
 
-	//if len(r.GlobalNetworks) > 0 {
-	for idx, g := range r.GlobalNetworks {
-		for i := 0; i < len(g.Tags); i++ {
-			if *g.Tags[i].Key == "Name" {
-				if *g.Tags[i].Value == nm.Config.Name {
-					log.Infof("Global Network found")
-					nm.GlobalNetworkID = r.GlobalNetworks[idx].GlobalNetworkId
-				}
-			}
+// tgwCidrs returns the unique cidrs behind the tgw, the branch cidrs and the cidrs of the
+// attached VPCs
+func (nm *NMgr) tgwCidrs(tgw *Device) []string {
+	cidrs := nm.branchCidrs(tgw)
+	for _, vpc := range nm.Vpcs {
+		if vpc.Tgw == tgw && vpc.Cidr != "" && !contains(cidrs, vpc.Cidr) {
+			cidrs = append(cidrs, vpc.Cidr)
 		}
 	}
-	if nm.GlobalNetworkID != nil {
-		for deviceName, device := range nm.Devices {
-			switch device.Kind {
-			case "tgw":
-				r, err := nm.GetTransitGatewayRegistrations()
-				if err != nil {
-					log.Error(err)
-				}
-				for _, t := range r.TransitGatewayRegistrations {
-					log.Infof("Deregister Transit Gateway....")
-					_, err = nm.DeregisterTransitGateway(t.TransitGatewayArn)
-					if err != nil {
-						log.Error(err)
-					}
-				}
-				log.Infof("Delete Transit Gateway....")
-				tgws, err := nm.DescribeTransitGateways(&device.Region, &deviceName)
-				if err != nil {
-					log.Error(err)
-				}
-				for _, t := range tgws.TransitGateways {
-					_, err = nm.DeleteTransitGateway(&device.Region, t.TransitGatewayId)
-					if err != nil {
-						log.Fatal(err)
-					}
-				}	
-			}
-		}
-		time.Sleep(30 * time.Second)
+	return cidrs
+}
 
-		log.Infof("Delete Global Network....")
-		if _, err := nm.DeleteGlobalNetwork(); err != nil {
-			log.Errorf("Error deleting Global Network: %s", err)
+// peerTgws returns the tgws that are peered with the tgw
+func (nm *NMgr) peerTgws(tgw *Device) []*Device {
+	var peers []*Device
+	for _, p := range nm.Peerings {
+		if p.A == tgw {
+			peers = append(peers, p.B)
+		}
+		if p.B == tgw {
+			peers = append(peers, p.A)
 		}
-	} else {
-		log.Infof("Nothing to delete....")
 	}
-
-	return nil
+	return peers
 }
 
-// CreateAWSNetworkMgrSites function
-func (nm *NMgr) CreateAWSNetworkMgrSites() error {
-	log.Infof("Add sites to Global Network: %s", nm.Config.Name)
-	respNetw, err := nm.CreateGlobalNetwork(&nm.Config.Name)
-	if err != nil {
-		log.Fatal(err)
+// vpcRouteCidrs returns the branch cidrs that are routed from a VPC towards the tgw,
+// including the branch cidrs of the peered tgws
+func (nm *NMgr) vpcRouteCidrs(tgw *Device) []string {
+	cidrs := nm.branchCidrs(tgw)
+	for _, peer := range nm.peerTgws(tgw) {
+		for _, cidr := range nm.branchCidrs(peer) {
+			if !contains(cidrs, cidr) {
+				cidrs = append(cidrs, cidr)
+			}
+		}
 	}
-	log.Debugf("Global Network Id: %v", *respNetw.GlobalNetwork.GlobalNetworkId)
-	nm.GlobalNetworkID = respNetw.GlobalNetwork.GlobalNetworkId
+	return cidrs
+}
 
-	enterprise := nm.getEnterprise(nm.Config.Nuage.Enterprise)
-	if enterprise == nil {
-		log.Errorf("Enterprise does not exist : %s", nm.Config.Nuage.Enterprise)
+// findTransitGateway looks up the tgw device in AWS and initializes the id, arn, owner
+// and default route table of the device, deleted tgws are ignored
+func (nm *NMgr) findTransitGateway(d *Device) (bool, error) {
+	r, err := nm.DescribeTransitGateways(&d.Region, &d.Name)
+	if err != nil {
+		return false, err
 	}
-	log.Debugf("Enterprise ID : %v", enterprise.ID)
-
-	ikePSK := nm.createIKEPSK("AWS-"+nm.Config.Name+"PSK", enterprise)
-	log.Debugf("ikePSK: %v", ikePSK.ID)
-
-	ikeEncryptionProfile := nm.createIKEEncryptionprofile("AWS-"+nm.Config.Name, enterprise)
-	log.Debugf("ikeEncryptionProfile: %v", ikeEncryptionProfile)
-
-	for siteName, site := range nm.Sites {
-		log.Infof("Create Site: %s", siteName)
-		r, err := nm.CreateSite(&siteName, site)
-		if err != nil {
-			log.Fatalf("Error create site: %s", err)
+	for i, t := range r.TransitGateways {
+		if t.State == types.TransitGatewayStateDeleted || t.State == types.TransitGatewayStateDeleting {
+			continue
 		}
-		log.Debugf("Site Id: %v", *r.Site.SiteId)
-		site.SiteID = r.Site.SiteId
+		nm.setTransitGateway(d, &r.TransitGateways[i])
+		return true, nil
 	}
+	return false, nil
+}
 
-	for deviceName, device := range nm.Devices {
-		switch device.Kind {
-		case "sdwan":
-			log.Infof("Create Device: %s", deviceName)
+// setTransitGateway initializes the tgw device with the AWS tgw information
+func (nm *NMgr) setTransitGateway(d *Device, t *types.TransitGateway) {
+	d.DeviceID = t.TransitGatewayId
+	d.DeviceARN = t.TransitGatewayArn
+	d.OwnerID = t.OwnerId
+	if t.Options != nil {
+		d.RouteTableID = t.Options.AssociationDefaultRouteTableId
+		d.PropagationRTID = t.Options.PropagationDefaultRouteTableId
+	}
+}
 
-			nsGateway := nm.getNsg(deviceName, enterprise)
-			if nsGateway == nil {
-				log.Errorf("Nuage NSG device does not exist: %s", deviceName)
+// CreateAWSVpcs creates or references the VPCs and subnets, attaches them to the tgw
+// and adds routes for the branch cidrs towards the tgw in the VPC route tables
+func (nm *NMgr) CreateAWSVpcs() error {
+	for vpcName, vpc := range nm.Vpcs {
+		if vpc.Tgw.DeviceID == nil {
+			return fmt.Errorf("transit GW %s of VPC %s not found", vpc.Tgw.Name, vpcName)
+		}
+		if vpc.ID != "" {
+			awsLog(vpc.Region, &vpc.ID).Infof("Find VPC: %s %s", vpcName, vpc.ID)
+			r, err := nm.DescribeVpc(&vpc.Region, &vpc.ID)
+			if err != nil {
+				return err
 			}
-			log.Debugf("Nuage NSG ID: %s", nsGateway.ID)
-			device.NuageNSGateway = nsGateway
-
-			r, err := nm.CreateDevice(&deviceName, device)
+			if len(r.Vpcs) == 0 {
+				return fmt.Errorf("VPC %s not found: %s", vpcName, vpc.ID)
+			}
+			vpc.VpcID = r.Vpcs[0].VpcId
+			if vpc.Cidr == "" && r.Vpcs[0].CidrBlock != nil {
+				vpc.Cidr = *r.Vpcs[0].CidrBlock
+			}
+		} else {
+			log.WithField("region", vpc.Region).Infof("Create VPC: %s %s %s", vpc.Region, vpcName, vpc.Cidr)
+			r, err := nm.CreateVpc(&vpc.Region, &vpcName, &vpc.Cidr)
 			if err != nil {
-				log.Fatalf("Error create device: %s", err)
+				return err
 			}
-			log.Debugf("Device Id: %v", *r.Device.DeviceId)
-			device.DeviceID = r.Device.DeviceId
-			device.DeviceARN = r.Device.DeviceArn
-			for epName, ep := range device.Endpoints {
-
-				nsgPort := nm.getNetworkPort(epName, nsGateway)
-				if nsgPort == nil {
-					log.Errorf("Nuage NSG port does not exist: %s", epName)
-				}
-				log.Debugf("Nuage PORT: %s", nsgPort.ID)
-				ep.NuagePort = nsgPort
-
-				nsVlan := nm.getVlan(0, nsgPort)
-				if nsVlan == nil {
-					log.Errorf("Nuage NSG vlan does not exist: 0")
-				}
-				log.Debugf("Nuage VLAN: %s", nsVlan.ID)
-				ep.NuageVlan = nsVlan
+			vpc.VpcID = r.Vpc.VpcId
+		}
+		log.Debugf("VPC Id: %s", *vpc.VpcID)
 
-				r, err := nm.CreateLink(&epName, ep)
-				if err != nil {
-					log.Fatalf("Error create link: %s", err)
-				}
-				log.Debugf("Link Id: %v", *r.Link.LinkId)
-				ep.LinkID = r.Link.LinkId
-				_, err = nm.AssociateLink(device.DeviceID, ep.LinkID)
+		var subnetIDs []string
+		for az, subnet := range vpc.Subnets {
+			if subnet.ID != "" {
+				subnet.SubnetID = &subnet.ID
+			} else {
+				log.Infof("Create Subnet: %s %s %s", az, subnet.Name, subnet.Cidr)
+				r, err := nm.CreateSubnet(&vpc.Region, &subnet.Name, vpc.VpcID, &subnet.AZ, &subnet.Cidr)
 				if err != nil {
-					log.Fatalf("Error create link: %s", err)
+					return err
 				}
+				subnet.SubnetID = r.Subnet.SubnetId
 			}
-		case "tgw":
-			log.Infof("Find TGW: %s", deviceName)
+			log.Debugf("Subnet Id: %s", *subnet.SubnetID)
+			subnetIDs = append(subnetIDs, *subnet.SubnetID)
+		}
 
-			state := false
-			found := false
-			i := 1
-			for ok := true; ok; ok = !state {
-				r, err := nm.DescribeTransitGateways(&device.Region, &deviceName)
-				if err != nil {
-					log.Fatalf("Error create device: %s", err)
-				}
-				if len(r.TransitGateways) == 0 {
-					log.Errorf("No transit GWs found, first 'awsnuagenetwmgr run deploy tgw -c <confi-file'")
-					return nil
-				}
+		awsLog(vpc.Region, vpc.VpcID).Infof("Create Transit GW VPC attachment: %s", vpc.AttachmentName)
+		r, err := nm.CreateTransitGatewayVpcAttachment(&vpc.Region, &vpc.AttachmentName, vpc.Tgw.DeviceID, vpc.VpcID, subnetIDs)
+		if err != nil {
+			return err
+		}
+		vpc.AttachmentID = r.TransitGatewayVpcAttachment.TransitGatewayAttachmentId
+		log.Debugf("Transit GW VPC attachment Id: %s", *vpc.AttachmentID)
+	}
+
+	for vpcName, vpc := range nm.Vpcs {
+		if err := nm.waitTransitGatewayVpcAttachment(vpc, types.TransitGatewayAttachmentStateAvailable); err != nil {
+			return err
+		}
+		if err := nm.associateRouteTable(vpc.Tgw, vpc.AttachmentID, vpc.RouteTable, true); err != nil {
+			return err
+		}
+
+		r, err := nm.DescribeRouteTables(&vpc.Region, vpc.VpcID)
+		if err != nil {
+			return err
+		}
+		for _, rt := range r.RouteTables {
+			for _, cidr := range nm.vpcRouteCidrs(vpc.Tgw) {
+				cidr := cidr
+				if routeExists(rt, cidr, *vpc.Tgw.DeviceID) {
+					continue
+				}
+				log.Infof("Create route in VPC %s: %s %s -> %s", vpcName, *rt.RouteTableId, cidr, *vpc.Tgw.DeviceID)
+				if _, err := nm.CreateTransitGatewayRoute(&vpc.Region, rt.RouteTableId, &cidr, vpc.Tgw.DeviceID); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteAWSVpcs deletes the routes towards the tgw and the tgw VPC attachments,
+// the VPCs and subnets are only deleted when they are not referenced by id
+func (nm *NMgr) DeleteAWSVpcs() error {
+	for vpcName, vpc := range nm.Vpcs {
+		if _, err := nm.findTransitGateway(vpc.Tgw); err != nil {
+			return err
+		}
+
+		if vpc.ID != "" {
+			vpc.VpcID = &vpc.ID
+		} else {
+			r, err := nm.DescribeVpcs(&vpc.Region, &vpcName)
+			if err != nil {
+				return err
+			}
+			for _, v := range r.Vpcs {
+				vpc.VpcID = v.VpcId
+			}
+		}
+		if vpc.VpcID == nil {
+			log.Infof("VPC not found: %s", vpcName)
+			continue
+		}
+
+		if vpc.Tgw.DeviceID != nil {
+			r, err := nm.DescribeRouteTables(&vpc.Region, vpc.VpcID)
+			if err != nil {
+				return err
+			}
+			for _, rt := range r.RouteTables {
+				for _, cidr := range nm.vpcRouteCidrs(vpc.Tgw) {
+					cidr := cidr
+					if routeExists(rt, cidr, *vpc.Tgw.DeviceID) {
+						log.Infof("Delete route in VPC %s: %s %s", vpcName, *rt.RouteTableId, cidr)
+						if _, err := nm.DeleteRoute(&vpc.Region, rt.RouteTableId, &cidr); err != nil {
+							log.Error(err)
+						}
+					}
+				}
+			}
+		}
+
+		r, err := nm.DescribeTransitGatewayVpcAttachments(&vpc.Region, &vpc.AttachmentName)
+		if err != nil {
+			return err
+		}
+		for _, a := range r.TransitGatewayVpcAttachments {
+			if a.State == types.TransitGatewayAttachmentStateDeleted || a.State == types.TransitGatewayAttachmentStateDeleting {
+				continue
+			}
+			awsLog(vpc.Region, a.TransitGatewayAttachmentId).Infof("Delete Transit GW VPC attachment: %s", *a.TransitGatewayAttachmentId)
+			if _, err := nm.DeleteTransitGatewayVpcAttachment(&vpc.Region, a.TransitGatewayAttachmentId); err != nil {
+				return err
+			}
+		}
+		if err := nm.waitTransitGatewayVpcAttachment(vpc, types.TransitGatewayAttachmentStateDeleted); err != nil {
+			return err
+		}
+
+		for _, subnet := range vpc.Subnets {
+			if subnet.ID != "" {
+				continue
+			}
+			r, err := nm.DescribeSubnets(&vpc.Region, &subnet.Name)
+			if err != nil {
+				return err
+			}
+			for _, sn := range r.Subnets {
+				log.Infof("Delete Subnet: %s", *sn.SubnetId)
+				if _, err := nm.DeleteSubnet(&vpc.Region, sn.SubnetId); err != nil {
+					log.Error(err)
+				}
+			}
+		}
+		if vpc.ID == "" {
+			awsLog(vpc.Region, vpc.VpcID).Infof("Delete VPC: %s", *vpc.VpcID)
+			if _, err := nm.DeleteVpc(&vpc.Region, vpc.VpcID); err != nil {
+				log.Error(err)
+			}
+		}
+	}
+	return nil
+}
+
+// waitTransitGatewayVpcAttachment waits till the tgw VPC attachment reaches the state,
+// a deleted attachment is also considered when the attachment no longer exists
+func (nm *NMgr) waitTransitGatewayVpcAttachment(vpc *Vpc, state types.TransitGatewayAttachmentState) error {
+	what := "tgw vpc attachment " + string(state) + " " + vpc.AttachmentName
+	start := time.Now()
+	defer nm.trackWait(what, start)
+	i := 0
+	for {
+		r, err := nm.DescribeTransitGatewayVpcAttachments(&vpc.Region, &vpc.AttachmentName)
+		if err != nil {
+			return err
+		}
+		done := true
+		for _, a := range r.TransitGatewayVpcAttachments {
+			if state == types.TransitGatewayAttachmentStateDeleted {
+				if a.State != types.TransitGatewayAttachmentStateDeleted {
+					done = false
+				}
+			} else if a.State == types.TransitGatewayAttachmentStateDeleted || a.State == types.TransitGatewayAttachmentStateDeleting {
+				continue
+			} else if a.State != state {
+				done = false
+			}
+		}
+		if done {
+			return nil
+		}
+		if err := nm.waitExpired(what, start); err != nil {
+			return err
+		}
+		i++
+		log.Infof("Wait 30 seconds to check if the VPC attachment %s is in %s state, total (%d sec)", vpc.AttachmentName, state, i*30)
+		time.Sleep(30 * time.Second)
+	}
+}
+
+// CreateAWSPeerings creates the tgw peering attachments, accepts them in the peer region,
+// adds static routes for the remote cidrs and registers the peered tgws in the global network
+func (nm *NMgr) CreateAWSPeerings() error {
+	for _, p := range nm.Peerings {
+		if p.A.DeviceID == nil || p.B.DeviceID == nil {
+			return fmt.Errorf("transit GWs of peering %s not found", p.Name)
+		}
+		deviceLog(p.A).Infof("Create Transit GW peering attachment: %s %s -> %s %s", p.A.Region, p.A.Name, p.B.Region, p.B.Name)
+		r, err := nm.CreateTransitGatewayPeeringAttachment(&p.A.Region, &p.Name, p.A.DeviceID, p.B.DeviceID, &p.B.Region, p.B.OwnerID)
+		if err != nil {
+			return err
+		}
+		p.AttachmentID = r.TransitGatewayPeeringAttachment.TransitGatewayAttachmentId
+		log.Debugf("Transit GW peering attachment Id: %s", *p.AttachmentID)
+
+		state, err := nm.waitTransitGatewayPeeringAttachment(p, types.TransitGatewayAttachmentStatePendingacceptance, types.TransitGatewayAttachmentStateAvailable)
+		if err != nil {
+			return err
+		}
+		if state == types.TransitGatewayAttachmentStatePendingacceptance {
+			awsLog(p.B.Region, p.AttachmentID).Infof("Accept Transit GW peering attachment: %s %s", p.B.Region, *p.AttachmentID)
+			if _, err := nm.AcceptTransitGatewayPeeringAttachment(&p.B.Region, p.AttachmentID); err != nil {
+				return err
+			}
+			if _, err := nm.waitTransitGatewayPeeringAttachment(p, types.TransitGatewayAttachmentStateAvailable); err != nil {
+				return err
+			}
+		}
+
+		// peering attachments do not support propagation, the segment gets static routes
+		if err := nm.associateRouteTable(p.A, p.AttachmentID, p.RouteTable, false); err != nil {
+			return err
+		}
+		if err := nm.associateRouteTable(p.B, p.AttachmentID, p.RouteTable, false); err != nil {
+			return err
+		}
+
+		// static routes for the cidrs behind the remote tgw
+		if err := nm.createPeeringRoutes(p, p.A, p.B); err != nil {
+			return err
+		}
+		if err := nm.createPeeringRoutes(p, p.B, p.A); err != nil {
+			return err
+		}
+	}
+
+	if len(nm.Peerings) > 0 {
+		// Network Manager shows the peerings between the registered tgws
+		rr, err := nm.GetTransitGatewayRegistrations()
+		if err != nil {
+			return err
+		}
+		for _, d := range nm.Devices {
+			if d.Kind != "tgw" || d.DeviceARN == nil || len(nm.peerTgws(d)) == 0 {
+				continue
+			}
+			registered := false
+			for _, t := range rr.TransitGatewayRegistrations {
+				if t.TransitGatewayArn != nil && *t.TransitGatewayArn == *d.DeviceARN {
+					registered = true
+				}
+			}
+			if !registered {
+				deviceLog(d).Infof("Register Transit GW: %s", d.Name)
+				if _, err := nm.RegisterTransitGateway(d.DeviceARN); err != nil {
+					return err
+				}
+				arn := d.DeviceARN
+				nm.journal.record("transit gateway registration", d.Name, *arn, *arn, func() error {
+					_, err := nm.DeregisterTransitGateway(arn)
+					return err
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// createPeeringRoutes adds static routes in the route table of the local tgw for the
+// cidrs behind the remote tgw towards the peering attachment
+func (nm *NMgr) createPeeringRoutes(p *Peering, local, remote *Device) error {
+	for _, rtID := range nm.segmentRouteTables(local, p.RouteTable) {
+		if rtID == nil {
+			return fmt.Errorf("transit GW %s has no default route table", local.Name)
+		}
+		r, err := nm.SearchTransitGatewayStaticRoutes(&local.Region, rtID)
+		if err != nil {
+			return err
+		}
+		for _, cidr := range nm.tgwCidrs(remote) {
+			cidr := cidr
+			if tgwRouteExists(r.Routes, cidr, *p.AttachmentID) {
+				continue
+			}
+			log.Infof("Create Transit GW route: %s %s %s -> %s", local.Name, *rtID, cidr, *p.AttachmentID)
+			if _, err := nm.CreateTransitGatewayStaticRoute(&local.Region, rtID, &cidr, p.AttachmentID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteAWSPeerings deletes the static routes towards the tgw peering attachments and
+// the peering attachments
+func (nm *NMgr) DeleteAWSPeerings() error {
+	for _, p := range nm.Peerings {
+		r, err := nm.DescribeTransitGatewayPeeringAttachments(&p.A.Region, &p.Name)
+		if err != nil {
+			return err
+		}
+		for _, a := range r.TransitGatewayPeeringAttachments {
+			if a.State == types.TransitGatewayAttachmentStateDeleted || a.State == types.TransitGatewayAttachmentStateDeleting {
+				continue
+			}
+			p.AttachmentID = a.TransitGatewayAttachmentId
+		}
+		if p.AttachmentID == nil {
+			log.Infof("Transit GW peering attachment not found: %s", p.Name)
+			continue
+		}
+
+		for _, d := range []*Device{p.A, p.B} {
+			found, err := nm.findTransitGateway(d)
+			if err != nil {
+				return err
+			}
+			if !found {
+				continue
+			}
+			if err := nm.findRouteTables(d); err != nil {
+				return err
+			}
+			for _, rtID := range nm.segmentRouteTables(d, p.RouteTable) {
+				if rtID == nil {
+					continue
+				}
+				rr, err := nm.SearchTransitGatewayStaticRoutes(&d.Region, rtID)
+				if err != nil {
+					return err
+				}
+				for _, route := range rr.Routes {
+					if route.DestinationCidrBlock != nil && tgwRouteExists([]types.TransitGatewayRoute{route}, *route.DestinationCidrBlock, *p.AttachmentID) {
+						log.Infof("Delete Transit GW route: %s %s %s", d.Name, *rtID, *route.DestinationCidrBlock)
+						if _, err := nm.DeleteTransitGatewayStaticRoute(&d.Region, rtID, route.DestinationCidrBlock); err != nil {
+							log.Error(err)
+						}
+					}
+				}
+			}
+		}
+
+		awsLog(p.A.Region, p.AttachmentID).Infof("Delete Transit GW peering attachment: %s", *p.AttachmentID)
+		if _, err := nm.DeleteTransitGatewayPeeringAttachment(&p.A.Region, p.AttachmentID); err != nil {
+			return err
+		}
+		if _, err := nm.waitTransitGatewayPeeringAttachment(p, types.TransitGatewayAttachmentStateDeleted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitTransitGatewayPeeringAttachment waits till the tgw peering attachment reaches one of
+// the states and returns the state
+func (nm *NMgr) waitTransitGatewayPeeringAttachment(p *Peering, states ...types.TransitGatewayAttachmentState) (types.TransitGatewayAttachmentState, error) {
+	what := "tgw peering attachment " + p.Name
+	start := time.Now()
+	defer nm.trackWait(what, start)
+	i := 0
+	for {
+		r, err := nm.DescribeTransitGatewayPeeringAttachment(&p.A.Region, p.AttachmentID)
+		if err != nil {
+			return "", err
+		}
+		if len(r.TransitGatewayPeeringAttachments) == 0 {
+			// the attachment no longer exists
+			for _, state := range states {
+				if state == types.TransitGatewayAttachmentStateDeleted {
+					return state, nil
+				}
+			}
+			return "", fmt.Errorf("transit GW peering attachment %s not found", *p.AttachmentID)
+		}
+		for _, a := range r.TransitGatewayPeeringAttachments {
+			for _, state := range states {
+				if a.State == state {
+					return a.State, nil
+				}
+			}
+			if a.State == types.TransitGatewayAttachmentStateFailed || a.State == types.TransitGatewayAttachmentStateRejected {
+				return a.State, fmt.Errorf("transit GW peering attachment %s is in %s state", *p.AttachmentID, a.State)
+			}
+		}
+		if err := nm.waitExpired(what, start); err != nil {
+			return "", err
+		}
+		i++
+		log.Infof("Wait 30 seconds to check if the peering attachment %s is in %v state, total (%d sec)", p.Name, states, i*30)
+		time.Sleep(30 * time.Second)
+	}
+}
+
+// tgwRouteExists checks if the tgw routes have a route for the cidr towards the attachment
+func tgwRouteExists(routes []types.TransitGatewayRoute, cidr, attachmentID string) bool {
+	for _, route := range routes {
+		if route.DestinationCidrBlock == nil || *route.DestinationCidrBlock != cidr {
+			continue
+		}
+		for _, a := range route.TransitGatewayAttachments {
+			if a.TransitGatewayAttachmentId != nil && *a.TransitGatewayAttachmentId == attachmentID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CreateNuageRedundancy creates or discovers the redundant gateway groups of the redundant
+// NSG pairs and the shunt links between them, the NSGs need to be resolved before
+func (nm *NMgr) CreateNuageRedundancy(enterprise *vspk.Enterprise) error {
+	for siteName, s := range nm.Sites {
+		if s.RedundantGroup == "" {
+			continue
+		}
+		if !nm.fullySelectedSite(siteName) {
+			log.Infof("Skip Nuage redundancy of site %s, not all its devices are selected", siteName)
+			continue
+		}
+		nsg1 := s.RedundantDevices[0].NuageNSGateway
+		nsg2 := s.RedundantDevices[1].NuageNSGateway
+		if nsg1 == nil || nsg2 == nil {
+			return fmt.Errorf("Nuage NSG devices of site %s are not available", siteName)
+		}
+
+		var group *vspk.NSRedundantGatewayGroup
+		switch {
+		case nsg1.RedundancyGroupID != "" && nsg1.RedundancyGroupID == nsg2.RedundancyGroupID:
+			log.Infof("Found Nuage redundant gateway group: %s %s", siteName, nsg1.RedundancyGroupID)
+			g, err := nm.getNsgRedundantGwGroup(nsg1.RedundancyGroupID)
+			if err != nil {
+				return err
+			}
+			group = g
+		case nsg1.RedundancyGroupID != "" || nsg2.RedundancyGroupID != "":
+			return fmt.Errorf("Nuage NSG devices of site %s are part of another redundant gateway group", siteName)
+		default:
+			log.Infof("Create Nuage redundant gateway group: %s %s", siteName, s.RedundantGroup)
+			g, created, err := nm.nsgRedundantGwGroup(s.RedundantGroup, nsg1, nsg2, enterprise)
+			if err != nil {
+				return err
+			}
+			group = g
+			if created {
+				name := s.RedundantGroup
+				nm.journal.record("nuage redundant gateway group", name, group.ID, "", func() error {
+					return nm.deleteNsgRedundantGwGroup(name, enterprise)
+				})
+			}
+		}
+		log.Debugf("Nuage redundant gateway group ID: %s", group.ID)
+		s.NuageRedundantGroup = group
+
+		if s.ShuntLink == nil {
+			continue
+		}
+		var vlans []*vspk.VLAN
+		for i, d := range s.RedundantDevices {
+			port, err := nm.getNetworkPort(s.ShuntLink.Ports[i], d.NuageNSGateway)
+			if err != nil {
+				return err
+			}
+			if port == nil {
+				return fmt.Errorf("Nuage NSG port does not exist: %s %s", d.Name, s.ShuntLink.Ports[i])
+			}
+			vlan, err := nm.getVlan(s.ShuntLink.Vlan, port)
+			if err != nil {
+				return err
+			}
+			if vlan == nil {
+				return fmt.Errorf("Nuage NSG vlan does not exist: %s %s %d", d.Name, s.ShuntLink.Ports[i], s.ShuntLink.Vlan)
+			}
+			vlans = append(vlans, vlan)
+		}
+		log.Infof("Create Nuage shunt link: %s %s", siteName, group.Name)
+		shuntLink, created, err := nm.shuntLink(group.Name+"-shunt", vlans[0], vlans[1], group)
+		if err != nil {
+			return err
+		}
+		if created {
+			name, g := shuntLink.Name, group
+			nm.journal.record("nuage shunt link", name, shuntLink.ID, "", func() error {
+				return nm.deleteShuntLink(name, g)
+			})
+		}
+		log.Debugf("Nuage shunt link ID: %s", shuntLink.ID)
+	}
+	return nil
+}
+
+// DeleteNuageRedundancy deletes the redundant gateway groups and their shunt links of
+// the sites whose devices are all selected
+func (nm *NMgr) DeleteNuageRedundancy(enterprise *vspk.Enterprise) error {
+	for siteName, s := range nm.Sites {
+		if s.RedundantGroup == "" {
+			continue
+		}
+		if !nm.fullySelectedSite(siteName) {
+			log.Infof("Skip Nuage redundancy of site %s, not all its devices are selected", siteName)
+			continue
+		}
+		siteLog(siteName).Infof("Delete Nuage redundant gateway group: %s %s", siteName, s.RedundantGroup)
+		if err := nm.deleteNsgRedundantGwGroup(s.RedundantGroup, enterprise); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// segmentCidrs returns the cidrs of the VPCs whose routes are propagated into the route table
+func (nm *NMgr) segmentCidrs(rtName string) []string {
+	var cidrs []string
+	rt := nm.Config.Topology.RouteTables[rtName]
+	for _, vpc := range nm.Vpcs {
+		if vpc.Cidr != "" && contains(rt.PropagateFrom, vpc.RouteTable) && !contains(cidrs, vpc.Cidr) {
+			cidrs = append(cidrs, vpc.Cidr)
+		}
+	}
+	return cidrs
+}
+
+// ikeConnectionName returns the name of the VSD IKE gateway connection of tunnel i of the
+// vpn connection of the endpoint
+func ikeConnectionName(ep *Endpoint, i int) string {
+	return "TGWCGW" + ep.Region + ep.Device.Name + ep.Name + strconv.Itoa(i)
+}
+
+// segmentEndpoints returns the sdwan endpoints of the connections in the route table
+func (nm *NMgr) segmentEndpoints(rtName string) []*Endpoint {
+	var eps []*Endpoint
+	for i := 0; i < len(nm.Connections); i++ {
+		if ep := nm.Connections[i].A; ep.RouteTable == rtName && ep.Device.Kind == "sdwan" {
+			eps = append(eps, ep)
+		}
+	}
+	return eps
+}
+
+// CreateNuageDomains sets up the Nuage domains that are mapped to the route tables, the
+// domains have the underlay enabled and route the AWS cidrs of the segment towards the
+// tgw through static routes or BGP neighbors on the tunnel inside addresses
+func (nm *NMgr) CreateNuageDomains(enterprise *vspk.Enterprise) error {
+	for rtName, rt := range nm.Config.Topology.RouteTables {
+		if rt.Nuage == nil {
+			continue
+		}
+		log.Infof("Create Nuage domain: %s", rt.Nuage.Domain)
+		domain, err := nm.getDomain(rt.Nuage.Domain, enterprise)
+		if err != nil {
+			return err
+		}
+		if domain == nil {
+			templateName := rt.Nuage.DomainTemplate
+			if templateName == "" {
+				templateName = rt.Nuage.Domain + "-template"
+			}
+			domainTemplate, err := nm.domainTemplate(templateName, enterprise)
+			if err != nil {
+				return err
+			}
+			if domain, err = nm.domain(rt.Nuage.Domain, domainTemplate, enterprise); err != nil {
+				return err
+			}
+		} else if domain.UnderlayEnabled != "ENABLED" {
+			domain.UnderlayEnabled = "ENABLED"
+			if err := nm.retryVSD("domains", domain.Save); err != nil {
+				nm.auditVSD("SaveDomain", domain, domain.ID, fmt.Errorf("%s", err.Description))
+				return fmt.Errorf("%s", err.Description)
+			}
+			nm.auditVSD("SaveDomain", domain, domain.ID, nil)
+			nm.updated("nuage domain", domain.Name, domain.ID, "")
+		}
+		log.Debugf("Nuage domain ID: %s", domain.ID)
+
+		var zone *vspk.Zone
+		if rt.Nuage.Zone != "" {
+			if zone, err = nm.zone(rt.Nuage.Zone, domain); err != nil {
+				return err
+			}
+			log.Debugf("Nuage zone ID: %s", zone.ID)
+		}
+
+		eps := nm.segmentEndpoints(rtName)
+		switch rt.Nuage.Routing {
+		case "bgp":
+			subnet, err := nm.getSubnet(rt.Nuage.Subnet, zone)
+			if err != nil {
+				return err
+			}
+			if subnet == nil {
+				return fmt.Errorf("nuage subnet %s does not exist in zone %s", rt.Nuage.Subnet, rt.Nuage.Zone)
+			}
+			for _, ep := range eps {
+				for i, ip := range ep.TunnelInsideIPs {
+					log.Infof("Create Nuage BGP neighbor: %s %s %s", rt.Nuage.Domain, ep.Name, ip)
+					neighbor, created, err := nm.bgpNeighbor(subnet, "TGW"+ep.Region+ep.Device.Name+ep.Name+strconv.Itoa(i), ip, int(tgwAsn))
+					if err != nil {
+						return err
+					}
+					if created {
+						nm.journal.record("nuage bgp neighbor", neighbor.Name, neighbor.ID, "", func() error {
+							return vsdDelete(nm, "bgpneighbors", "BGPNeighbor", neighbor)
+						})
+					}
+				}
+			}
+		default:
+			// a route per tunnel, the tunnels of all branches of the segment are ECMP next hops
+			cidrs := nm.segmentCidrs(rtName)
+			for _, ep := range eps {
+				for i, nextHop := range ep.TunnelInsideIPs {
+					// routes towards an earlier inside address of the tunnel
+					if err := nm.deleteTunnelStaticRoutes(domain, ikeConnectionName(ep, i), nextHop); err != nil {
+						return err
+					}
+					for _, cidr := range cidrs {
+						log.Infof("Create Nuage static route: %s %s -> %s", rt.Nuage.Domain, cidr, nextHop)
+						route, created, err := nm.staticRoute(domain, cidr, nextHop, "EXIT_DOMAIN", ikeConnectionName(ep, i))
+						if err != nil {
+							return err
+						}
+						if created {
+							nm.journal.record("nuage static route", cidr+" "+nextHop, route.ID, "", func() error {
+								return vsdDelete(nm, "staticroutes", "StaticRoute", route)
+							})
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteNuageDomains deletes the static routes and BGP neighbors towards the tgw from the
+// Nuage domains, the domains themselves are left in place
+func (nm *NMgr) DeleteNuageDomains(enterprise *vspk.Enterprise) error {
+	for rtName, rt := range nm.Config.Topology.RouteTables {
+		if rt.Nuage == nil {
+			continue
+		}
+		domain, err := nm.getDomain(rt.Nuage.Domain, enterprise)
+		if err != nil {
+			return err
+		}
+		if domain == nil {
+			log.Infof("Nuage domain not found: %s", rt.Nuage.Domain)
+			continue
+		}
+		switch rt.Nuage.Routing {
+		case "bgp":
+			zone, err := nm.getZone(rt.Nuage.Zone, domain)
+			if err != nil {
+				return err
+			}
+			if zone == nil {
+				continue
+			}
+			subnet, err := nm.getSubnet(rt.Nuage.Subnet, zone)
+			if err != nil {
+				return err
+			}
+			if subnet == nil {
+				continue
+			}
+			for _, ep := range nm.segmentEndpoints(rtName) {
+				for i := 0; i < 2; i++ {
+					log.Infof("Delete Nuage BGP neighbor: %s %s %d", rt.Nuage.Domain, ep.Name, i)
+					if err := nm.deleteBGPNeighbor(subnet, "TGW"+ep.Region+ep.Device.Name+ep.Name+strconv.Itoa(i)); err != nil {
+						log.Errorf("deleteBGPNeighbor error: %v", err)
+					}
+				}
+			}
+		default:
+			for _, ep := range nm.segmentEndpoints(rtName) {
+				for i := 0; i < 2; i++ {
+					log.Infof("Delete Nuage static routes: %s %s %d", rt.Nuage.Domain, ep.Name, i)
+					if err := nm.deleteTunnelStaticRoutes(domain, ikeConnectionName(ep, i), ""); err != nil {
+						log.Errorf("deleteTunnelStaticRoutes error: %v", err)
+					}
+				}
+			}
+			if !nm.selectedAll() {
+				continue
+			}
+			// routes of earlier deploys that are not tied to a tunnel
+			for _, cidr := range nm.segmentCidrs(rtName) {
+				log.Infof("Delete Nuage static route: %s %s", rt.Nuage.Domain, cidr)
+				if err := nm.deleteStaticRoute(domain, cidr); err != nil {
+					log.Errorf("deleteStaticRoute error: %v", err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// CreateAWSRouteTables creates the named route tables on the tgws
+func (nm *NMgr) CreateAWSRouteTables() error {
+	for deviceName, d := range nm.Devices {
+		if d.Kind != "tgw" || d.DeviceID == nil {
+			continue
+		}
+		d.RouteTables = make(map[string]*string)
+		for rtName := range nm.Config.Topology.RouteTables {
+			name := deviceName + "-" + rtName
+			deviceLog(d).Infof("Create Transit GW route table: %s", name)
+			r, err := nm.CreateTransitGatewayRouteTable(&d.Region, &name, d.DeviceID)
+			if err != nil {
+				return err
+			}
+			d.RouteTables[rtName] = r.TransitGatewayRouteTable.TransitGatewayRouteTableId
+			log.Debugf("Transit GW route table Id: %s", *d.RouteTables[rtName])
+		}
+	}
+	for _, d := range nm.Devices {
+		for rtName, rtID := range d.RouteTables {
+			if err := nm.waitTransitGatewayRouteTable(d, rtName, rtID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// waitTransitGatewayRouteTable waits till the tgw route table is available
+func (nm *NMgr) waitTransitGatewayRouteTable(d *Device, rtName string, rtID *string) error {
+	what := "tgw route table available " + d.Name + "-" + rtName
+	start := time.Now()
+	defer nm.trackWait(what, start)
+	name := d.Name + "-" + rtName
+	i := 0
+	for {
+		r, err := nm.DescribeTransitGatewayRouteTables(&d.Region, &name)
+		if err != nil {
+			return err
+		}
+		for _, rt := range r.TransitGatewayRouteTables {
+			if *rt.TransitGatewayRouteTableId == *rtID && rt.State == types.TransitGatewayRouteTableStateAvailable {
+				return nil
+			}
+		}
+		if err := nm.waitExpired(what, start); err != nil {
+			return err
+		}
+		i++
+		log.Infof("Wait 10 seconds to check if the route table %s is available, total (%d sec)", name, i*10)
+		time.Sleep(10 * time.Second)
+	}
+}
+
+// associateRouteTable associates the tgw attachment with the named route table of the
+// segment and sets up the propagations of the segment, attachments without a route
+// table stay in the default route table of the tgw
+func (nm *NMgr) associateRouteTable(d *Device, attachmentID *string, rtName string, propagate bool) error {
+	if rtName == "" {
+		return nil
+	}
+	rtID := d.RouteTables[rtName]
+	if rtID == nil {
+		return fmt.Errorf("route table %s not found on transit GW %s", rtName, d.Name)
+	}
+
+	r, err := nm.DescribeTransitGatewayAttachment(&d.Region, attachmentID)
+	if err != nil {
+		return err
+	}
+	for _, a := range r.TransitGatewayAttachments {
+		if a.Association != nil && a.Association.TransitGatewayRouteTableId != nil {
+			if *a.Association.TransitGatewayRouteTableId == *rtID {
+				log.Debugf("Transit GW attachment %s is associated with route table %s", *attachmentID, rtName)
+				break
+			}
+			awsLog(d.Region, attachmentID).Infof("Disassociate Transit GW attachment: %s %s", *attachmentID, *a.Association.TransitGatewayRouteTableId)
+			if _, err := nm.DisassociateTransitGatewayRouteTable(&d.Region, a.Association.TransitGatewayRouteTableId, attachmentID); err != nil {
+				return err
+			}
+			if err := nm.waitTransitGatewayAttachmentDisassociated(d, attachmentID); err != nil {
+				return err
+			}
+			nm.updated("tgw route table association", rtName, *attachmentID, "")
+		}
+		awsLog(d.Region, attachmentID).Infof("Associate Transit GW attachment: %s %s", *attachmentID, rtName)
+		if _, err := nm.AssociateTransitGatewayRouteTable(&d.Region, rtID, attachmentID); err != nil {
+			return err
+		}
+	}
+
+	if !propagate {
+		return nil
+	}
+	// the attachment only propagates into the route tables that propagate from the segment
+	if d.PropagationRTID != nil && !containsID(d.RouteTables, *d.PropagationRTID) {
+		if err := nm.setPropagation(d, d.PropagationRTID, attachmentID, false); err != nil {
+			return err
+		}
+	}
+	for name, rt := range nm.Config.Topology.RouteTables {
+		if err := nm.setPropagation(d, d.RouteTables[name], attachmentID, contains(rt.PropagateFrom, rtName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setPropagation enables or disables the propagation of the attachment into the route table
+func (nm *NMgr) setPropagation(d *Device, rtID, attachmentID *string, enable bool) error {
+	r, err := nm.GetTransitGatewayRouteTablePropagations(&d.Region, rtID)
+	if err != nil {
+		return err
+	}
+	enabled := false
+	for _, p := range r.TransitGatewayRouteTablePropagations {
+		if p.TransitGatewayAttachmentId != nil && *p.TransitGatewayAttachmentId == *attachmentID &&
+			p.State != types.TransitGatewayPropagationStateDisabled && p.State != types.TransitGatewayPropagationStateDisabling {
+			enabled = true
+		}
+	}
+	switch {
+	case enable && !enabled:
+		log.Infof("Enable Transit GW route table propagation: %s %s", *rtID, *attachmentID)
+		_, err = nm.EnableTransitGatewayRouteTablePropagation(&d.Region, rtID, attachmentID)
+	case !enable && enabled:
+		log.Infof("Disable Transit GW route table propagation: %s %s", *rtID, *attachmentID)
+		_, err = nm.DisableTransitGatewayRouteTablePropagation(&d.Region, rtID, attachmentID)
+	}
+	return err
+}
+
+// waitTransitGatewayAttachmentDisassociated waits till the tgw attachment has no route
+// table association
+func (nm *NMgr) waitTransitGatewayAttachmentDisassociated(d *Device, attachmentID *string) error {
+	what := "tgw attachment disassociated " + *attachmentID
+	start := time.Now()
+	defer nm.trackWait(what, start)
+	i := 0
+	for {
+		r, err := nm.DescribeTransitGatewayAttachment(&d.Region, attachmentID)
+		if err != nil {
+			return err
+		}
+		done := true
+		for _, a := range r.TransitGatewayAttachments {
+			if a.Association != nil && a.Association.TransitGatewayRouteTableId != nil {
+				done = false
+			}
+		}
+		if done {
+			return nil
+		}
+		if err := nm.waitExpired(what, start); err != nil {
+			return err
+		}
+		i++
+		log.Infof("Wait 10 seconds to check if the attachment %s is disassociated, total (%d sec)", *attachmentID, i*10)
+		time.Sleep(10 * time.Second)
+	}
+}
+
+// waitTransitGatewayAttachmentDeleted waits till the tgw attachment is deleted
+func (nm *NMgr) waitTransitGatewayAttachmentDeleted(region, attachmentID *string) error {
+	what := "tgw attachment deleted " + *attachmentID
+	start := time.Now()
+	defer nm.trackWait(what, start)
+	i := 0
+	for {
+		r, err := nm.DescribeTransitGatewayAttachment(region, attachmentID)
+		if err != nil {
+			return err
+		}
+		done := true
+		for _, a := range r.TransitGatewayAttachments {
+			if a.State != types.TransitGatewayAttachmentStateDeleted {
+				done = false
+			}
+		}
+		if done {
+			return nil
+		}
+		if err := nm.waitExpired(what, start); err != nil {
+			return err
+		}
+		i++
+		log.Infof("Wait 30 seconds to check if the attachment %s is deleted, total (%d sec)", *attachmentID, i*30)
+		time.Sleep(30 * time.Second)
+	}
+}
+
+// segmentRouteTables returns the route table ids of the tgw that receive the routes of
+// the segment, the default route table is used when the segment is not set
+func (nm *NMgr) segmentRouteTables(d *Device, rtName string) []*string {
+	if rtName == "" {
+		return []*string{d.RouteTableID}
+	}
+	var rtIDs []*string
+	for name, rt := range nm.Config.Topology.RouteTables {
+		if contains(rt.PropagateFrom, rtName) && d.RouteTables[name] != nil {
+			rtIDs = append(rtIDs, d.RouteTables[name])
+		}
+	}
+	return rtIDs
+}
+
+// findRouteTables looks up the named route tables of the tgw
+func (nm *NMgr) findRouteTables(d *Device) error {
+	d.RouteTables = make(map[string]*string)
+	for rtName := range nm.Config.Topology.RouteTables {
+		name := d.Name + "-" + rtName
+		r, err := nm.DescribeTransitGatewayRouteTables(&d.Region, &name)
+		if err != nil {
+			return err
+		}
+		for _, rt := range r.TransitGatewayRouteTables {
+			if rt.State != types.TransitGatewayRouteTableStateDeleted && rt.State != types.TransitGatewayRouteTableStateDeleting {
+				d.RouteTables[rtName] = rt.TransitGatewayRouteTableId
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteAWSRouteTables deletes the named route tables of the tgws after removing the
+// associations and propagations
+func (nm *NMgr) DeleteAWSRouteTables() error {
+	for deviceName, d := range nm.Devices {
+		if d.Kind != "tgw" {
+			continue
+		}
+		found, err := nm.findTransitGateway(d)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		for rtName := range nm.Config.Topology.RouteTables {
+			name := deviceName + "-" + rtName
+			r, err := nm.DescribeTransitGatewayRouteTables(&d.Region, &name)
+			if err != nil {
+				return err
+			}
+			for _, rt := range r.TransitGatewayRouteTables {
+				if rt.State == types.TransitGatewayRouteTableStateDeleted || rt.State == types.TransitGatewayRouteTableStateDeleting {
+					continue
+				}
+				if err := nm.deleteRouteTable(d.Region, name, rt.TransitGatewayRouteTableId); err != nil {
+					log.Errorf("Error deleting route table %s: %s", name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// deleteRouteTable removes the associations and propagations of the tgw route table
+// and deletes it
+func (nm *NMgr) deleteRouteTable(region, name string, rtID *string) error {
+	p, err := nm.GetTransitGatewayRouteTablePropagations(&region, rtID)
+	if err != nil {
+		return err
+	}
+	for _, prop := range p.TransitGatewayRouteTablePropagations {
+		if prop.State == types.TransitGatewayPropagationStateEnabled {
+			log.Infof("Disable Transit GW route table propagation: %s %s", name, *prop.TransitGatewayAttachmentId)
+			if _, err := nm.DisableTransitGatewayRouteTablePropagation(&region, rtID, prop.TransitGatewayAttachmentId); err != nil {
+				log.Error(err)
+			}
+		}
+	}
+	what := "tgw route table disassociated " + name
+	start := time.Now()
+	i := 0
+	for {
+		a, err := nm.GetTransitGatewayRouteTableAssociations(&region, rtID)
+		if err != nil {
+			return err
+		}
+		if len(a.Associations) == 0 {
+			break
+		}
+		for _, assoc := range a.Associations {
+			if assoc.State == types.TransitGatewayAssociationStateAssociated {
+				awsLog(region, assoc.TransitGatewayAttachmentId).Infof("Disassociate Transit GW attachment: %s %s", name, *assoc.TransitGatewayAttachmentId)
+				if _, err := nm.DisassociateTransitGatewayRouteTable(&region, rtID, assoc.TransitGatewayAttachmentId); err != nil {
+					log.Error(err)
+				}
+			}
+		}
+		if err := nm.waitExpired(what, start); err != nil {
+			return err
+		}
+		i++
+		log.Infof("Wait 10 seconds to check if the route table %s has no associations, total (%d sec)", name, i*10)
+		time.Sleep(10 * time.Second)
+	}
+	awsLog(region, rtID).Infof("Delete Transit GW route table: %s", name)
+	_, err = nm.DeleteTransitGatewayRouteTable(&region, rtID)
+	return err
+}
+
+func containsID(m map[string]*string, id string) bool {
+	for _, v := range m {
+		if v != nil && *v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// routeExists checks if the route table has a route for the cidr towards the tgw
+func routeExists(rt types.RouteTable, cidr, tgwID string) bool {
+	for _, route := range rt.Routes {
+		if route.DestinationCidrBlock != nil && *route.DestinationCidrBlock == cidr &&
+			route.TransitGatewayId != nil && *route.TransitGatewayId == tgwID {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAWSNetworkMgrNetwork function
+func (nm *NMgr) CreateAWSNetworkMgrNetwork() error {
+	log.Infof("Create Global Network: %s", nm.Config.Name)
+	respNetw, err := nm.CreateGlobalNetwork(&nm.Config.Name)
+	if err != nil {
+		return fmt.Errorf("Error create global network: %s", err)
+	}
+	log.Infof("Global Network Id: %v", *respNetw.GlobalNetwork.GlobalNetworkId)
+	nm.GlobalNetworkID = respNetw.GlobalNetwork.GlobalNetworkId
+
+	for deviceName, device := range nm.Devices {
+		switch device.Kind {
+		case "tgw":
+			deviceLog(device).Infof("Create TGW: %s", deviceName)
+			r, err := nm.CreateTransitGateway(&device.Region, &deviceName, device.Tags, len(nm.Config.Topology.RouteTables) > 0)
+			if err != nil {
+				return fmt.Errorf("Error create device: %s", err)
+			}
+			deviceLog(device).WithField("aws_id", *r.TransitGateway.TransitGatewayId).Infof("Device Id: %v", *r.TransitGateway.TransitGatewayId)
+			device.DeviceID = r.TransitGateway.TransitGatewayId
+			device.DeviceARN = r.TransitGateway.TransitGatewayArn
+			_, err = nm.RegisterTransitGateway(device.DeviceARN)
+			if err != nil {
+				deviceLog(device).Errorf("Error associating TGW: %s", err)
+			} else if nm.journal.created("transit gateway", *device.DeviceID) {
+				arn := device.DeviceARN
+				nm.journal.record("transit gateway registration", deviceName, *arn, *arn, func() error {
+					_, err := nm.DeregisterTransitGateway(arn)
+					return err
+				})
+			}
+
+		}
+	}
+	return nil
+}
+
+// DeleteAWSNetworkMgrNetwork function
+func (nm *NMgr) DeleteAWSNetworkMgrNetwork() error {
+	r, err := nm.DescribeGlobalNetworks()
+	if err != nil {
+		return err
+	}
+
+	//if len(r.GlobalNetworks) > 0 {
+	for idx, g := range r.GlobalNetworks {
+		for i := 0; i < len(g.Tags); i++ {
+			if *g.Tags[i].Key == "Name" {
+				if *g.Tags[i].Value == nm.Config.Name {
+					log.Infof("Global Network found")
+					nm.GlobalNetworkID = r.GlobalNetworks[idx].GlobalNetworkId
+				}
+			}
+		}
+	}
+	if nm.GlobalNetworkID != nil {
+		for deviceName, device := range nm.Devices {
+			switch device.Kind {
+			case "tgw":
+				r, err := nm.GetTransitGatewayRegistrations()
+				if err != nil {
+					log.Error(err)
+				}
+				for _, t := range r.TransitGatewayRegistrations {
+					log.Infof("Deregister Transit Gateway....")
+					_, err = nm.DeregisterTransitGateway(t.TransitGatewayArn)
+					if err != nil {
+						log.Error(err)
+					}
+				}
+				log.Infof("Delete Transit Gateway....")
+				tgws, err := nm.DescribeTransitGateways(&device.Region, &deviceName)
+				if err != nil {
+					log.Error(err)
+				}
+				for _, t := range tgws.TransitGateways {
+					_, err = nm.DeleteTransitGateway(&device.Region, t.TransitGatewayId)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+		time.Sleep(30 * time.Second)
+
+		log.Infof("Delete Global Network....")
+		if _, err := nm.DeleteGlobalNetwork(); err != nil {
+			log.Errorf("Error deleting Global Network: %s", err)
+		}
+	} else {
+		log.Infof("Nothing to delete....")
+	}
+
+	return nil
+}
+
+// CreateAWSNetworkMgrSites function
+func (nm *NMgr) CreateAWSNetworkMgrSites() error {
+	log.Infof("Add sites to Global Network: %s", nm.Config.Name)
+	if err := nm.loadCheckpoint(); err != nil {
+		return fmt.Errorf("Error read state file: %s", err)
+	}
+
+	respNetw, err := nm.CreateGlobalNetwork(&nm.Config.Name)
+	if err != nil {
+		return fmt.Errorf("Error create global network: %s", err)
+	}
+	log.Debugf("Global Network Id: %v", *respNetw.GlobalNetwork.GlobalNetworkId)
+	nm.GlobalNetworkID = respNetw.GlobalNetwork.GlobalNetworkId
+	nm.checkGlobalNetworkCheckpoint()
+	nm.checkpoint(phaseGlobalNetwork)
+
+	enterprise, err := nm.getEnterprise(nm.Config.Nuage.Enterprise)
+	if err != nil {
+		return err
+	}
+	if enterprise == nil {
+		return fmt.Errorf("Enterprise does not exist : %s", nm.Config.Nuage.Enterprise)
+	}
+	log.Debugf("Enterprise ID : %v", enterprise.ID)
+
+	ikePSK, err := nm.createIKEPSK("AWS-"+nm.Config.Name+"PSK", enterprise)
+	if err != nil {
+		return fmt.Errorf("Error create ike psk: %s", err)
+	}
+	log.Debugf("ikePSK: %v", ikePSK.ID)
 
-				for i, t := range r.TransitGateways {
-					if t.State == "deleted" || t.State == "deleting" {
-						// do nothing
-					} else {
-						found = true
-						if t.State == "available" {
-							state = true
-						}
-						device.DeviceID = r.TransitGateways[i].TransitGatewayId
-						device.DeviceARN = r.TransitGateways[i].TransitGatewayArn
-						log.Debugf("Transit GW Id: %s", *r.TransitGateways[i].TransitGatewayId)
-					}
+	ikeEncryptionProfile, err := nm.createIKEEncryptionprofile("AWS-"+nm.Config.Name, enterprise)
+	if err != nil {
+		return fmt.Errorf("Error create ike encryption profile: %s", err)
+	}
+	log.Debugf("ikeEncryptionProfile: %v", ikeEncryptionProfile)
+
+	skip, err := nm.resumePhase(phaseSites, nm.restoreSites)
+	if err != nil {
+		return fmt.Errorf("Error resume sites: %s", err)
+	}
+	if !skip {
+		for siteName, site := range nm.Sites {
+			siteLog(siteName).Infof("Create Site: %s", siteName)
+			r, err := nm.CreateSite(&siteName, site)
+			if err != nil {
+				return fmt.Errorf("Error create site: %s", err)
+			}
+			siteLog(siteName).WithField("aws_id", *r.Site.SiteId).Debugf("Site Id: %v", *r.Site.SiteId)
+			site.SiteID = r.Site.SiteId
+		}
+	}
+	nm.checkpoint(phaseSites)
+
+	skip, err = nm.resumePhase(phaseDevicesLinks, func() (bool, error) { return nm.restoreDevicesLinks(enterprise) })
+	if err != nil {
+		return fmt.Errorf("Error resume devices: %s", err)
+	}
+	if !skip {
+		for deviceName, device := range nm.Devices {
+			switch device.Kind {
+			case "sdwan":
+				deviceLog(device).Infof("Create Device: %s", deviceName)
+
+				nsGateway, err := nm.getNsg(deviceName, enterprise)
+				if err != nil {
+					return err
+				}
+				if nsGateway == nil {
+					return fmt.Errorf("Nuage NSG device does not exist: %s", deviceName)
+				}
+				deviceLog(device).WithField("vsd_id", nsGateway.ID).Debugf("Nuage NSG ID: %s", nsGateway.ID)
+				device.NuageNSGateway = nsGateway
+
+				r, err := nm.CreateDevice(&deviceName, device)
+				if err != nil {
+					return fmt.Errorf("Error create device: %s", err)
 				}
-				if !found {
-					log.Errorf("No transit GWs found, first 'awsnuagenetwmgr run deploy tgw -c <confi-file'")
-					return nil
+				deviceLog(device).WithField("aws_id", *r.Device.DeviceId).Debugf("Device Id: %v", *r.Device.DeviceId)
+				device.DeviceID = r.Device.DeviceId
+				device.DeviceARN = r.Device.DeviceArn
+				for epName, ep := range device.Endpoints {
+
+					nsVlan, err := nm.getEndpointVlan(ep, nsGateway)
+					if err != nil {
+						return err
+					}
+					endpointLog(ep).WithField("vsd_id", nsVlan.ID).Debugf("Nuage VLAN: %s", nsVlan.ID)
+					ep.NuageVlan = nsVlan
+
+					r, err := nm.CreateLink(&epName, ep)
+					if err != nil {
+						return fmt.Errorf("Error create link: %s", err)
+					}
+					endpointLog(ep).WithField("aws_id", *r.Link.LinkId).Debugf("Link Id: %v", *r.Link.LinkId)
+					ep.LinkID = r.Link.LinkId
+					_, err = nm.AssociateLink(device.DeviceID, ep.LinkID)
+					if err != nil {
+						return fmt.Errorf("Error create link: %s", err)
+					}
+					if nm.journal.created("link", *ep.LinkID) {
+						deviceID, linkID := device.DeviceID, ep.LinkID
+						nm.journal.record("link association", deviceName+":"+epName, *linkID, "", func() error {
+							_, err := nm.DisassociateLink(deviceID, linkID)
+							return err
+						})
+					}
 				}
-				if !state {
-					log.Infof("Wait a minute to check if all tgw are in available state, total (%d min)", i)
-					time.Sleep(60 * time.Second)
-					i++
+			case "tgw":
+				deviceLog(device).Infof("Find TGW: %s", deviceName)
+
+				state := false
+				found := false
+				start := time.Now()
+				i := 1
+				for ok := true; ok; ok = !state {
+					r, err := nm.DescribeTransitGateways(&device.Region, &deviceName)
+					if err != nil {
+						return fmt.Errorf("Error create device: %s", err)
+					}
+					if len(r.TransitGateways) == 0 {
+						return fmt.Errorf("No transit GWs found, first 'awsnuagenetwmgr run deploy tgw -c <confi-file'")
+					}
+
+					for i, t := range r.TransitGateways {
+						if t.State == "deleted" || t.State == "deleting" {
+							// do nothing
+						} else {
+							found = true
+							if t.State == "available" {
+								state = true
+							}
+							nm.setTransitGateway(device, &r.TransitGateways[i])
+							deviceLog(device).Debugf("Transit GW Id: %s", *r.TransitGateways[i].TransitGatewayId)
+						}
+					}
+					if !found {
+						return fmt.Errorf("No transit GWs found, first 'awsnuagenetwmgr run deploy tgw -c <confi-file'")
+					}
+					if !state {
+						if err := nm.waitExpired("transit gateway available "+deviceName, start); err != nil {
+							return err
+						}
+						deviceLog(device).Infof("Wait a minute to check if all tgw are in available state, total (%d min)", i)
+						time.Sleep(60 * time.Second)
+						i++
+					}
+
 				}
 
 			}
+		}
+	}
 
+	nm.checkpoint(phaseDevicesLinks)
+
+	if !nm.skipPhase(phaseRedundancy) {
+		if err := nm.CreateNuageRedundancy(enterprise); err != nil {
+			return fmt.Errorf("Error create nuage redundancy: %s", err)
 		}
 	}
+	nm.checkpoint(phaseRedundancy)
 
-	for _, conn := range nm.Connections {
-		if conn.A.Device.Kind == "sdwan" {
-			if conn.A.PublicIP != "" {
-				log.Infof("Create Customer Gateway: %s %s %s", conn.A.Region, conn.A.Name, conn.A.PublicIP)
-				r, err := nm.CreateCustomerGateway(&conn.A.Region, &conn.A.Name, &conn.A.PublicIP, &conn.A.Asn)
-				if err != nil {
-					log.Fatalf("Error create customer gateway: %s", err)
-				}
+	skip, err = nm.resumePhase(phaseRouteTables, nm.restoreRouteTables)
+	if err != nil {
+		return fmt.Errorf("Error resume tgw route tables: %s", err)
+	}
+	if !skip {
+		if err := nm.CreateAWSRouteTables(); err != nil {
+			return fmt.Errorf("Error create tgw route tables: %s", err)
+		}
+	}
+	nm.checkpoint(phaseRouteTables)
+
+	skip, err = nm.resumePhase(phaseVpcs, nm.restoreVpcs)
+	if err != nil {
+		return fmt.Errorf("Error resume vpcs: %s", err)
+	}
+	if !skip {
+		if err := nm.CreateAWSVpcs(); err != nil {
+			return fmt.Errorf("Error create vpcs: %s", err)
+		}
+	}
+	nm.checkpoint(phaseVpcs)
 
-				if conn.B.Device.Kind == "tgw" {
-					log.Infof("Create VPN connection: %s %s %s", conn.A.Region, conn.A.Name, conn.A.Cidr)
-					r, err := nm.CreateVpnConnection(&conn.A.Region, &conn.A.Name, r.CustomerGateway.CustomerGatewayId, conn.B.Device.DeviceID, &conn.A.Cidr)
+	if !nm.skipPhase(phasePeerings) {
+		if err := nm.CreateAWSPeerings(); err != nil {
+			return fmt.Errorf("Error create tgw peerings: %s", err)
+		}
+	}
+	nm.checkpoint(phasePeerings)
+
+	skip, err = nm.resumePhase(phaseVpnConnections, nm.restoreVpnConnections)
+	if err != nil {
+		return fmt.Errorf("Error resume vpn connections: %s", err)
+	}
+	if !skip {
+		for _, conn := range nm.Connections {
+			if conn.A.Device.Kind == "sdwan" {
+				if conn.A.PublicIP != "" {
+					endpointLog(conn.A).Infof("Create Customer Gateway: %s %s %s", conn.A.Region, conn.A.Name, conn.A.PublicIP)
+					r, err := nm.CreateCustomerGateway(&conn.A.Region, &conn.A.Name, &conn.A.PublicIP, &conn.A.Asn, conn.A.Tags)
 					if err != nil {
-						log.Fatalf("Error create vpn connection: %s", err)
+						return fmt.Errorf("Error create customer gateway: %s", err)
 					}
-					//log.Infof("VPN Connection: %v", *r.VpnConnection.CustomerGatewayConfiguration)
-					vpnConn := VpnConnection{}
-					xml.Unmarshal([]byte(*r.VpnConnection.CustomerGatewayConfiguration), &vpnConn)
-					for i, ipsec := range vpnConn.IpsecTunnel {
-						log.Debugf("VPN IP address : %s", ipsec.VpnGateway.TunnelOutsideAddress.IPAddress)
-						conn.A.CustomerGatewayIP = append(conn.A.CustomerGatewayIP, ipsec.VpnGateway.TunnelOutsideAddress.IPAddress)
 
-						ikeGatewayCfg := nm.createIKEGateway("TGWCGW"+conn.A.Region+conn.A.Device.Name+conn.A.Name+strconv.Itoa(i), "V1", ipsec.VpnGateway.TunnelOutsideAddress.IPAddress, enterprise)
-						log.Debugf("ikeGatewayCfg: %v", ikeGatewayCfg)
+					if conn.B.Device.Kind == "tgw" {
+						endpointLog(conn.A).Infof("Create VPN connection: %s %s %s", conn.A.Region, conn.A.Name, conn.A.Cidr)
+						r, err := nm.CreateVpnConnection(r.CustomerGateway.CustomerGatewayId, conn.B.Device.DeviceID, conn.A)
+						if err != nil {
+							return fmt.Errorf("Error create vpn connection: %s", err)
+						}
+						conn.A.VpnConnectionID = r.VpnConnection.VpnConnectionId
+						//log.Infof("VPN Connection: %v", *r.VpnConnection.CustomerGatewayConfiguration)
+						vpnConn := VpnConnection{}
+						xml.Unmarshal([]byte(*r.VpnConnection.CustomerGatewayConfiguration), &vpnConn)
+						for i, ipsec := range vpnConn.IpsecTunnel {
+							endpointLog(conn.A).WithField("aws_id", *conn.A.VpnConnectionID).Debugf("VPN IP address : %s", ipsec.VpnGateway.TunnelOutsideAddress.IPAddress)
+							conn.A.CustomerGatewayIP = append(conn.A.CustomerGatewayIP, ipsec.VpnGateway.TunnelOutsideAddress.IPAddress)
+							conn.A.TunnelInsideIPs = append(conn.A.TunnelInsideIPs, ipsec.VpnGateway.TunnelInsideAddress.IPAddress)
+
+							ikeGatewayCfg, err := nm.createIKEGateway("TGWCGW"+conn.A.Region+conn.A.Device.Name+conn.A.Name+strconv.Itoa(i), conn.A.IKEVersion, ipsec.VpnGateway.TunnelOutsideAddress.IPAddress, enterprise)
+							if err != nil {
+								return fmt.Errorf("Error create ike gateway: %s", err)
+							}
+							endpointLog(conn.A).WithField("vsd_id", ikeGatewayCfg.ID).Debugf("ikeGatewayCfg: %v", ikeGatewayCfg)
+
+							ikeGatewayProfile, err := nm.createIKEGatewayProfile("TGWCGW"+conn.A.Region+conn.A.Device.Name+conn.A.Name+strconv.Itoa(i), ikePSK.ID, ipsec.VpnGateway.TunnelOutsideAddress.IPAddress, ikeGatewayCfg.ID, ikeEncryptionProfile.ID, enterprise)
+							if err != nil {
+								return fmt.Errorf("Error create ike gateway profile: %s", err)
+							}
+							endpointLog(conn.A).WithField("vsd_id", ikeGatewayProfile.ID).Debugf("ikeGatewayProfile: %v", ikeGatewayProfile)
 
-						ikeGatewayProfile := nm.createIKEGatewayProfile("TGWCGW"+conn.A.Region+conn.A.Device.Name+conn.A.Name+strconv.Itoa(i), ikePSK.ID, ipsec.VpnGateway.TunnelOutsideAddress.IPAddress, ikeGatewayCfg.ID, ikeEncryptionProfile.ID, enterprise)
-						log.Debugf("ikeGatewayProfile: %v", ikeGatewayProfile)
+							ikeGatewayconn, err := nm.createIKEGatewayConnection("TGWCGW"+conn.A.Region+conn.A.Device.Name+conn.A.Name+strconv.Itoa(i), conn.A.Device.Name, ikeGatewayProfile.ID, ikePSK.ID, conn.A.NuageVlan)
+							if err != nil {
+								return fmt.Errorf("Error create ike gateway connection: %s", err)
+							}
+							endpointLog(conn.A).WithField("vsd_id", ikeGatewayconn.ID).Debugf("ikeGatewayconn: %v", ikeGatewayconn)
 
-						ikeGatewayconn := nm.createIKEGatewayConnection("TGWCGW"+conn.A.Region+conn.A.Device.Name+conn.A.Name+strconv.Itoa(i), conn.A.Device.Name, ikeGatewayProfile.ID, ikePSK.ID, conn.A.NuageVlan)
-						log.Debugf("ikeGatewayconn: %v", ikeGatewayconn)
+							// the IKE objects are named after the connection, they are new when the vpn connection is
+							if nm.journal.created("vpn connection", *conn.A.VpnConnectionID) {
+								nm.recordIKEObjects("TGWCGW"+conn.A.Region+conn.A.Device.Name+conn.A.Name+strconv.Itoa(i), conn.A.NuageVlan, enterprise)
+							}
+						}
 					}
+					endpointLog(conn.A).WithField("aws_id", *r.CustomerGateway.CustomerGatewayId).Debugf("Customer Gateway Id: %v", *r.CustomerGateway.CustomerGatewayId)
+					CustomerGatewayArn, err := nm.customerGatewayARN(conn.A.Region, *r.CustomerGateway.CustomerGatewayId)
+					if err != nil {
+						return fmt.Errorf("Error customer gateway arn: %s", err)
+					}
+					conn.A.CustomerGatewayID = r.CustomerGateway.CustomerGatewayId
+					conn.A.VPNConnState = "not available"
+					conn.A.CustomerGatewayARN = &CustomerGatewayArn
+					log.Debugf("Customer Gateway ARN: %v", CustomerGatewayArn)
+
+				}
+			}
+		}
+	}
+
+	nm.checkpoint(phaseVpnConnections)
+
+	if !nm.skipPhase(phaseNuageDomains) {
+		if err := nm.CreateNuageDomains(enterprise); err != nil {
+			return fmt.Errorf("Error create nuage domains: %s", err)
+		}
+	}
+	nm.checkpoint(phaseNuageDomains)
+
+	if !nm.skipPhase(phaseVpnWait) {
+		if err := nm.waitVpnConnections(); err != nil {
+			return fmt.Errorf("Error wait vpn connections: %s", err)
+		}
+	}
+	nm.checkpoint(phaseVpnWait)
+
+	if !nm.skipPhase(phaseRouteTableAssocs) {
+		for _, conn := range nm.Connections {
+			if conn.A.VpnConnectionID == nil || (conn.A.RouteTable == "" && (conn.A.BGP || conn.A.Cidr == "")) {
+				continue
+			}
+			attachmentID, err := nm.waitVpnAttachment(conn.A)
+			if err != nil {
+				return fmt.Errorf("Error wait vpn attachment: %s", err)
+			}
+			if err := nm.associateRouteTable(conn.B.Device, attachmentID, conn.A.RouteTable, true); err != nil {
+				return fmt.Errorf("Error associate vpn attachment: %s", err)
+			}
+			if !conn.A.BGP && conn.A.Cidr != "" {
+				if err := nm.createVpnRoutes(conn.A, conn.B.Device, attachmentID); err != nil {
+					return fmt.Errorf("Error create vpn routes: %s", err)
 				}
-				log.Debugf("Customer Gateway Id: %v", *r.CustomerGateway.CustomerGatewayId)
-				CustomerGatewayArn := "arn:aws:ec2:" + conn.A.Region + ":610303483713:customer-gateway/" + *r.CustomerGateway.CustomerGatewayId
-				conn.A.CustomerGatewayID = r.CustomerGateway.CustomerGatewayId
-				conn.A.VPNConnState = "not available"
-				conn.A.CustomerGatewayARN = &CustomerGatewayArn
-				log.Debugf("Customer Gateway ARN: %v", CustomerGatewayArn)
+			}
+		}
+		time.Sleep(60 * time.Second)
+	}
+	nm.checkpoint(phaseRouteTableAssocs)
 
+	if !nm.skipPhase(phaseCustomerGatewayAssoc) {
+		for _, conn := range nm.Connections {
+			// endpoints without a public ip have no customer gateway
+			if conn.A.Device.Kind == "sdwan" && conn.A.PublicIP != "" && conn.A.CustomerGatewayARN != nil && conn.A.LinkID != nil {
+				endpointLog(conn.A).Infof("Associate Customer GW: %s %s %s %s", *conn.A.CustomerGatewayARN, *conn.A.Device.DeviceID, *conn.A.LinkID, conn.A.Device.Name)
+				_, err = nm.AssociateCustomerGateway(conn.A.CustomerGatewayARN, conn.A.Device.DeviceID, conn.A.LinkID)
+				if err != nil {
+					return fmt.Errorf("Error associate customer gateway: %s", err)
+				}
+				if nm.journal.created("customer gateway", *conn.A.CustomerGatewayID) {
+					ep := conn.A
+					nm.journal.record("customer gateway association", ep.Name, *ep.CustomerGatewayARN, *ep.CustomerGatewayARN, func() error {
+						_, err := nm.DisassociateCustomerGateway(ep.CustomerGatewayARN, ep.Device.DeviceID, ep.LinkID)
+						return err
+					})
+				}
 			}
 		}
 	}
+	nm.checkpoint(phaseCustomerGatewayAssoc)
 
+	return nil
+}
+
+// waitVpnConnections waits until all vpn connections are available, the state is
+// checked before every wait so a resumed deploy does not wait for available connections
+func (nm *NMgr) waitVpnConnections() error {
+	what := "vpn connections available"
+	start := time.Now()
+	defer nm.trackWait(what, start)
 	log.Infof("Checking VPN connection status before we can associate the device/links with the customer GW")
 	state := false
 	i := 0
-	for ok := true; ok; ok = !state {
-		i++
-		log.Infof("Wait a minute to check if all gw are in available state, total (%d min)", i)
-		time.Sleep(60 * time.Second)
+	for {
 		for _, conn := range nm.Connections {
 			if conn.A.Device.Kind == "sdwan" {
 				if conn.A.PublicIP != "" {
 					r, err := nm.DescribeVpnConnections(&conn.A.Region, &conn.A.Name)
 					if err != nil {
-						log.Errorf("Error get vpn connection: %s", err)
+						endpointLog(conn.A).Errorf("Error get vpn connection: %s", err)
+						continue
 					}
 					for _, v := range r.VpnConnections {
-						log.Infof("Customer GW state: %s %s %t", conn.A.Name, v.State, state)
+						if v.State == types.VpnStateDeleted || v.State == types.VpnStateDeleting {
+							// replaced vpn connections are ignored
+							continue
+						}
+						endpointLog(conn.A).WithField("aws_id", strValue(v.VpnConnectionId)).Infof("Customer GW state: %s %s %t", conn.A.Name, v.State, state)
 						if v.State == types.VpnStateAvailable {
-							if conn.A.VPNConnState != "available" {
-								// only assocate the connection to the customer gw once
-							}
 							conn.A.VPNConnState = "available"
 						} else {
 							conn.A.VPNConnState = "not available"
@@ -587,36 +2313,76 @@ func (nm *NMgr) CreateAWSNetworkMgrSites() error {
 				}
 			}
 		}
+		if state {
+			return nil
+		}
+		if err := nm.waitExpired(what, start); err != nil {
+			return err
+		}
+		i++
+		log.Infof("Wait a minute to check if all gw are in available state, total (%d min)", i)
+		time.Sleep(60 * time.Second)
 	}
+}
 
-	time.Sleep(60 * time.Second)
-
-	for _, conn := range nm.Connections {
-		if conn.A.Device.Kind == "sdwan" {
-			log.Infof("Associate Customer GW: %s %s %s %s", *conn.A.CustomerGatewayARN, *conn.A.Device.DeviceID, *conn.A.LinkID, conn.A.Device.Name)
-			_, err = nm.AssociateCustomerGateway(conn.A.CustomerGatewayARN, conn.A.Device.DeviceID, conn.A.LinkID)
-			if err != nil {
-				log.Fatalf("Error associate customer gateway: %s", err)
-			}
-		}
+// getEndpointVlan returns the Nuage vlan of the endpoint on its port of the NSG. It
+// returns an error when the port or vlan does not exist
+func (nm *NMgr) getEndpointVlan(ep *Endpoint, nsGateway *vspk.NSGateway) (*vspk.VLAN, error) {
+	epName := ep.Port
+	nsgPort, err := nm.getNetworkPort(epName, nsGateway)
+	if err != nil {
+		return nil, err
 	}
+	if nsgPort == nil {
+		return nil, fmt.Errorf("Nuage NSG port does not exist: %s %s", nsGateway.Name, epName)
+	}
+	endpointLog(ep).WithField("vsd_id", nsgPort.ID).Debugf("Nuage PORT: %s", nsgPort.ID)
+	ep.NuagePort = nsgPort
 
-	return nil
+	nsVlan, err := nm.getVlan(ep.Vlan, nsgPort)
+	if err != nil {
+		return nil, err
+	}
+	if nsVlan == nil {
+		return nil, fmt.Errorf("Nuage NSG vlan does not exist: %s %s %d", nsGateway.Name, epName, ep.Vlan)
+	}
+	return nsVlan, nil
 }
 
 // DeleteAWSNetworkMgrSites function
 func (nm *NMgr) DeleteAWSNetworkMgrSites() error {
 	r, err := nm.DescribeGlobalNetworks()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	enterprise := nm.getEnterprise(nm.Config.Nuage.Enterprise)
+	enterprise, err := nm.getEnterprise(nm.Config.Nuage.Enterprise)
+	if err != nil {
+		return err
+	}
 	if enterprise == nil {
-		log.Errorf("Enterprise does not exist : %s", nm.Config.Nuage.Enterprise)
+		return fmt.Errorf("Enterprise does not exist : %s", nm.Config.Nuage.Enterprise)
 	}
 	log.Debugf("Enterprise ID : %v", enterprise.ID)
 
+	log.Infof("Deleting Nuage domain routes....")
+	if err := nm.DeleteNuageDomains(enterprise); err != nil {
+		log.Errorf("Error deleting Nuage domain routes: %s", err)
+	}
+
+	// vpcs, peerings and route tables are shared by all branches
+	if nm.selectedAll() {
+		log.Infof("Deleting VPC attachments....")
+		if err := nm.DeleteAWSVpcs(); err != nil {
+			log.Errorf("Error deleting VPC attachments: %s", err)
+		}
+
+		log.Infof("Deleting TGW peerings....")
+		if err := nm.DeleteAWSPeerings(); err != nil {
+			log.Errorf("Error deleting TGW peerings: %s", err)
+		}
+	}
+
 	//if len(r.GlobalNetworks) > 0 {
 	for idx, g := range r.GlobalNetworks {
 		for i := 0; i < len(g.Tags); i++ {
@@ -629,50 +2395,41 @@ func (nm *NMgr) DeleteAWSNetworkMgrSites() error {
 		}
 	}
 	if nm.GlobalNetworkID != nil {
-		// get the site ID, device ID(s), Link ID(s) from the AWS to remove the associations
-		r, err := nm.GetSites()
+		// get the site ID, device ID(s), Link ID(s) from the inventory to remove the associations
+		sites, err := nm.siteInventory()
 		if err != nil {
-			log.Fatal(err)
-		}
-		for _, sa := range r.Sites {
-			for i := 0; i < len(sa.Tags); i++ {
-				if *sa.Tags[i].Key == "Name" {
-					for _, s := range nm.Sites {
-						if *sa.Tags[i].Value == s.Name {
-							log.Infof("Site exists")
-							s.SiteID = sa.SiteId
-							r, err := nm.GetDevice(s.SiteID)
-							if err != nil {
-								log.Fatal(err)
-							}
-							l, err := nm.GetLink(s.SiteID)
-							if err != nil {
-								log.Fatal(err)
-							}
-							for _, da := range r.Devices {
-								for i := 0; i < len(da.Tags); i++ {
-									for _, d := range nm.Devices {
-										if *da.Tags[i].Value == d.Name {
-											log.Debugf("Device exists")
-											d.Site = s
-											d.DeviceID = da.DeviceId
-											d.DeviceARN = da.DeviceArn
-										}
-										for _, la := range l.Links {
-											log.Debugf("AWS LINK INFO: %s, %s", *la.LinkId, *la.Description)
-											for i := 0; i < len(la.Tags); i++ {
-												for n, ep := range d.Endpoints {
-													if *la.Tags[i].Value == n {
-														log.Debugf("Link exists on device")
-														ep.LinkID = la.LinkId
-														ep.LinkARN = la.LinkArn
-
-													}
-												}
-											}
-										}
-									}
-								}
+			return err
+		}
+		devices, err := nm.deviceInventory()
+		if err != nil {
+			return err
+		}
+		links, err := nm.linkInventory()
+		if err != nil {
+			return err
+		}
+		for _, s := range nm.Sites {
+			sa := sites.name(s.Name)
+			if sa == nil {
+				continue
+			}
+			siteLog(s.Name).WithField("aws_id", strValue(sa.SiteId)).Infof("Site exists")
+			s.SiteID = sa.SiteId
+			for _, d := range nm.Devices {
+				for _, da := range devices.named(d.Name) {
+					if strValue(da.SiteId) != *s.SiteID {
+						continue
+					}
+					log.Debugf("Device exists")
+					d.Site = s
+					d.DeviceID = da.DeviceId
+					d.DeviceARN = da.DeviceArn
+					for n, ep := range d.Endpoints {
+						for _, la := range links.named(n) {
+							if strValue(la.SiteId) == *s.SiteID {
+								log.Debugf("Link exists on device")
+								ep.LinkID = la.LinkId
+								ep.LinkARN = la.LinkArn
 							}
 						}
 					}
@@ -680,8 +2437,14 @@ func (nm *NMgr) DeleteAWSNetworkMgrSites() error {
 			}
 		}
 		rc, err := nm.GetCustomerGatewayAssociations()
+		if err != nil {
+			return err
+		}
 		for _, c := range rc.CustomerGatewayAssociations {
-			log.Infof("Customer gateway DisAssociation: %s, %s, %s", *c.CustomerGatewayArn, *c.DeviceId, *c.LinkId)
+			if !nm.selectedAll() && !nm.selectedLink(strValue(c.LinkId)) {
+				continue
+			}
+			log.WithField("aws_id", *c.CustomerGatewayArn).Infof("Customer gateway DisAssociation: %s, %s, %s", *c.CustomerGatewayArn, *c.DeviceId, *c.LinkId)
 			_, err := nm.DisassociateCustomerGateway(c.CustomerGatewayArn, c.DeviceId, c.LinkId)
 			if err != nil {
 				log.Error(err)
@@ -692,33 +2455,33 @@ func (nm *NMgr) DeleteAWSNetworkMgrSites() error {
 			if d.DeviceID != nil && d.Site.SiteID != nil {
 				log.Debugf("Device Name: %s, %s, %s", d.Name, *d.DeviceID, *d.Site.SiteID)
 
-				nsGateway := nm.getNsg(deviceName, enterprise)
+				nsGateway, err := nm.getNsg(deviceName, enterprise)
+				if err != nil {
+					return err
+				}
 				if nsGateway == nil {
-					log.Errorf("Nuage NSG device does not exist: %s", deviceName)
+					deviceLog(d).Errorf("Nuage NSG device does not exist: %s", deviceName)
+				} else {
+					log.Debugf("Nuage NSG ID: %s", nsGateway.ID)
+					d.NuageNSGateway = nsGateway
 				}
-				log.Debugf("Nuage NSG ID: %s", nsGateway.ID)
-				d.NuageNSGateway = nsGateway
 
-				for epName, ep := range d.Endpoints {
+				for _, ep := range d.Endpoints {
 					if ep.LinkID != nil {
 						log.Debugf("Link Name: %s, %s", ep.Name, *ep.LinkID)
 
-						nsgPort := nm.getNetworkPort(epName, nsGateway)
-						if nsgPort == nil {
-							log.Errorf("Nuage NSG port does not exist: %s", epName)
-						}
-						log.Debugf("Nuage PORT: %s", nsgPort.ID)
-						ep.NuagePort = nsgPort
-
-						nsVlan := nm.getVlan(0, nsgPort)
-						if nsVlan == nil {
-							log.Errorf("Nuage NSG vlan does not exist: 0")
+						if nsGateway != nil {
+							nsVlan, err := nm.getEndpointVlan(ep, nsGateway)
+							if err != nil {
+								endpointLog(ep).Errorf("%s", err)
+							} else {
+								log.Debugf("Nuage VLAN: %s", nsVlan.ID)
+								ep.NuageVlan = nsVlan
+							}
 						}
-						log.Debugf("Nuage VLAN: %s", nsVlan.ID)
-						ep.NuageVlan = nsVlan
 
 						if _, err := nm.DisassociateLink(d.DeviceID, ep.LinkID); err != nil {
-							log.Errorf("Error disassociating links: %s", err)
+							endpointLog(ep).Errorf("Error disassociating links: %s", err)
 						}
 					}
 				}
@@ -727,17 +2490,21 @@ func (nm *NMgr) DeleteAWSNetworkMgrSites() error {
 			}
 		}
 
-		log.Infof("Deleting Links....")
-		if err := nm.DeleteLinks(); err != nil {
-			log.Errorf("Error deleting links: %s", err)
-		}
-		log.Infof("Deleting Devices....")
-		if err := nm.DeleteDevices(); err != nil {
-			log.Errorf("Error deleting Devices: %s", err)
-		}
-		log.Infof("Deleting Sites....")
-		if err := nm.DeleteSites(); err != nil {
-			log.Errorf("Error deleting Sites: %s", err)
+		if nm.selectedAll() {
+			log.Infof("Deleting Links....")
+			if err := nm.DeleteLinks(); err != nil {
+				log.Errorf("Error deleting links: %s", err)
+			}
+			log.Infof("Deleting Devices....")
+			if err := nm.DeleteDevices(); err != nil {
+				log.Errorf("Error deleting Devices: %s", err)
+			}
+			log.Infof("Deleting Sites....")
+			if err := nm.DeleteSites(); err != nil {
+				log.Errorf("Error deleting Sites: %s", err)
+			}
+		} else {
+			nm.deleteSelectedSites()
 		}
 		for _, conn := range nm.Connections {
 			if conn.A.Device.Kind == "sdwan" {
@@ -745,59 +2512,234 @@ func (nm *NMgr) DeleteAWSNetworkMgrSites() error {
 					if conn.B.Device.Kind == "tgw" {
 						r, err := nm.DescribeVpnConnections(&conn.A.Region, &conn.A.Name)
 						if err != nil {
-							log.Fatalf("Error describe vpn connection: %s", err)
+							return fmt.Errorf("Error describe vpn connection: %s", err)
 						}
 						for _, c := range r.VpnConnections {
-							log.Infof("Delete Vpn Connection....")
+							if !conn.A.BGP && conn.A.Cidr != "" {
+								if err := nm.deleteVpnRoutes(conn.A, conn.B.Device, c.VpnConnectionId); err != nil {
+									endpointLog(conn.A).Errorf("Error deleting the Transit GW routes of the vpn connection: %s", err)
+								}
+							}
+							endpointLog(conn.A).WithField("aws_id", strValue(c.VpnConnectionId)).Infof("Delete Vpn Connection....")
 							_, err = nm.DeleteVpnConnection(&conn.A.Region, c.VpnConnectionId)
 							if err != nil {
-								log.Fatal(err)
+								return err
 							}
 
 							for i := 0; i < 2; i++ {
-								err = nm.deleteIKEGatewayConnection("TGWCGW"+conn.A.Region+conn.A.Device.Name+conn.A.Name+strconv.Itoa(i), conn.A.NuageVlan)
-								if err != nil {
-									log.Errorf("delete deleteIKEGatewayConnection error: %v", err)
+								if conn.A.NuageVlan != nil {
+									err = nm.deleteIKEGatewayConnection("TGWCGW"+conn.A.Region+conn.A.Device.Name+conn.A.Name+strconv.Itoa(i), conn.A.NuageVlan)
+									if err != nil {
+										endpointLog(conn.A).Errorf("delete deleteIKEGatewayConnection error: %v", err)
+									}
 								}
 
 								err = nm.deleteIKEGatewayProfile("TGWCGW"+conn.A.Region+conn.A.Device.Name+conn.A.Name+strconv.Itoa(i), enterprise)
 								if err != nil {
-									log.Errorf("delete ikeGatewayProfile error: %v", err)
+									endpointLog(conn.A).Errorf("delete ikeGatewayProfile error: %v", err)
 								}
 
 								err = nm.deleteIKEGateway("TGWCGW"+conn.A.Region+conn.A.Device.Name+conn.A.Name+strconv.Itoa(i), enterprise)
 								if err != nil {
-									log.Errorf("deleteIKEGateway error: %v", err)
+									endpointLog(conn.A).Errorf("deleteIKEGateway error: %v", err)
 								}
 							}
 						}
 					}
 					r, err := nm.DescribeCustomerGateways(&conn.A.Region, &conn.A.Name)
 					if err != nil {
-						log.Fatalf("Error describe customer gateway: %s", err)
+						return fmt.Errorf("Error describe customer gateway: %s", err)
 					}
 					for _, c := range r.CustomerGateways {
-						log.Infof("Delete Customer Gateway....")
+						endpointLog(conn.A).WithField("aws_id", strValue(c.CustomerGatewayId)).Infof("Delete Customer Gateway....")
 						_, err = nm.DeleteCustomerGateway(&conn.A.Region, c.CustomerGatewayId)
 						if err != nil {
-							log.Fatal(err)
+							return err
 						}
 					}
 				}
 			}
 		}
 
-		err = nm.deleteIKEEncryptionprofile("AWS-"+nm.Config.Name, enterprise)
-		if err != nil {
-			log.Errorf("Error deleting Encryption profile: %s", err)
+		log.Infof("Deleting Nuage redundant gateway groups....")
+		if err := nm.DeleteNuageRedundancy(enterprise); err != nil {
+			log.Errorf("Error deleting Nuage redundant gateway groups: %s", err)
 		}
-		err = nm.deleteIKEPSK("AWS-"+nm.Config.Name+"PSK", enterprise)
-		if err != nil {
-			log.Errorf("Error deleting PSK: %s", err)
+
+		// the psk and encryption profile are shared by all branches
+		if nm.selectedAll() {
+			err = nm.deleteIKEEncryptionprofile("AWS-"+nm.Config.Name, enterprise)
+			if err != nil {
+				log.Errorf("Error deleting Encryption profile: %s", err)
+			}
+			err = nm.deleteIKEPSK("AWS-"+nm.Config.Name+"PSK", enterprise)
+			if err != nil {
+				log.Errorf("Error deleting PSK: %s", err)
+			}
 		}
 
 	} else {
 		log.Infof("Nothing to delete....")
 	}
+
+	if nm.selectedAll() {
+		log.Infof("Deleting TGW route tables....")
+		if err := nm.DeleteAWSRouteTables(); err != nil {
+			log.Errorf("Error deleting TGW route tables: %s", err)
+		}
+	}
+	return nil
+}
+
+// waitVpnConnectionAvailable waits till the vpn connection is available again after a
+// modification of its tunnel options
+func (nm *NMgr) waitVpnConnectionAvailable(region, vpnID *string) error {
+	what := "vpn connection available " + *vpnID
+	start := time.Now()
+	defer nm.trackWait(what, start)
+	for {
+		r, err := nm.DescribeVpnConnection(region, vpnID)
+		if err != nil {
+			return err
+		}
+		if len(r.VpnConnections) == 0 {
+			return fmt.Errorf("vpn connection not found: %s", *vpnID)
+		}
+		if r.VpnConnections[0].State == types.VpnStateAvailable {
+			return nil
+		}
+		if err := nm.waitExpired(what, start); err != nil {
+			return err
+		}
+		log.Infof("Wait 30 seconds till the vpn connection is available: %s %s", *vpnID, r.VpnConnections[0].State)
+		time.Sleep(30 * time.Second)
+	}
+}
+
+// waitVpnConnectionDeleted waits till the vpn connection is deleted
+func (nm *NMgr) waitVpnConnectionDeleted(region, vpnID *string) error {
+	what := "vpn connection deleted " + *vpnID
+	start := time.Now()
+	defer nm.trackWait(what, start)
+	for {
+		r, err := nm.DescribeVpnConnection(region, vpnID)
+		if err != nil {
+			return err
+		}
+		if len(r.VpnConnections) == 0 || r.VpnConnections[0].State == types.VpnStateDeleted {
+			return nil
+		}
+		if err := nm.waitExpired(what, start); err != nil {
+			return err
+		}
+		log.Infof("Wait 30 seconds till the vpn connection is deleted: %s %s", *vpnID, r.VpnConnections[0].State)
+		time.Sleep(30 * time.Second)
+	}
+}
+
+// waitVpnAttachment waits till the tgw attachment of the vpn connection of the endpoint
+// is available and returns its id, a failed or rejected attachment fails the wait
+func (nm *NMgr) waitVpnAttachment(ep *Endpoint) (*string, error) {
+	what := "vpn attachment available " + ep.Name
+	start := time.Now()
+	defer nm.trackWait(what, start)
+	i := 0
+	for {
+		r, err := nm.DescribeTransitGatewayAttachmentsByResource(&ep.Region, ep.VpnConnectionID)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range r.TransitGatewayAttachments {
+			switch a.State {
+			case types.TransitGatewayAttachmentStateAvailable:
+				return a.TransitGatewayAttachmentId, nil
+			case types.TransitGatewayAttachmentStateFailed, types.TransitGatewayAttachmentStateRejected:
+				return nil, fmt.Errorf("vpn attachment %s of %s is in %s state", strValue(a.TransitGatewayAttachmentId), ep.Name, a.State)
+			}
+		}
+		if err := nm.waitExpired(what, start); err != nil {
+			return nil, err
+		}
+		i++
+		endpointLog(ep).Infof("Wait 30 seconds to check if the vpn attachment of %s is available, total (%d sec)", ep.Name, i*30)
+		time.Sleep(30 * time.Second)
+	}
+}
+
+// createVpnRoutes adds a static route for the cidr of a static routed branch towards its
+// vpn attachment in the route tables of the tgw that receive the routes of its segment.
+// A blackhole route of a deleted vpn is replaced, a cidr that is routed towards another
+// attachment, like the vpn of the redundant NSG, is left in place
+func (nm *NMgr) createVpnRoutes(ep *Endpoint, tgw *Device, attachmentID *string) error {
+	for _, rtID := range nm.segmentRouteTables(tgw, ep.RouteTable) {
+		if rtID == nil {
+			return fmt.Errorf("transit GW %s has no default route table", tgw.Name)
+		}
+		r, err := nm.SearchTransitGatewayStaticRoutes(&tgw.Region, rtID)
+		if err != nil {
+			return err
+		}
+		if tgwRouteExists(r.Routes, ep.Cidr, *attachmentID) {
+			continue
+		}
+		routed := false
+		for _, route := range r.Routes {
+			if route.DestinationCidrBlock == nil || *route.DestinationCidrBlock != ep.Cidr {
+				continue
+			}
+			if route.State != types.TransitGatewayRouteStateBlackhole {
+				routed = true
+				continue
+			}
+			log.Infof("Delete blackhole Transit GW route: %s %s %s", tgw.Name, *rtID, ep.Cidr)
+			if _, err := nm.DeleteTransitGatewayStaticRoute(&tgw.Region, rtID, &ep.Cidr); err != nil {
+				return err
+			}
+		}
+		if routed {
+			log.Infof("Transit GW route exists towards another attachment, leaving it in place: %s %s %s", tgw.Name, *rtID, ep.Cidr)
+			continue
+		}
+		cidr := ep.Cidr
+		log.Infof("Create Transit GW route: %s %s %s -> %s", tgw.Name, *rtID, cidr, *attachmentID)
+		if _, err := nm.CreateTransitGatewayStaticRoute(&tgw.Region, rtID, &cidr, attachmentID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteVpnRoutes deletes the static routes towards the vpn attachment of the vpn
+// connection from the route tables of the tgw
+func (nm *NMgr) deleteVpnRoutes(ep *Endpoint, tgw *Device, vpnID *string) error {
+	found, err := nm.findTransitGateway(tgw)
+	if err != nil || !found {
+		return err
+	}
+	if err := nm.findRouteTables(tgw); err != nil {
+		return err
+	}
+	r, err := nm.DescribeTransitGatewayAttachmentsByResource(&tgw.Region, vpnID)
+	if err != nil {
+		return err
+	}
+	for _, a := range r.TransitGatewayAttachments {
+		for _, rtID := range nm.segmentRouteTables(tgw, ep.RouteTable) {
+			if rtID == nil {
+				continue
+			}
+			rr, err := nm.SearchTransitGatewayStaticRoutes(&tgw.Region, rtID)
+			if err != nil {
+				return err
+			}
+			if tgwRouteExists(rr.Routes, ep.Cidr, *a.TransitGatewayAttachmentId) {
+				cidr := ep.Cidr
+				log.Infof("Delete Transit GW route: %s %s %s", tgw.Name, *rtID, cidr)
+				if _, err := nm.DeleteTransitGatewayStaticRoute(&tgw.Region, rtID, &cidr); err != nil {
+					return err
+				}
+			}
+		}
+	}
 	return nil
 }
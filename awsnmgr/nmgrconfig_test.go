@@ -0,0 +1,94 @@
+package awsnmgr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseIKEVersion(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: "", want: defaultIKEVersion},
+		{in: "v1", want: "V1"},
+		{in: "V2", want: "V2"},
+		{in: "2", want: "V2"},
+		{in: "ikev1", want: "V1"},
+		{in: "IKEv2", want: "V2"},
+		{in: "v3", err: true},
+		{in: "ike", err: true},
+	} {
+		got, err := parseIKEVersion(tc.in)
+		if (err != nil) != tc.err {
+			t.Errorf("parseIKEVersion(%q) error = %v, want error %t", tc.in, err, tc.err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("parseIKEVersion(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+// tunnelNMgr returns a NMgr with a connection per tunnel config list
+func tunnelNMgr(tunnels ...[]TunnelConfig) *NMgr {
+	nm := &NMgr{Connections: make(map[int]*Connection)}
+	for i, t := range tunnels {
+		nm.Connections[i] = &Connection{A: &Endpoint{Name: "ep" + string(rune('1'+i)), Tunnels: t}}
+	}
+	return nm
+}
+
+func TestValidateTunnels(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		tunnels [][]TunnelConfig
+		err     string
+	}{
+		{
+			name: "valid",
+			tunnels: [][]TunnelConfig{
+				{{InsideCidr: "169.254.100.0/30", DPDTimeout: 30, DPDTimeoutAction: "restart"}, {InsideCidr: "169.254.100.4/30"}},
+				{{InsideCidr: "169.254.101.0/30", RekeyMarginTime: 540, ReplayWindowSize: 1024, StartupAction: "start"}},
+			},
+		},
+		{name: "no options", tunnels: [][]TunnelConfig{{{}, {}}}},
+		{name: "three tunnels", tunnels: [][]TunnelConfig{{{}, {}, {}}}, err: "has 3 tunnels"},
+		{name: "invalid cidr", tunnels: [][]TunnelConfig{{{InsideCidr: "169.254.100.0"}}}, err: "invalid CIDR"},
+		{name: "not a /30", tunnels: [][]TunnelConfig{{{InsideCidr: "169.254.100.0/29"}}}, err: "is not a /30"},
+		{name: "not link local", tunnels: [][]TunnelConfig{{{InsideCidr: "10.0.0.0/30"}}}, err: "is not a /30"},
+		{name: "reserved", tunnels: [][]TunnelConfig{{{InsideCidr: "169.254.5.0/30"}}}, err: "reserved by AWS"},
+		{
+			name:    "duplicate in connection",
+			tunnels: [][]TunnelConfig{{{InsideCidr: "169.254.100.0/30"}, {InsideCidr: "169.254.100.0/30"}}},
+			err:     "already used by connection 'ep1' tunnel 0",
+		},
+		{
+			name:    "duplicate across connections",
+			tunnels: [][]TunnelConfig{{{InsideCidr: "169.254.100.0/30"}}, {{InsideCidr: "169.254.100.1/30"}}},
+			err:     "already used by connection 'ep1' tunnel 0",
+		},
+		{name: "dpd timeout action", tunnels: [][]TunnelConfig{{{DPDTimeoutAction: "drop"}}}, err: "dpd-timeout-action 'drop'"},
+		{name: "startup action", tunnels: [][]TunnelConfig{{{StartupAction: "wait"}}}, err: "startup-action 'wait'"},
+		{name: "rekey margin low", tunnels: [][]TunnelConfig{{{RekeyMarginTime: 59}}}, err: "rekey-margin-time"},
+		{name: "rekey margin high", tunnels: [][]TunnelConfig{{{RekeyMarginTime: phase2Lifetime/2 + 1}}}, err: "rekey-margin-time"},
+		{name: "rekey margin bounds", tunnels: [][]TunnelConfig{{{RekeyMarginTime: 60}, {RekeyMarginTime: phase2Lifetime / 2}}}},
+		{name: "dpd timeout", tunnels: [][]TunnelConfig{{{DPDTimeout: 29}}}, err: "dpd-timeout needs"},
+		{name: "replay window low", tunnels: [][]TunnelConfig{{{ReplayWindowSize: 63}}}, err: "replay-window-size"},
+		{name: "replay window high", tunnels: [][]TunnelConfig{{{ReplayWindowSize: 2049}}}, err: "replay-window-size"},
+		{name: "replay window bounds", tunnels: [][]TunnelConfig{{{ReplayWindowSize: 64}, {ReplayWindowSize: 2048}}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tunnelNMgr(tc.tunnels...).validateTunnels()
+			switch {
+			case tc.err == "" && err != nil:
+				t.Errorf("validateTunnels: %v", err)
+			case tc.err != "" && err == nil:
+				t.Errorf("validateTunnels succeeded, want error %q", tc.err)
+			case tc.err != "" && !strings.Contains(err.Error(), tc.err):
+				t.Errorf("validateTunnels error = %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
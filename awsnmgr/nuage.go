@@ -1,16 +1,16 @@
 package awsnmgr
 
 import (
+	"fmt"
 	"net"
 
 	log "github.com/sirupsen/logrus"
 
-	nuagewrapper "github.com/henderiw/nuage-wrapper"
 	"github.com/henderiw/nuage-wrapper/pkg/vspk"
 	"github.com/nuagenetworks/go-bambou/bambou"
 )
 
-func (nm *NMgr) enterpriseProfile(name string) *vspk.EnterpriseProfile {
+func (nm *NMgr) enterpriseProfile(name string) (*vspk.EnterpriseProfile, error) {
 	enterpriseProfileCfg := map[string]interface{}{
 		"Name":                                   name,
 		"Description":                            name,
@@ -27,10 +27,16 @@ func (nm *NMgr) enterpriseProfile(name string) *vspk.EnterpriseProfile {
 		"AllowedForwardingClasses":               []interface{}{'A', 'B', 'C', 'D', 'E', 'F', 'G'},
 	}
 
-	return nuagewrapper.Enterpriseprofile(enterpriseProfileCfg, nm.VsdUsr)
+	p, _, err := vsdCreateOrUpdate(nm, "enterpriseprofiles", "EnterpriseProfile", enterpriseProfileCfg, &vspk.EnterpriseProfile{},
+		func() ([]*vspk.EnterpriseProfile, *bambou.Error) {
+			return nm.VsdUsr.EnterpriseProfiles(&bambou.FetchingInfo{Filter: name})
+		},
+		func(p *vspk.EnterpriseProfile) bool { return p.Name == name },
+		nm.VsdUsr.CreateEnterpriseProfile)
+	return p, err
 }
 
-func (nm *NMgr) enterprise(name string, localAS int, entProfile *vspk.EnterpriseProfile) *vspk.Enterprise {
+func (nm *NMgr) enterprise(name string, localAS int, entProfile *vspk.EnterpriseProfile) (*vspk.Enterprise, error) {
 	enterpriseCfg := map[string]interface{}{
 		"Name":                  name,
 		"LocalAS":               localAS,
@@ -38,54 +44,126 @@ func (nm *NMgr) enterprise(name string, localAS int, entProfile *vspk.Enterprise
 		"FlowCollectionEnabled": "ENABLED",
 	}
 
-	return nuagewrapper.Enterprise(enterpriseCfg, nm.VsdUsr)
+	e, _, err := vsdCreateOrUpdate(nm, "enterprises", "Enterprise", enterpriseCfg, &vspk.Enterprise{},
+		func() ([]*vspk.Enterprise, *bambou.Error) {
+			return nm.VsdUsr.Enterprises(&bambou.FetchingInfo{Filter: name})
+		},
+		func(e *vspk.Enterprise) bool { return e.Name == name },
+		nm.VsdUsr.CreateEnterprise)
+	return e, err
 }
 
-func (nm *NMgr) getEnterprise(name string) *vspk.Enterprise {
-	enterpriseCfg := map[string]interface{}{
-		"Name": name,
-	}
-	return nuagewrapper.GetEnterprise(enterpriseCfg, nm.VsdUsr)
+// getEnterprise returns the enterprise with the name, nil when it does not exist
+func (nm *NMgr) getEnterprise(name string) (*vspk.Enterprise, error) {
+	e, _, err := vsdFind(nm, "enterprises",
+		func() ([]*vspk.Enterprise, *bambou.Error) {
+			return nm.VsdUsr.Enterprises(&bambou.FetchingInfo{Filter: name})
+		},
+		func(e *vspk.Enterprise) bool { return e.Name == name })
+	return e, err
 }
 
-func (nm *NMgr) deleteEnterprise(name string) *vspk.Enterprise {
-	enterpriseCfg := map[string]interface{}{
-		"Name": name,
-	}
-	return nuagewrapper.DeleteEnterprise(enterpriseCfg, nm.VsdUsr)
+func (nm *NMgr) deleteEnterprise(name string) error {
+	_, err := vsdDeleteAll(nm, "enterprises", "Enterprise",
+		func() ([]*vspk.Enterprise, *bambou.Error) {
+			return nm.VsdUsr.Enterprises(&bambou.FetchingInfo{Filter: name})
+		},
+		func(e *vspk.Enterprise) bool { return e.Name == name })
+	return err
 }
 
-func (nm *NMgr) domainTemplate(name string, enterprise *vspk.Enterprise) *vspk.DomainTemplate {
+func (nm *NMgr) domainTemplate(name string, enterprise *vspk.Enterprise) (*vspk.DomainTemplate, error) {
 	domainTemplateCfg := map[string]interface{}{
 		"Name":       name,
 		"DPI":        "ENABLED",
 		"Encryption": "ENABLED",
 	}
 
-	return nuagewrapper.DomainTemplate(domainTemplateCfg, enterprise)
+	t, created, err := vsdCreateOrUpdate(nm, "domaintemplates", "DomainTemplate", domainTemplateCfg, &vspk.DomainTemplate{},
+		func() ([]*vspk.DomainTemplate, *bambou.Error) {
+			return enterprise.DomainTemplates(&bambou.FetchingInfo{Filter: name})
+		},
+		func(t *vspk.DomainTemplate) bool { return t.Name == name },
+		enterprise.CreateDomainTemplate)
+	if created {
+		nm.created("nuage domain template", name, t.ID, "")
+	}
+	return t, err
 }
 
-func (nm *NMgr) domain(name string, domainTemplate *vspk.DomainTemplate, enterprise *vspk.Enterprise) *vspk.Domain {
+func (nm *NMgr) domain(name string, domainTemplate *vspk.DomainTemplate, enterprise *vspk.Enterprise) (*vspk.Domain, error) {
 	domainCfg := map[string]interface{}{
-		"Name":       name,
-		"DPI":        "ENABLED",
-		"Encryption": "ENABLED",
-		"TemplateID": domainTemplate.ID,
+		"Name":            name,
+		"DPI":             "ENABLED",
+		"Encryption":      "ENABLED",
+		"UnderlayEnabled": "ENABLED",
+		"TemplateID":      domainTemplate.ID,
 	}
 
-	return nuagewrapper.Domain(domainCfg, enterprise)
+	d, created, err := vsdCreateOrUpdate(nm, "domains", "Domain", domainCfg, &vspk.Domain{},
+		func() ([]*vspk.Domain, *bambou.Error) {
+			return enterprise.Domains(&bambou.FetchingInfo{Filter: name})
+		},
+		func(d *vspk.Domain) bool { return d.Name == name },
+		enterprise.CreateDomain)
+	if created {
+		nm.created("nuage domain", name, d.ID, "")
+	}
+	return d, err
 }
 
-func (nm *NMgr) zone(name string, domain *vspk.Domain) *vspk.Zone {
+// getDomain returns the domain with the name, nil when it does not exist
+func (nm *NMgr) getDomain(name string, enterprise *vspk.Enterprise) (*vspk.Domain, error) {
+	d, _, err := vsdFind(nm, "domains",
+		func() ([]*vspk.Domain, *bambou.Error) {
+			return enterprise.Domains(&bambou.FetchingInfo{Filter: name})
+		},
+		func(d *vspk.Domain) bool { return d.Name == name })
+	return d, err
+}
+
+func (nm *NMgr) zone(name string, domain *vspk.Domain) (*vspk.Zone, error) {
 	zoneCfg := map[string]interface{}{
 		"Name": name,
 	}
 
-	return nuagewrapper.Zone(zoneCfg, domain)
+	z, created, err := vsdCreateOrUpdate(nm, "zones", "Zone", zoneCfg, &vspk.Zone{},
+		func() ([]*vspk.Zone, *bambou.Error) {
+			return domain.Zones(&bambou.FetchingInfo{Filter: name})
+		},
+		func(z *vspk.Zone) bool { return z.Name == name },
+		domain.CreateZone)
+	if created {
+		nm.created("nuage zone", name, z.ID, "")
+	}
+	return z, err
+}
+
+// getZone returns the zone with the name, nil when it does not exist
+func (nm *NMgr) getZone(name string, domain *vspk.Domain) (*vspk.Zone, error) {
+	z, _, err := vsdFind(nm, "zones",
+		func() ([]*vspk.Zone, *bambou.Error) {
+			return domain.Zones(&bambou.FetchingInfo{Filter: name})
+		},
+		func(z *vspk.Zone) bool { return z.Name == name })
+	return z, err
 }
 
-func (nm *NMgr) subnet(name, ip string, zone *vspk.Zone) *vspk.Subnet {
-	ipv4Addr, ipv4Net, _ := net.ParseCIDR(ip)
+// getSubnet returns the subnet with the name, nil when it does not exist
+func (nm *NMgr) getSubnet(name string, zone *vspk.Zone) (*vspk.Subnet, error) {
+	s, _, err := vsdFind(nm, "subnets",
+		func() ([]*vspk.Subnet, *bambou.Error) {
+			return zone.Subnets(&bambou.FetchingInfo{Filter: name})
+		},
+		func(s *vspk.Subnet) bool { return s.Name == name })
+	return s, err
+}
+
+func (nm *NMgr) subnet(name, ip string, zone *vspk.Zone) (*vspk.Subnet, error) {
+	ipv4Addr, ipv4Net, err := net.ParseCIDR(ip)
+	if err != nil {
+		return nil, err
+	}
 	log.Debugf("ipv4Addr: %s, ipv4Net:%s \n", ipv4Addr, ipv4Net)
 	log.Debugf("ipv4Net IP: %s \n", ipv4Net.IP)
 	log.Debugf("ipv4Net Mask: %s \n", ipv4Net.Mask)
@@ -108,10 +186,16 @@ func (nm *NMgr) subnet(name, ip string, zone *vspk.Zone) *vspk.Subnet {
 		"Advertise":       true,
 		"EnableDHCPv4":    true,
 	}
-	return nuagewrapper.Subnet(subnetCfg, zone)
+	s, _, err := vsdCreateOrUpdate(nm, "subnets", "Subnet", subnetCfg, &vspk.Subnet{},
+		func() ([]*vspk.Subnet, *bambou.Error) {
+			return zone.Subnets(&bambou.FetchingInfo{Filter: name})
+		},
+		func(s *vspk.Subnet) bool { return s.Name == name },
+		zone.CreateSubnet)
+	return s, err
 }
 
-func (nm *NMgr) ingressACLTemplate(name string, priority int, domain *vspk.Domain) *vspk.IngressACLTemplate {
+func (nm *NMgr) ingressACLTemplate(name string, priority int, domain *vspk.Domain) (*vspk.IngressACLTemplate, error) {
 	ingressACLTemplateCfg := map[string]interface{}{
 		"Name":              name,
 		"Description":       name,
@@ -123,10 +207,16 @@ func (nm *NMgr) ingressACLTemplate(name string, priority int, domain *vspk.Domai
 		"Priority":          priority,
 		"PriorityType":      "NONE",
 	}
-	return nuagewrapper.IngressACLTemplate(ingressACLTemplateCfg, domain)
+	t, _, err := vsdCreateOrUpdate(nm, "ingressacltemplates", "IngressACLTemplate", ingressACLTemplateCfg, &vspk.IngressACLTemplate{},
+		func() ([]*vspk.IngressACLTemplate, *bambou.Error) {
+			return domain.IngressACLTemplates(&bambou.FetchingInfo{Filter: name})
+		},
+		func(t *vspk.IngressACLTemplate) bool { return t.Name == name },
+		domain.CreateIngressACLTemplate)
+	return t, err
 }
 
-func (nm *NMgr) egressACLTemplate(name string, priority int, domain *vspk.Domain) *vspk.EgressACLTemplate {
+func (nm *NMgr) egressACLTemplate(name string, priority int, domain *vspk.Domain) (*vspk.EgressACLTemplate, error) {
 	egressACLTemplateCfg := map[string]interface{}{
 		"Name":                           name,
 		"Description":                    name,
@@ -139,82 +229,177 @@ func (nm *NMgr) egressACLTemplate(name string, priority int, domain *vspk.Domain
 		"Priority":                       priority,
 		"PriorityType":                   "NONE",
 	}
-	return nuagewrapper.EgressACLTemplate(egressACLTemplateCfg, domain)
+	t, _, err := vsdCreateOrUpdate(nm, "egressacltemplates", "EgressACLTemplate", egressACLTemplateCfg, &vspk.EgressACLTemplate{},
+		func() ([]*vspk.EgressACLTemplate, *bambou.Error) {
+			return domain.EgressACLTemplates(&bambou.FetchingInfo{Filter: name})
+		},
+		func(t *vspk.EgressACLTemplate) bool { return t.Name == name },
+		domain.CreateEgressACLTemplate)
+	return t, err
 }
 
-func (nm *NMgr) assignAddressrange(subnet *vspk.Subnet, start, end string) {
-	addressRanges, _ := subnet.AddressRanges(&bambou.FetchingInfo{})
-	if addressRanges == nil {
-		addressRange := &vspk.AddressRange{}
-		addressRange.DHCPPoolType = "BRIDGE"
-		addressRange.IPType = "IPV4"
-		addressRange.MaxAddress = end
-		addressRange.MinAddress = start
-		subnet.CreateAddressRange(addressRange)
-	} else {
+func (nm *NMgr) assignAddressrange(subnet *vspk.Subnet, start, end string) error {
+	fetch := func() ([]*vspk.AddressRange, *bambou.Error) {
+		return subnet.AddressRanges(&bambou.FetchingInfo{})
+	}
+	match := func(*vspk.AddressRange) bool { return true }
+	_, found, err := vsdFind(nm, "addressranges", fetch, match)
+	if err != nil {
+		return err
+	}
+	if found {
 		log.Debug("Address Ranges already exist")
+		return nil
 	}
+	addressRange := &vspk.AddressRange{}
+	addressRange.DHCPPoolType = "BRIDGE"
+	addressRange.IPType = "IPV4"
+	addressRange.MaxAddress = end
+	addressRange.MinAddress = start
+	_, err = vsdCreate(nm, "addressranges", "AddressRange", addressRange, fetch, match, subnet.CreateAddressRange)
+	return err
 }
 
-func (nm *NMgr) assignDhcpOptions(subnet *vspk.Subnet, dns1, dns2 string) {
-	dhcpOptions, _ := subnet.DHCPOptions(&bambou.FetchingInfo{})
-	if dhcpOptions == nil {
-		dhcpOption := &vspk.DHCPOption{}
-		dhcpOption.ActualType = 6
-		dhcpOption.ActualValues = []interface{}{dns1, dns2}
-		subnet.CreateDHCPOption(dhcpOption)
-	} else {
+func (nm *NMgr) assignDhcpOptions(subnet *vspk.Subnet, dns1, dns2 string) error {
+	fetch := func() ([]*vspk.DHCPOption, *bambou.Error) {
+		return subnet.DHCPOptions(&bambou.FetchingInfo{})
+	}
+	match := func(*vspk.DHCPOption) bool { return true }
+	_, found, err := vsdFind(nm, "dhcpoptions", fetch, match)
+	if err != nil {
+		return err
+	}
+	if found {
 		log.Debug("DHCP Options already exist")
+		return nil
 	}
+	dhcpOption := &vspk.DHCPOption{}
+	dhcpOption.ActualType = 6
+	dhcpOption.ActualValues = []interface{}{dns1, dns2}
+	_, err = vsdCreate(nm, "dhcpoptions", "DHCPOption", dhcpOption, fetch, match, subnet.CreateDHCPOption)
+	return err
 }
 
 // NSG function
-func (nm *NMgr) nsg(name string, enterprise *vspk.Enterprise) *vspk.NSGateway {
+func (nm *NMgr) nsg(name string, enterprise *vspk.Enterprise) (*vspk.NSGateway, error) {
 	nsgCfg := map[string]interface{}{
 		"Name":                  name,
 		"TCPMSSEnabled":         true,
 		"TCPMaximumSegmentSize": 1330,
 		"NetworkAcceleration":   "PERFORMANCE",
 	}
-	return nuagewrapper.NSG(nsgCfg, enterprise)
+	g, _, err := vsdCreateOrUpdate(nm, "nsgateways", "NSGateway", nsgCfg, &vspk.NSGateway{},
+		func() ([]*vspk.NSGateway, *bambou.Error) {
+			return enterprise.NSGateways(&bambou.FetchingInfo{Filter: name})
+		},
+		func(g *vspk.NSGateway) bool { return g.Name == name },
+		enterprise.CreateNSGateway)
+	return g, err
 }
 
-func (nm *NMgr) getNsg(name string, enterprise *vspk.Enterprise) *vspk.NSGateway {
-	nsgCfg := map[string]interface{}{
-		"Name": name,
-	}
-	return nuagewrapper.NSG(nsgCfg, enterprise)
+// getNsg returns the NSG with the name, nil when it does not exist
+func (nm *NMgr) getNsg(name string, enterprise *vspk.Enterprise) (*vspk.NSGateway, error) {
+	g, _, err := vsdFind(nm, "nsgateways",
+		func() ([]*vspk.NSGateway, *bambou.Error) {
+			return enterprise.NSGateways(&bambou.FetchingInfo{Filter: name})
+		},
+		func(g *vspk.NSGateway) bool { return g.Name == name })
+	return g, err
 }
 
-func (nm *NMgr) nsgRedundantGwGroup(name string, nsg1, nsg2 *vspk.NSGateway, enterprise *vspk.Enterprise) *vspk.NSRedundantGatewayGroup {
+// nsgRedundantGwGroup creates the redundant gateway group of the NSGs, created returns
+// if the group is new
+func (nm *NMgr) nsgRedundantGwGroup(name string, nsg1, nsg2 *vspk.NSGateway, enterprise *vspk.Enterprise) (*vspk.NSRedundantGatewayGroup, bool, error) {
 	nsRedundantGwGroupCfg := map[string]interface{}{
 		"Name":           name,
 		"GatewayPeer1ID": nsg1.ID,
 		"GatewayPeer2ID": nsg2.ID,
 	}
-	return nuagewrapper.NSGRedundantGwGroup(nsRedundantGwGroupCfg, enterprise)
+	return vsdCreateOrUpdate(nm, "nsredundantgatewaygroups", "NSRedundantGatewayGroup", nsRedundantGwGroupCfg, &vspk.NSRedundantGatewayGroup{},
+		func() ([]*vspk.NSRedundantGatewayGroup, *bambou.Error) {
+			return enterprise.NSRedundantGatewayGroups(&bambou.FetchingInfo{Filter: name})
+		},
+		func(g *vspk.NSRedundantGatewayGroup) bool { return g.Name == name },
+		enterprise.CreateNSRedundantGatewayGroup)
+}
+
+// deleteNsgRedundantGwGroup deletes the redundant gateway group with the name and its
+// shunt links
+func (nm *NMgr) deleteNsgRedundantGwGroup(name string, enterprise *vspk.Enterprise) error {
+	group, found, err := vsdFind(nm, "nsredundantgatewaygroups",
+		func() ([]*vspk.NSRedundantGatewayGroup, *bambou.Error) {
+			return enterprise.NSRedundantGatewayGroups(&bambou.FetchingInfo{Filter: name})
+		},
+		func(g *vspk.NSRedundantGatewayGroup) bool { return g.Name == name })
+	if err != nil || !found {
+		return err
+	}
+	if _, err := vsdDeleteAll(nm, "shuntlinks", "ShuntLink",
+		func() ([]*vspk.ShuntLink, *bambou.Error) {
+			return group.ShuntLinks(&bambou.FetchingInfo{})
+		},
+		func(*vspk.ShuntLink) bool { return true }); err != nil {
+		return err
+	}
+	if err := vsdDelete(nm, "nsredundantgatewaygroups", "NSRedundantGatewayGroup", group); err != nil {
+		return err
+	}
+	nm.deleted("nuage redundant gateway group", name, group.ID, "")
+	return nil
+}
+
+func (nm *NMgr) getNsgRedundantGwGroup(id string) (*vspk.NSRedundantGatewayGroup, error) {
+	nsRedundantGwGroup := vspk.NewNSRedundantGatewayGroup()
+	nsRedundantGwGroup.ID = id
+	if err := nm.retryVSD("nsredundantgatewaygroups", nsRedundantGwGroup.Fetch); err != nil {
+		return nil, fmt.Errorf("unable to read redundant gateway group %s: %s", id, err.Description)
+	}
+	return nsRedundantGwGroup, nil
 }
 
-func (nm *NMgr) shuntLink(name string, vlan1, vlan2 *vspk.VLAN, nsRedundantGwGroup *vspk.NSRedundantGatewayGroup) *vspk.ShuntLink {
+// shuntLink creates the shunt link between the vlans of the redundant NSGs, created
+// returns if the link is new
+func (nm *NMgr) shuntLink(name string, vlan1, vlan2 *vspk.VLAN, nsRedundantGwGroup *vspk.NSRedundantGatewayGroup) (*vspk.ShuntLink, bool, error) {
 	shuntLinkCfg := map[string]interface{}{
 		"Name":        name,
 		"VLANPeer1ID": vlan1.ID,
 		"VLANPeer2ID": vlan2.ID,
 	}
-	return nuagewrapper.ShuntLink(shuntLinkCfg, nsRedundantGwGroup)
+	return vsdCreateOrUpdate(nm, "shuntlinks", "ShuntLink", shuntLinkCfg, &vspk.ShuntLink{},
+		func() ([]*vspk.ShuntLink, *bambou.Error) {
+			return nsRedundantGwGroup.ShuntLinks(&bambou.FetchingInfo{Filter: name})
+		},
+		func(l *vspk.ShuntLink) bool { return l.Name == name },
+		nsRedundantGwGroup.CreateShuntLink)
 }
 
-func (nm *NMgr) redundantPort(name string, nsRedundantGwGroup *vspk.NSRedundantGatewayGroup) *vspk.RedundantPort {
+// deleteShuntLink deletes the shunt link with the name from the redundant gateway group
+func (nm *NMgr) deleteShuntLink(name string, nsRedundantGwGroup *vspk.NSRedundantGatewayGroup) error {
+	_, err := vsdDeleteAll(nm, "shuntlinks", "ShuntLink",
+		func() ([]*vspk.ShuntLink, *bambou.Error) {
+			return nsRedundantGwGroup.ShuntLinks(&bambou.FetchingInfo{Filter: name})
+		},
+		func(l *vspk.ShuntLink) bool { return l.Name == name })
+	return err
+}
+
+func (nm *NMgr) redundantPort(name string, nsRedundantGwGroup *vspk.NSRedundantGatewayGroup) (*vspk.RedundantPort, error) {
 	nsRedundantPortCfg := map[string]interface{}{
 		"Name":         name,
 		"PhysicalName": name,
 		"PortType":     "ACCESS",
 		"VLANRange":    "0-4094",
 	}
-	return nuagewrapper.NSGRedundantPort(nsRedundantPortCfg, nsRedundantGwGroup)
+	p, _, err := vsdCreateOrUpdate(nm, "redundantports", "RedundantPort", nsRedundantPortCfg, &vspk.RedundantPort{},
+		func() ([]*vspk.RedundantPort, *bambou.Error) {
+			return nsRedundantGwGroup.RedundantPorts(&bambou.FetchingInfo{Filter: name})
+		},
+		func(p *vspk.RedundantPort) bool { return p.Name == name },
+		nsRedundantGwGroup.CreateRedundantPort)
+	return p, err
 }
 
-func (nm *NMgr) nsgNetworkPort(name string, nsg *vspk.NSGateway) *vspk.NSPort {
+func (nm *NMgr) nsgNetworkPort(name string, nsg *vspk.NSGateway) (*vspk.NSPort, error) {
 	nsgPortCfg := map[string]interface{}{
 		"Name":            name,
 		"PhysicalName":    name,
@@ -223,56 +408,141 @@ func (nm *NMgr) nsgNetworkPort(name string, nsg *vspk.NSGateway) *vspk.NSPort {
 		"EnableNATProbes": true,
 		"NATTraversal":    "FULL_NAT",
 	}
-	return nuagewrapper.NSGPort(nsgPortCfg, nsg)
+	p, _, err := vsdCreateOrUpdate(nm, "nsports", "NSPort", nsgPortCfg, &vspk.NSPort{},
+		func() ([]*vspk.NSPort, *bambou.Error) {
+			return nsg.NSPorts(&bambou.FetchingInfo{Filter: name})
+		},
+		func(p *vspk.NSPort) bool { return p.Name == name },
+		nsg.CreateNSPort)
+	return p, err
 }
 
-func (nm *NMgr) getNetworkPort(name string, nsg *vspk.NSGateway) *vspk.NSPort {
-	nsgPortCfg := map[string]interface{}{
-		"Name": name,
-	}
-	return nuagewrapper.GetNSGPort(nsgPortCfg, nsg)
+// getNetworkPort returns the port of the NSG with the name, nil when it does not exist
+func (nm *NMgr) getNetworkPort(name string, nsg *vspk.NSGateway) (*vspk.NSPort, error) {
+	p, _, err := vsdFind(nm, "nsports",
+		func() ([]*vspk.NSPort, *bambou.Error) {
+			return nsg.NSPorts(&bambou.FetchingInfo{Filter: name})
+		},
+		func(p *vspk.NSPort) bool { return p.Name == name })
+	return p, err
 }
 
-func (nm *NMgr) vlan(vlanID int, port *vspk.NSPort) *vspk.VLAN {
+func (nm *NMgr) vlan(vlanID int, port *vspk.NSPort) (*vspk.VLAN, error) {
 	nsgVLANCfg := map[string]interface{}{
 		"Value":       vlanID,
 		"Description": "test",
 	}
-	return nuagewrapper.Vlan(nsgVLANCfg, port)
+	v, _, err := vsdCreateOrUpdate(nm, "vlans", "VLAN", nsgVLANCfg, &vspk.VLAN{},
+		func() ([]*vspk.VLAN, *bambou.Error) {
+			return port.VLANs(&bambou.FetchingInfo{Filter: fmt.Sprintf("value == %d", vlanID)})
+		},
+		func(v *vspk.VLAN) bool { return v.Value == vlanID },
+		port.CreateVLAN)
+	return v, err
 }
 
-func (nm *NMgr) getVlan(vlanID int, port *vspk.NSPort) *vspk.VLAN {
-	nsgVLANCfg := map[string]interface{}{
-		"Value": vlanID,
-	}
-	return nuagewrapper.GetVlan(nsgVLANCfg, port)
+// getVlan returns the vlan of the port with the vlan id, nil when it does not exist
+func (nm *NMgr) getVlan(vlanID int, port *vspk.NSPort) (*vspk.VLAN, error) {
+	v, _, err := vsdFind(nm, "vlans",
+		func() ([]*vspk.VLAN, *bambou.Error) {
+			return port.VLANs(&bambou.FetchingInfo{Filter: fmt.Sprintf("value == %d", vlanID)})
+		},
+		func(v *vspk.VLAN) bool { return v.Value == vlanID })
+	return v, err
 }
 
-func (nm *NMgr) localNSGRedundantVLAN(vlanID int, port *vspk.RedundantPort) *vspk.VLAN {
+func (nm *NMgr) localNSGRedundantVLAN(vlanID int, port *vspk.RedundantPort) (*vspk.VLAN, error) {
 	nsgVLANCfg := map[string]interface{}{
 		"Value": vlanID,
 	}
-	return nuagewrapper.RedundantVlan(nsgVLANCfg, port)
+	v, _, err := vsdCreateOrUpdate(nm, "vlans", "VLAN", nsgVLANCfg, &vspk.VLAN{},
+		func() ([]*vspk.VLAN, *bambou.Error) {
+			return port.VLANs(&bambou.FetchingInfo{Filter: fmt.Sprintf("value == %d", vlanID)})
+		},
+		func(v *vspk.VLAN) bool { return v.Value == vlanID },
+		port.CreateVLAN)
+	return v, err
 }
 
-func (nm *NMgr) staticRoute(domain *vspk.Domain, prefix, nextHop string) {
-	ipv4Addr, ipv4Net, _ := net.ParseCIDR(prefix)
+// staticRoute creates the route for the prefix towards the next hop, the routes of a
+// prefix towards different next hops are ECMP routes. The external id ties the route
+// to the IKE gateway connection of the tunnel of the next hop
+func (nm *NMgr) staticRoute(domain *vspk.Domain, prefix, nextHop, routeType, externalID string) (*vspk.StaticRoute, bool, error) {
+	ipv4Addr, ipv4Net, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, false, err
+	}
 	log.Debugf("ipv4Addr: %s\n", ipv4Addr.String())
 	log.Debugf("ipv4Net IP: %s \n", ipv4Net.IP.String())
 	log.Debugf("ipv4Net Mask: %s \n", ipv4Net.Mask.String())
 	log.Debugf("inexthop: %s \n", nextHop)
 
 	staticRouteCfg := map[string]interface{}{
-		"Address":   "0.0.0.0",
-		"Netmask":   "0.0.0.0",
-		"NextHopIp": nextHop,
-		"Type":      "OVERLAY",
-		"IPType":    "IPV4",
-	}
-	nuagewrapper.StaticRoute(staticRouteCfg, domain)
-}
-
-func (nm *NMgr) bgpNeighbor(subnet *vspk.Subnet, name, peerIP string, peerAS int) {
+		"Address":    ipv4Net.IP.String(),
+		"Netmask":    net.IP(ipv4Net.Mask).String(),
+		"NextHopIp":  nextHop,
+		"Type":       routeType,
+		"IPType":     "IPV4",
+		"ExternalID": externalID,
+	}
+	return vsdCreateOrUpdate(nm, "staticroutes", "StaticRoute", staticRouteCfg, &vspk.StaticRoute{},
+		func() ([]*vspk.StaticRoute, *bambou.Error) {
+			return domain.StaticRoutes(&bambou.FetchingInfo{Filter: ipv4Net.IP.String()})
+		},
+		func(sr *vspk.StaticRoute) bool {
+			return sr.Address == ipv4Net.IP.String() && sr.Netmask == net.IP(ipv4Net.Mask).String() && sr.NextHopIp == nextHop
+		},
+		domain.CreateStaticRoute)
+}
+
+func (nm *NMgr) getStaticRoutes(domain *vspk.Domain, prefix string) ([]*vspk.StaticRoute, error) {
+	_, ipv4Net, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var staticRoutes vspk.StaticRoutesList
+	bErr := nm.retryVSD("staticroutes", func() *bambou.Error {
+		var bErr *bambou.Error
+		staticRoutes, bErr = domain.StaticRoutes(&bambou.FetchingInfo{Filter: ipv4Net.IP.String()})
+		return bErr
+	})
+	if bErr != nil {
+		return nil, fmt.Errorf("%s", bErr.Description)
+	}
+	var routes []*vspk.StaticRoute
+	for _, sr := range staticRoutes {
+		if sr.Address == ipv4Net.IP.String() && sr.Netmask == net.IP(ipv4Net.Mask).String() {
+			routes = append(routes, sr)
+		}
+	}
+	return routes, nil
+}
+
+func (nm *NMgr) deleteStaticRoute(domain *vspk.Domain, prefix string) error {
+	staticRoutes, err := nm.getStaticRoutes(domain, prefix)
+	if err != nil {
+		return err
+	}
+	for _, sr := range staticRoutes {
+		if err := vsdDelete(nm, "staticroutes", "StaticRoute", sr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteTunnelStaticRoutes deletes the static routes of the domain that are tied to the
+// IKE gateway connection of a tunnel, except the routes towards the next hop to keep
+func (nm *NMgr) deleteTunnelStaticRoutes(domain *vspk.Domain, externalID, keepNextHop string) error {
+	_, err := vsdDeleteAll(nm, "staticroutes", "StaticRoute",
+		func() ([]*vspk.StaticRoute, *bambou.Error) {
+			return domain.StaticRoutes(&bambou.FetchingInfo{Filter: fmt.Sprintf("externalID == \"%s\"", externalID)})
+		},
+		func(sr *vspk.StaticRoute) bool { return sr.ExternalID == externalID && sr.NextHopIp != keepNextHop })
+	return err
+}
+
+func (nm *NMgr) bgpNeighbor(subnet *vspk.Subnet, name, peerIP string, peerAS int) (*vspk.BGPNeighbor, bool, error) {
 	bgpNeighborCfg := map[string]interface{}{
 		"Name":        name,
 		"Description": name,
@@ -280,77 +550,148 @@ func (nm *NMgr) bgpNeighbor(subnet *vspk.Subnet, name, peerIP string, peerAS int
 		"PeerIP":      peerIP,
 		"IPType":      "IPV4",
 	}
-	nuagewrapper.BGPNeighbor(bgpNeighborCfg, subnet)
+	return vsdCreateOrUpdate(nm, "bgpneighbors", "BGPNeighbor", bgpNeighborCfg, &vspk.BGPNeighbor{},
+		func() ([]*vspk.BGPNeighbor, *bambou.Error) {
+			return subnet.BGPNeighbors(&bambou.FetchingInfo{Filter: name})
+		},
+		func(n *vspk.BGPNeighbor) bool { return n.Name == name },
+		subnet.CreateBGPNeighbor)
 }
 
-func (nm *NMgr) assignVportBridge(name string, subnet *vspk.Subnet, vlan *vspk.VLAN) *vspk.VPort {
-	var vport *vspk.VPort
-	vports, _ := subnet.VPorts(&bambou.FetchingInfo{})
+func (nm *NMgr) deleteBGPNeighbor(subnet *vspk.Subnet, name string) error {
+	_, err := vsdDeleteAll(nm, "bgpneighbors", "BGPNeighbor",
+		func() ([]*vspk.BGPNeighbor, *bambou.Error) {
+			return subnet.BGPNeighbors(&bambou.FetchingInfo{Filter: name})
+		},
+		func(n *vspk.BGPNeighbor) bool { return n.Name == name })
+	return err
+}
 
-	if vports == nil {
-		log.Debug("vport does not exist yet")
-		vport := &vspk.VPort{}
-		vport.Name = name
-		vport.VLANID = vlan.ID
-		vport.AddressSpoofing = "ENABLED"
-		vport.Type = "BRIDGE"
-		subnet.CreateVPort(vport)
-	} else {
+func (nm *NMgr) assignVportBridge(name string, subnet *vspk.Subnet, vlan *vspk.VLAN) (*vspk.VPort, error) {
+	fetch := func() ([]*vspk.VPort, *bambou.Error) {
+		return subnet.VPorts(&bambou.FetchingInfo{})
+	}
+	match := func(*vspk.VPort) bool { return true }
+	vport, found, err := vsdFind(nm, "vports", fetch, match)
+	if err != nil {
+		return nil, err
+	}
+	if found {
 		log.Debug("vport already exist")
-		vport = vports[0]
+		return vport, nil
+	}
+	log.Debug("vport does not exist yet")
+	vport = &vspk.VPort{}
+	vport.Name = name
+	vport.VLANID = vlan.ID
+	vport.AddressSpoofing = "ENABLED"
+	vport.Type = "BRIDGE"
+	vport, err = vsdCreate(nm, "vports", "VPort", vport, fetch, match, subnet.CreateVPort)
+	if err != nil {
+		return nil, err
 	}
 	log.Debugf("vport: %#v \n", vport)
-	return vport
+	return vport, nil
 }
 
-func (nm *NMgr) assignBridgeInterface(name string, vport *vspk.VPort) {
+func (nm *NMgr) assignBridgeInterface(name string, vport *vspk.VPort) error {
 	log.Debugf("assign bridge interface Name: %s \n", name)
-	bridgeInterfaces, _ := vport.BridgeInterfaces(&bambou.FetchingInfo{})
-
-	if bridgeInterfaces == nil {
-		bridgeInterface := &vspk.BridgeInterface{}
-		bridgeInterface.Name = name
-		bridgeInterface.VPortID = vport.ID
-		vport.CreateBridgeInterface(bridgeInterface)
-	} else {
+	fetch := func() ([]*vspk.BridgeInterface, *bambou.Error) {
+		return vport.BridgeInterfaces(&bambou.FetchingInfo{})
+	}
+	match := func(*vspk.BridgeInterface) bool { return true }
+	_, found, err := vsdFind(nm, "bridgeinterfaces", fetch, match)
+	if err != nil {
+		return err
+	}
+	if found {
 		log.Debug("bridge Interface already exist")
+		return nil
 	}
+	bridgeInterface := &vspk.BridgeInterface{}
+	bridgeInterface.Name = name
+	bridgeInterface.VPortID = vport.ID
+	_, err = vsdCreate(nm, "bridgeinterfaces", "BridgeInterface", bridgeInterface, fetch, match, vport.CreateBridgeInterface)
+	return err
 }
 
-func (nm *NMgr) createIKEPSK(name string, enterprise *vspk.Enterprise) *vspk.IKEPSK {
+func (nm *NMgr) createIKEPSK(name string, enterprise *vspk.Enterprise) (*vspk.IKEPSK, error) {
 	ikePSKCfg := map[string]interface{}{
 		"Name":           name,
 		"Description":    name,
 		"UnencryptedPSK": psk,
 	}
-	return nuagewrapper.CreateIKEPSK(ikePSKCfg, enterprise)
+	p, created, err := vsdCreateOrUpdate(nm, "ikepsks", "IKEPSK", ikePSKCfg, &vspk.IKEPSK{},
+		func() ([]*vspk.IKEPSK, *bambou.Error) {
+			return enterprise.IKEPSKs(&bambou.FetchingInfo{Filter: name})
+		},
+		func(p *vspk.IKEPSK) bool { return p.Name == name },
+		enterprise.CreateIKEPSK)
+	if created {
+		nm.created("ike psk", name, p.ID, "")
+	}
+	return p, err
 }
 
 func (nm *NMgr) deleteIKEPSK(name string, enterprise *vspk.Enterprise) error {
-	ikePSKCfg := map[string]interface{}{
-		"Name": name,
+	n, err := vsdDeleteAll(nm, "ikepsks", "IKEPSK",
+		func() ([]*vspk.IKEPSK, *bambou.Error) {
+			return enterprise.IKEPSKs(&bambou.FetchingInfo{Filter: name})
+		},
+		func(p *vspk.IKEPSK) bool { return p.Name == name })
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		nm.deleted("ike psk", name, name, "")
 	}
-	return nuagewrapper.DeleteIKEPSK(ikePSKCfg, enterprise)
+	return nil
 }
 
-func (nm *NMgr) createIKEGateway(name, version, ip string, enterprise *vspk.Enterprise) *vspk.IKEGateway {
+// createIKEGateway creates the IKE gateway with an IKE subnet that allows any prefix
+func (nm *NMgr) createIKEGateway(name, version, ip string, enterprise *vspk.Enterprise) (*vspk.IKEGateway, error) {
 	ikeGatewayCfg := map[string]interface{}{
 		"Name":        name,
 		"Description": name,
 		"IKEVersion":  version,
 		"IPAddress":   ip,
 	}
-	return nuagewrapper.CreateIKEGateway(ikeGatewayCfg, enterprise)
+	g, created, err := vsdCreateOrUpdate(nm, "ikegateways", "IKEGateway", ikeGatewayCfg, &vspk.IKEGateway{},
+		func() ([]*vspk.IKEGateway, *bambou.Error) {
+			return enterprise.IKEGateways(&bambou.FetchingInfo{Filter: name})
+		},
+		func(g *vspk.IKEGateway) bool { return g.Name == name },
+		enterprise.CreateIKEGateway)
+	if err != nil || !created {
+		return g, err
+	}
+	ikeSubnet := &vspk.IKESubnet{}
+	ikeSubnet.Prefix = "0.0.0.0/0"
+	_, err = vsdCreate(nm, "ikesubnets", "IKESubnet", ikeSubnet,
+		func() ([]*vspk.IKESubnet, *bambou.Error) {
+			return g.IKESubnets(&bambou.FetchingInfo{})
+		},
+		func(s *vspk.IKESubnet) bool { return s.Prefix == ikeSubnet.Prefix },
+		g.CreateIKESubnet)
+	return g, err
 }
 
 func (nm *NMgr) deleteIKEGateway(name string, enterprise *vspk.Enterprise) error {
-	ikeGatewayCfg := map[string]interface{}{
-		"Name": name,
+	n, err := vsdDeleteAll(nm, "ikegateways", "IKEGateway",
+		func() ([]*vspk.IKEGateway, *bambou.Error) {
+			return enterprise.IKEGateways(&bambou.FetchingInfo{Filter: name})
+		},
+		func(g *vspk.IKEGateway) bool { return g.Name == name })
+	if err != nil {
+		return err
 	}
-	return nuagewrapper.DeleteIKEGateway(ikeGatewayCfg, enterprise)
+	if n > 0 {
+		nm.deleted("ike gateway", name, name, "")
+	}
+	return nil
 }
 
-func (nm *NMgr) createIKEEncryptionprofile(name string, enterprise *vspk.Enterprise) *vspk.IKEEncryptionprofile {
+func (nm *NMgr) createIKEEncryptionprofile(name string, enterprise *vspk.Enterprise) (*vspk.IKEEncryptionprofile, error) {
 	ikeEncryptionProfileCfg := map[string]interface{}{
 		"Name":                              name,
 		"Description":                       name,
@@ -363,23 +704,39 @@ func (nm *NMgr) createIKEEncryptionprofile(name string, enterprise *vspk.Enterpr
 		"IPsecEnablePFS":                    true,
 		"IPsecEncryptionAlgorithm":          "AES128",
 		"IPsecPreFragment":                  true,
-		"IPsecSALifetime":                   3600,
+		"IPsecSALifetime":                   int(phase2Lifetime),
 		"IPsecAuthenticationAlgorithm":      "HMAC_SHA1",
 		"IPsecSAReplayWindowSize":           "WINDOW_SIZE_64",
 	}
 
-	return nuagewrapper.CreateIKEEncryptionProfile(ikeEncryptionProfileCfg, enterprise)
+	p, created, err := vsdCreateOrUpdate(nm, "ikeencryptionprofiles", "IKEEncryptionProfile", ikeEncryptionProfileCfg, &vspk.IKEEncryptionprofile{},
+		func() ([]*vspk.IKEEncryptionprofile, *bambou.Error) {
+			return enterprise.IKEEncryptionprofiles(&bambou.FetchingInfo{Filter: name})
+		},
+		func(p *vspk.IKEEncryptionprofile) bool { return p.Name == name },
+		enterprise.CreateIKEEncryptionprofile)
+	if created {
+		nm.created("ike encryption profile", name, p.ID, "")
+	}
+	return p, err
 }
 
 func (nm *NMgr) deleteIKEEncryptionprofile(name string, enterprise *vspk.Enterprise) error {
-	ikeEncryptionProfileCfg := map[string]interface{}{
-		"Name": name,
+	n, err := vsdDeleteAll(nm, "ikeencryptionprofiles", "IKEEncryptionProfile",
+		func() ([]*vspk.IKEEncryptionprofile, *bambou.Error) {
+			return enterprise.IKEEncryptionprofiles(&bambou.FetchingInfo{Filter: name})
+		},
+		func(p *vspk.IKEEncryptionprofile) bool { return p.Name == name })
+	if err != nil {
+		return err
 	}
-
-	return nuagewrapper.DeleteIKEEncryptionProfile(ikeEncryptionProfileCfg, enterprise)
+	if n > 0 {
+		nm.deleted("ike encryption profile", name, name, "")
+	}
+	return nil
 }
 
-func (nm *NMgr) createIKEGatewayProfile(name, pskID, ip, ikeGWID, ikeProfID string, enterprise *vspk.Enterprise) *vspk.IKEGatewayProfile {
+func (nm *NMgr) createIKEGatewayProfile(name, pskID, ip, ikeGWID, ikeProfID string, enterprise *vspk.Enterprise) (*vspk.IKEGatewayProfile, error) {
 	ikeGatewayProfileCfg := map[string]interface{}{
 		"Name":                             name,
 		"Description":                      name,
@@ -390,18 +747,31 @@ func (nm *NMgr) createIKEGatewayProfile(name, pskID, ip, ikeGWID, ikeProfID stri
 		"AssociatedIKEEncryptionProfileID": ikeProfID,
 	}
 
-	return nuagewrapper.CreateIKEGatewayProfile(ikeGatewayProfileCfg, enterprise)
+	p, _, err := vsdCreateOrUpdate(nm, "ikegatewayprofiles", "IKEGatewayProfile", ikeGatewayProfileCfg, &vspk.IKEGatewayProfile{},
+		func() ([]*vspk.IKEGatewayProfile, *bambou.Error) {
+			return enterprise.IKEGatewayProfiles(&bambou.FetchingInfo{Filter: name})
+		},
+		func(p *vspk.IKEGatewayProfile) bool { return p.Name == name },
+		enterprise.CreateIKEGatewayProfile)
+	return p, err
 }
 
 func (nm *NMgr) deleteIKEGatewayProfile(name string, enterprise *vspk.Enterprise) error {
-	ikeGatewayProfileCfg := map[string]interface{}{
-		"Name": name,
+	n, err := vsdDeleteAll(nm, "ikegatewayprofiles", "IKEGatewayProfile",
+		func() ([]*vspk.IKEGatewayProfile, *bambou.Error) {
+			return enterprise.IKEGatewayProfiles(&bambou.FetchingInfo{Filter: name})
+		},
+		func(p *vspk.IKEGatewayProfile) bool { return p.Name == name })
+	if err != nil {
+		return err
 	}
-
-	return nuagewrapper.DeleteIKEGatewayProfile(ikeGatewayProfileCfg, enterprise)
+	if n > 0 {
+		nm.deleted("ike gateway profile", name, name, "")
+	}
+	return nil
 }
 
-func (nm *NMgr) createIKEGatewayConnection(name, id, ikeProfID, pskID string, vlan *vspk.VLAN) *vspk.IKEGatewayConnection {
+func (nm *NMgr) createIKEGatewayConnection(name, id, ikeProfID, pskID string, vlan *vspk.VLAN) (*vspk.IKEGatewayConnection, error) {
 	ikeGatewayConnCfg1 := map[string]interface{}{
 		"Name":                          name,
 		"Description":                   name,
@@ -413,12 +783,50 @@ func (nm *NMgr) createIKEGatewayConnection(name, id, ikeProfID, pskID string, vl
 		"AssociatedIKEAuthenticationID": pskID,
 	}
 
-	return nuagewrapper.CreateIKEGatewayConnection(ikeGatewayConnCfg1, vlan)
+	c, _, err := vsdCreateOrUpdate(nm, "ikegatewayconnections", "IKEGatewayConnection", ikeGatewayConnCfg1, &vspk.IKEGatewayConnection{},
+		func() ([]*vspk.IKEGatewayConnection, *bambou.Error) {
+			return vlan.IKEGatewayConnections(&bambou.FetchingInfo{Filter: name})
+		},
+		func(c *vspk.IKEGatewayConnection) bool { return c.Name == name },
+		vlan.CreateIKEGatewayConnection)
+	return c, err
 }
 
 func (nm *NMgr) deleteIKEGatewayConnection(name string, vlan *vspk.VLAN) error {
-	ikeGatewayConnCfg1 := map[string]interface{}{
-		"Name": name,
-	}
-	return nuagewrapper.DeleteIKEGatewayConnection(ikeGatewayConnCfg1, vlan)
+	n, err := vsdDeleteAll(nm, "ikegatewayconnections", "IKEGatewayConnection",
+		func() ([]*vspk.IKEGatewayConnection, *bambou.Error) {
+			return vlan.IKEGatewayConnections(&bambou.FetchingInfo{Filter: name})
+		},
+		func(c *vspk.IKEGatewayConnection) bool { return c.Name == name })
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		nm.deleted("ike gateway connection", name, name, "")
+	}
+	return nil
+}
+
+// getIKEGatewayConnection returns the IKE gateway connection with the name on the vlan
+func (nm *NMgr) getIKEGatewayConnection(name string, vlan *vspk.VLAN) (*vspk.IKEGatewayConnection, error) {
+	c, _, err := vsdFind(nm, "ikegatewayconnections",
+		func() ([]*vspk.IKEGatewayConnection, *bambou.Error) {
+			return vlan.IKEGatewayConnections(&bambou.FetchingInfo{Filter: name})
+		},
+		func(c *vspk.IKEGatewayConnection) bool { return c.Name == name })
+	return c, err
+}
+
+// recordIKEObjects records the IKE gateway, profile and connection of a new vpn tunnel
+// in the deploy journal, in the order they are created
+func (nm *NMgr) recordIKEObjects(name string, vlan *vspk.VLAN, enterprise *vspk.Enterprise) {
+	nm.journal.record("ike gateway", name, name, "", func() error {
+		return nm.deleteIKEGateway(name, enterprise)
+	})
+	nm.journal.record("ike gateway profile", name, name, "", func() error {
+		return nm.deleteIKEGatewayProfile(name, enterprise)
+	})
+	nm.journal.record("ike gateway connection", name, name, "", func() error {
+		return nm.deleteIKEGatewayConnection(name, vlan)
+	})
 }
@@ -0,0 +1,17 @@
+package awsnmgr
+
+// paginate calls fetch with the next token returned by the previous page until the
+// last page is read, fetch appends the items of the page to the output of the caller
+func paginate(fetch func(token *string) (*string, error)) error {
+	var token *string
+	for {
+		next, err := fetch(token)
+		if err != nil {
+			return err
+		}
+		if next == nil || *next == "" {
+			return nil
+		}
+		token = next
+	}
+}
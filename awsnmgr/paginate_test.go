@@ -0,0 +1,419 @@
+package awsnmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/networkmanager"
+)
+
+func TestPaginate(t *testing.T) {
+	empty := ""
+	for _, tc := range []struct {
+		name   string
+		pages  map[string]*string
+		err    error
+		errAt  string
+		tokens []string
+		items  []string
+	}{
+		{
+			name:   "single page",
+			pages:  map[string]*string{"": nil},
+			tokens: []string{""},
+			items:  []string{""},
+		},
+		{
+			name:   "multi page",
+			pages:  map[string]*string{"": aws.String("t1"), "t1": aws.String("t2"), "t2": nil},
+			tokens: []string{"", "t1", "t2"},
+			items:  []string{"", "t1", "t2"},
+		},
+		{
+			name:   "empty token ends",
+			pages:  map[string]*string{"": aws.String("t1"), "t1": &empty},
+			tokens: []string{"", "t1"},
+			items:  []string{"", "t1"},
+		},
+		{
+			name:   "error mid stream",
+			pages:  map[string]*string{"": aws.String("t1"), "t1": aws.String("t2"), "t2": nil},
+			err:    errors.New("throttled"),
+			errAt:  "t1",
+			tokens: []string{"", "t1"},
+			items:  []string{""},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var tokens, items []string
+			err := paginate(func(token *string) (*string, error) {
+				tokens = append(tokens, aws.ToString(token))
+				if tc.err != nil && aws.ToString(token) == tc.errAt {
+					return nil, tc.err
+				}
+				items = append(items, aws.ToString(token))
+				return tc.pages[aws.ToString(token)], nil
+			})
+			if err != tc.err {
+				t.Errorf("paginate error = %v, want %v", err, tc.err)
+			}
+			if fmt.Sprint(tokens) != fmt.Sprint(tc.tokens) {
+				t.Errorf("tokens = %q, want %q", tokens, tc.tokens)
+			}
+			if fmt.Sprint(items) != fmt.Sprint(tc.items) {
+				t.Errorf("items = %q, want %q", items, tc.items)
+			}
+		})
+	}
+}
+
+// fakeNMgrHTTP answers the Network Manager Get requests with the page of the nextToken
+// in the query, a missing page answers a non retryable error
+type fakeNMgrHTTP struct {
+	pages    map[string]string
+	requests []string
+}
+
+func (f *fakeNMgrHTTP) Do(req *http.Request) (*http.Response, error) {
+	token := req.URL.Query().Get("nextToken")
+	f.requests = append(f.requests, token)
+	body, ok := f.pages[token]
+	status := http.StatusOK
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	if !ok {
+		status = http.StatusBadRequest
+		header.Set("X-Amzn-Errortype", "ValidationException")
+		body = `{"Message":"invalid next token"}`
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+var fakeCredentials = aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+	return aws.Credentials{AccessKeyID: "key", SecretAccessKey: "secret"}, nil
+})
+
+func newFakeNMgr(f *fakeNMgrHTTP) *NMgr {
+	return &NMgr{
+		ctx:             context.Background(),
+		GlobalNetworkID: aws.String("global-network-1"),
+		ClientNMgr: networkmanager.New(networkmanager.Options{
+			Region:      "us-west-2",
+			HTTPClient:  f,
+			Credentials: fakeCredentials,
+		}),
+	}
+}
+
+func TestGetLinksPages(t *testing.T) {
+	f := &fakeNMgrHTTP{pages: map[string]string{
+		"":   `{"Links":[{"LinkId":"link-1"},{"LinkId":"link-2"}],"NextToken":"t1"}`,
+		"t1": `{"Links":[{"LinkId":"link-3"}],"NextToken":"t2"}`,
+		"t2": `{"Links":[{"LinkId":"link-4"}]}`,
+	}}
+	r, err := newFakeNMgr(f).GetLinks()
+	if err != nil {
+		t.Fatalf("GetLinks: %v", err)
+	}
+	var ids []string
+	for _, l := range r.Links {
+		ids = append(ids, aws.ToString(l.LinkId))
+	}
+	if want := "[link-1 link-2 link-3 link-4]"; fmt.Sprint(ids) != want {
+		t.Errorf("links = %v, want %s", ids, want)
+	}
+	if want := `["" "t1" "t2"]`; fmt.Sprintf("%q", f.requests) != want {
+		t.Errorf("requests = %q, want %s", f.requests, want)
+	}
+}
+
+func TestGetLinksError(t *testing.T) {
+	f := &fakeNMgrHTTP{pages: map[string]string{
+		"": `{"Links":[{"LinkId":"link-1"}],"NextToken":"t1"}`,
+	}}
+	if _, err := newFakeNMgr(f).GetLinks(); err == nil {
+		t.Error("GetLinks succeeded with a failing second page")
+	}
+	if want := `["" "t1"]`; fmt.Sprintf("%q", f.requests) != want {
+		t.Errorf("requests = %q, want %s", f.requests, want)
+	}
+}
+
+func TestGetPages(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		pages map[string]string
+		get   func(nm *NMgr) ([]string, error)
+		want  string
+	}{
+		{
+			name: "sites",
+			pages: map[string]string{
+				"":   `{"Sites":[{"SiteId":"site-1"}],"NextToken":"t1"}`,
+				"t1": `{"Sites":[{"SiteId":"site-2"}]}`,
+			},
+			get: func(nm *NMgr) ([]string, error) {
+				r, err := nm.GetSites()
+				if err != nil {
+					return nil, err
+				}
+				var ids []string
+				for _, s := range r.Sites {
+					ids = append(ids, aws.ToString(s.SiteId))
+				}
+				return ids, nil
+			},
+			want: "[site-1 site-2]",
+		},
+		{
+			name: "devices",
+			pages: map[string]string{
+				"":   `{"Devices":[{"DeviceId":"device-1"},{"DeviceId":"device-2"}],"NextToken":"t1"}`,
+				"t1": `{"Devices":[{"DeviceId":"device-3"}]}`,
+			},
+			get: func(nm *NMgr) ([]string, error) {
+				r, err := nm.GetDevices()
+				if err != nil {
+					return nil, err
+				}
+				var ids []string
+				for _, d := range r.Devices {
+					ids = append(ids, aws.ToString(d.DeviceId))
+				}
+				return ids, nil
+			},
+			want: "[device-1 device-2 device-3]",
+		},
+		{
+			name: "customer gateway associations",
+			pages: map[string]string{
+				"":   `{"CustomerGatewayAssociations":[{"CustomerGatewayArn":"cgw-1"}],"NextToken":"t1"}`,
+				"t1": `{"CustomerGatewayAssociations":[{"CustomerGatewayArn":"cgw-2"}],"NextToken":"t2"}`,
+				"t2": `{"CustomerGatewayAssociations":[{"CustomerGatewayArn":"cgw-3"}]}`,
+			},
+			get: func(nm *NMgr) ([]string, error) {
+				r, err := nm.GetCustomerGatewayAssociations()
+				if err != nil {
+					return nil, err
+				}
+				var ids []string
+				for _, a := range r.CustomerGatewayAssociations {
+					ids = append(ids, aws.ToString(a.CustomerGatewayArn))
+				}
+				return ids, nil
+			},
+			want: "[cgw-1 cgw-2 cgw-3]",
+		},
+		{
+			name: "transit gateway registrations",
+			pages: map[string]string{
+				"":   `{"TransitGatewayRegistrations":[{"TransitGatewayArn":"tgw-1"}],"NextToken":"t1"}`,
+				"t1": `{"TransitGatewayRegistrations":[{"TransitGatewayArn":"tgw-2"}]}`,
+			},
+			get: func(nm *NMgr) ([]string, error) {
+				r, err := nm.GetTransitGatewayRegistrations()
+				if err != nil {
+					return nil, err
+				}
+				var ids []string
+				for _, reg := range r.TransitGatewayRegistrations {
+					ids = append(ids, aws.ToString(reg.TransitGatewayArn))
+				}
+				return ids, nil
+			},
+			want: "[tgw-1 tgw-2]",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &fakeNMgrHTTP{pages: tc.pages}
+			ids, err := tc.get(newFakeNMgr(f))
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			if fmt.Sprint(ids) != tc.want {
+				t.Errorf("ids = %v, want %s", ids, tc.want)
+			}
+			if len(f.requests) != len(tc.pages) {
+				t.Errorf("requests = %q, want %d", f.requests, len(tc.pages))
+			}
+		})
+	}
+}
+
+// fakeEC2HTTP answers the EC2 query requests with the XML of the answer function, an
+// empty answer is a non retryable error
+type fakeEC2HTTP struct {
+	answer   func(form url.Values) string
+	requests []url.Values
+}
+
+func (f *fakeEC2HTTP) Do(req *http.Request) (*http.Response, error) {
+	b, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	form, err := url.ParseQuery(string(b))
+	if err != nil {
+		return nil, err
+	}
+	f.requests = append(f.requests, form)
+	body := f.answer(form)
+	status := http.StatusOK
+	if body == "" {
+		status = http.StatusBadRequest
+		body = `<Response><Errors><Error><Code>InvalidParameterValue</Code><Message>invalid request</Message></Error></Errors></Response>`
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"text/xml"}},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func newFakeEC2(f *fakeEC2HTTP) *NMgr {
+	return &NMgr{
+		ctx: context.Background(),
+		ClientEC2: map[string]*ec2.Client{"us-west-2": ec2.New(ec2.Options{
+			Region:      "us-west-2",
+			HTTPClient:  f,
+			Credentials: fakeCredentials,
+		})},
+	}
+}
+
+func TestDescribeTransitGatewaysPages(t *testing.T) {
+	pages := map[string]string{
+		"":   `<DescribeTransitGatewaysResponse><transitGatewaySet><item><transitGatewayId>tgw-1</transitGatewayId></item></transitGatewaySet><nextToken>t1</nextToken></DescribeTransitGatewaysResponse>`,
+		"t1": `<DescribeTransitGatewaysResponse><transitGatewaySet><item><transitGatewayId>tgw-2</transitGatewayId></item><item><transitGatewayId>tgw-3</transitGatewayId></item></transitGatewaySet></DescribeTransitGatewaysResponse>`,
+	}
+	f := &fakeEC2HTTP{answer: func(form url.Values) string { return pages[form.Get("NextToken")] }}
+	r, err := newFakeEC2(f).DescribeTransitGateways(aws.String("us-west-2"), aws.String("tgw"))
+	if err != nil {
+		t.Fatalf("DescribeTransitGateways: %v", err)
+	}
+	var ids []string
+	for _, tgw := range r.TransitGateways {
+		ids = append(ids, aws.ToString(tgw.TransitGatewayId))
+	}
+	if want := "[tgw-1 tgw-2 tgw-3]"; fmt.Sprint(ids) != want {
+		t.Errorf("transit gateways = %v, want %s", ids, want)
+	}
+	if len(f.requests) != 2 || f.requests[1].Get("NextToken") != "t1" {
+		t.Errorf("requests = %v, want a second request with token t1", f.requests)
+	}
+}
+
+// routeSearchAnswer answers the static route searches of the routes with at most max
+// routes per answer like the EC2 route search
+func routeSearchAnswer(routes []string, max int) func(form url.Values) string {
+	return func(form url.Values) string {
+		var match, cidr string
+		for i := 1; form.Get(fmt.Sprintf("Filter.%d.Name", i)) != ""; i++ {
+			if name := form.Get(fmt.Sprintf("Filter.%d.Name", i)); name != "type" {
+				match, cidr = name, form.Get(fmt.Sprintf("Filter.%d.Value.1", i))
+			}
+		}
+		var found []string
+		for _, r := range routes {
+			_, rn, _ := net.ParseCIDR(r)
+			if cidr != "" {
+				_, fn, _ := net.ParseCIDR(cidr)
+				rOnes, rBits := rn.Mask.Size()
+				fOnes, fBits := fn.Mask.Size()
+				switch match {
+				case "route-search.exact-match":
+					if rn.String() != fn.String() {
+						continue
+					}
+				case "route-search.subnet-of-match":
+					if rBits != fBits || rOnes < fOnes || !fn.Contains(rn.IP) {
+						continue
+					}
+				default:
+					return ""
+				}
+			}
+			found = append(found, r)
+		}
+		more := len(found) > max
+		if more {
+			found = found[:max]
+		}
+		var b strings.Builder
+		b.WriteString("<SearchTransitGatewayRoutesResponse><routeSet>")
+		for _, r := range found {
+			fmt.Fprintf(&b, "<item><destinationCidrBlock>%s</destinationCidrBlock><type>static</type></item>", r)
+		}
+		fmt.Fprintf(&b, "</routeSet><additionalRoutesAvailable>%t</additionalRoutesAvailable></SearchTransitGatewayRoutesResponse>", more)
+		return b.String()
+	}
+}
+
+func TestSearchTransitGatewayStaticRoutesSplit(t *testing.T) {
+	defer func(max int32) { maxRouteSearchResults = max }(maxRouteSearchResults)
+	maxRouteSearchResults = 2
+
+	routes := []string{"0.0.0.0/0", "10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24", "172.16.0.0/16", "192.168.1.0/24", "192.168.2.0/24", "2001:db8::/64"}
+	for _, tc := range []struct {
+		name   string
+		routes []string
+		split  bool
+	}{
+		{name: "one search", routes: routes[1:3]},
+		{name: "split", routes: routes, split: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &fakeEC2HTTP{answer: routeSearchAnswer(tc.routes, int(maxRouteSearchResults))}
+			r, err := newFakeEC2(f).SearchTransitGatewayStaticRoutes(aws.String("us-west-2"), aws.String("tgw-rtb-1"))
+			if err != nil {
+				t.Fatalf("SearchTransitGatewayStaticRoutes: %v", err)
+			}
+			var got []string
+			for _, route := range r.Routes {
+				got = append(got, aws.ToString(route.DestinationCidrBlock))
+			}
+			sort.Strings(got)
+			want := append([]string(nil), tc.routes...)
+			sort.Strings(want)
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("routes = %v, want %v", got, want)
+			}
+			if split := len(f.requests) > 1; split != tc.split {
+				t.Errorf("%d searches, want split %t", len(f.requests), tc.split)
+			}
+		})
+	}
+}
+
+func TestSplitPrefix(t *testing.T) {
+	for _, tc := range []struct {
+		prefix string
+		want   string
+	}{
+		{prefix: "0.0.0.0/0", want: "[0.0.0.0/1 128.0.0.0/1]"},
+		{prefix: "10.0.0.0/8", want: "[10.0.0.0/9 10.128.0.0/9]"},
+		{prefix: "10.0.0.0/23", want: "[10.0.0.0/24 10.0.1.0/24]"},
+		{prefix: "10.0.0.1/32", want: "[]"},
+		{prefix: "::/0", want: "[::/1 8000::/1]"},
+	} {
+		_, n, _ := net.ParseCIDR(tc.prefix)
+		if got := fmt.Sprint(splitPrefix(n)); got != tc.want {
+			t.Errorf("splitPrefix(%s) = %s, want %s", tc.prefix, got, tc.want)
+		}
+	}
+}
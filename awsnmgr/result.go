@@ -0,0 +1,138 @@
+package awsnmgr
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ResourceResult is a resource a command created, updated or deleted
+type ResourceResult struct {
+	Kind string `json:"kind" yaml:"kind"`
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	ID   string `json:"id,omitempty" yaml:"id,omitempty"`
+	ARN  string `json:"arn,omitempty" yaml:"arn,omitempty"`
+}
+
+// WaitResult is a wait of a command till resources reach a state
+type WaitResult struct {
+	Name     string `json:"name" yaml:"name"`
+	Duration string `json:"duration" yaml:"duration"`
+}
+
+// Result is the structured result of a command, the warnings and errors are the ones
+// that are logged during the run
+type Result struct {
+	Command        string              `json:"command" yaml:"command"`
+	RunID          string              `json:"runId,omitempty" yaml:"run-id,omitempty"`
+	Created        []ResourceResult    `json:"created,omitempty" yaml:"created,omitempty"`
+	Updated        []ResourceResult    `json:"updated,omitempty" yaml:"updated,omitempty"`
+	Deleted        []ResourceResult    `json:"deleted,omitempty" yaml:"deleted,omitempty"`
+	RolledBack     []ResourceResult    `json:"rolledBack,omitempty" yaml:"rolled-back,omitempty"`
+	Waits          []WaitResult        `json:"waits,omitempty" yaml:"waits,omitempty"`
+	VpnConnections []*ConnectionStatus `json:"vpnConnections,omitempty" yaml:"vpn-connections,omitempty"`
+	Warnings       []string            `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+	Errors         []string            `json:"errors,omitempty" yaml:"errors,omitempty"`
+
+	mu sync.Mutex
+}
+
+// resultHook collects the logged warnings and errors in the result
+type resultHook struct {
+	result *Result
+}
+
+func (h *resultHook) Levels() []log.Level {
+	return []log.Level{log.PanicLevel, log.FatalLevel, log.ErrorLevel, log.WarnLevel}
+}
+
+func (h *resultHook) Fire(e *log.Entry) error {
+	h.result.mu.Lock()
+	defer h.result.mu.Unlock()
+	if e.Level == log.WarnLevel {
+		h.result.Warnings = append(h.result.Warnings, e.Message)
+	} else {
+		h.result.Errors = append(h.result.Errors, e.Message)
+	}
+	return nil
+}
+
+func newResult() *Result {
+	r := &Result{}
+	log.AddHook(&resultHook{result: r})
+	return r
+}
+
+// AddError adds an error to the result when it is not logged already
+func (r *Result) AddError(msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.Errors {
+		if e == msg {
+			return
+		}
+	}
+	r.Errors = append(r.Errors, msg)
+}
+
+// created records a resource the command created, the resources in the deploy journal
+// are recorded when they are journaled, the shared Nuage domains and IKE profiles are
+// recorded without journal so a rollback leaves them in place
+func (nm *NMgr) created(kind, name, id, arn string) {
+	nm.result.mu.Lock()
+	nm.result.Created = append(nm.result.Created, ResourceResult{Kind: kind, Name: name, ID: id, ARN: arn})
+	nm.result.mu.Unlock()
+	nm.notifyResource(EventResourceCreated, kind, name, id, arn)
+}
+
+// updated records a resource the command changed because it drifted from the topology
+func (nm *NMgr) updated(kind, name, id, arn string) {
+	nm.result.mu.Lock()
+	nm.result.Updated = append(nm.result.Updated, ResourceResult{Kind: kind, Name: name, ID: id, ARN: arn})
+	nm.result.mu.Unlock()
+	nm.notifyResource(EventDriftDetected, kind, name, id, arn)
+}
+
+// deleted records a resource the command deleted
+func (nm *NMgr) deleted(kind, name, id, arn string) {
+	nm.result.mu.Lock()
+	nm.result.Deleted = append(nm.result.Deleted, ResourceResult{Kind: kind, Name: name, ID: id, ARN: arn})
+	nm.result.mu.Unlock()
+	nm.notifyResource(EventResourceDeleted, kind, name, id, arn)
+}
+
+// trackWait records the duration of a wait, it is deferred at the start of the wait
+func (nm *NMgr) trackWait(name string, start time.Time) {
+	nm.result.mu.Lock()
+	defer nm.result.mu.Unlock()
+	nm.result.Waits = append(nm.result.Waits, WaitResult{Name: name, Duration: time.Since(start).Round(time.Second).String()})
+}
+
+// SetVpnConnections adds the status of the vpn connections and their tunnels to the result
+func (nm *NMgr) SetVpnConnections(status []*ConnectionStatus) {
+	nm.result.VpnConnections = status
+}
+
+// Result returns the result of the command, the rolled back resources are the ones the
+// rollback undid from the deploy journal
+func (nm *NMgr) Result(command string) *Result {
+	r := nm.result
+	r.Command = command
+	r.RunID = nm.runID
+	r.RolledBack = nil
+	undone := make(map[string]bool)
+	for _, s := range nm.journal.undone {
+		r.RolledBack = append(r.RolledBack, ResourceResult{Kind: s.Kind, Name: s.Name, ID: s.ID, ARN: s.ARN})
+		undone[s.Kind+"/"+s.ID] = true
+	}
+	// the deletes of the rollback are reported as rolled back
+	deleted := r.Deleted[:0]
+	for _, d := range r.Deleted {
+		if !undone[d.Kind+"/"+d.ID] {
+			deleted = append(deleted, d)
+		}
+	}
+	r.Deleted = deleted
+	return r
+}
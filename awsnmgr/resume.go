@@ -0,0 +1,248 @@
+package awsnmgr
+
+import (
+	"encoding/xml"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	nmtypes "github.com/aws/aws-sdk-go-v2/service/networkmanager/types"
+	"github.com/henderiw/nuage-wrapper/pkg/vspk"
+	log "github.com/sirupsen/logrus"
+)
+
+// resumePhase returns if a completed phase of a resumed deploy is skipped. The restore
+// function verifies the checkpointed ids still exist and sets them on the topology, when
+// they don't the phase and all the phases after it run again
+func (nm *NMgr) resumePhase(phase string, restore func() (bool, error)) (bool, error) {
+	if !nm.phaseDone(phase) {
+		return false, nil
+	}
+	if restore != nil {
+		ok, err := restore()
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			log.Infof("Checkpointed ids of phase %s do not match, running the phase and the next phases again", phase)
+			nm.resume = false
+			return false, nil
+		}
+	}
+	return nm.skipPhase(phase), nil
+}
+
+// skipPhase returns if the phase is skipped because it completed in a previous run
+func (nm *NMgr) skipPhase(phase string) bool {
+	if !nm.phaseDone(phase) {
+		return false
+	}
+	log.Infof("Skip phase %s, completed in a previous run", phase)
+	return true
+}
+
+// restoreSites sets the checkpointed site ids that still exist in the global network
+func (nm *NMgr) restoreSites() (bool, error) {
+	r, err := nm.GetSites()
+	if err != nil {
+		return false, err
+	}
+	ids := make(map[string]bool)
+	for _, s := range r.Sites {
+		if s.State != nmtypes.SiteStateDeleting {
+			ids[strValue(s.SiteId)] = true
+		}
+	}
+	for name, site := range nm.Sites {
+		id := nm.state.Sites[name]
+		if !ids[id] {
+			log.Infof("Checkpointed site %s %s not found", name, id)
+			return false, nil
+		}
+		site.SiteID = &id
+	}
+	return true, nil
+}
+
+// restoreDevicesLinks sets the checkpointed device, link and tgw ids that still exist
+// and looks up the Nuage NSGs and vlans of the devices
+func (nm *NMgr) restoreDevicesLinks(enterprise *vspk.Enterprise) (bool, error) {
+	rd, err := nm.GetDevices()
+	if err != nil {
+		return false, err
+	}
+	arns := make(map[string]*string)
+	for _, d := range rd.Devices {
+		if d.State != nmtypes.DeviceStateDeleting {
+			arns[strValue(d.DeviceId)] = d.DeviceArn
+		}
+	}
+	rl, err := nm.GetLinks()
+	if err != nil {
+		return false, err
+	}
+	links := make(map[string]bool)
+	for _, l := range rl.Links {
+		if l.State != nmtypes.LinkStateDeleting {
+			links[strValue(l.LinkId)] = true
+		}
+	}
+
+	for name, device := range nm.Devices {
+		switch device.Kind {
+		case "sdwan":
+			id := nm.state.Devices[name]
+			arn, ok := arns[id]
+			if !ok {
+				log.Infof("Checkpointed device %s %s not found", name, id)
+				return false, nil
+			}
+			nsGateway, err := nm.getNsg(name, enterprise)
+			if err != nil {
+				return false, err
+			}
+			if nsGateway == nil {
+				log.Infof("Nuage NSG device does not exist: %s", name)
+				return false, nil
+			}
+			device.NuageNSGateway = nsGateway
+			device.DeviceID = &id
+			device.DeviceARN = arn
+			for _, ep := range device.Endpoints {
+				linkID := nm.state.Links[ep.Name]
+				if !links[linkID] {
+					log.Infof("Checkpointed link %s %s not found", ep.Name, linkID)
+					return false, nil
+				}
+				nsVlan, err := nm.getEndpointVlan(ep, nsGateway)
+				if err != nil {
+					return false, err
+				}
+				ep.NuageVlan = nsVlan
+				ep.LinkID = &linkID
+			}
+		case "tgw":
+			id := nm.state.TransitGateways[name]
+			if id == "" {
+				return false, nil
+			}
+			r, err := nm.DescribeTransitGateway(&device.Region, &id)
+			if err != nil {
+				log.Infof("Checkpointed transit GW %s %s not found: %s", name, id, err)
+				return false, nil
+			}
+			if len(r.TransitGateways) == 0 || r.TransitGateways[0].State != types.TransitGatewayStateAvailable {
+				log.Infof("Checkpointed transit GW %s %s is not available", name, id)
+				return false, nil
+			}
+			nm.setTransitGateway(device, &r.TransitGateways[0])
+		}
+	}
+	return true, nil
+}
+
+// restoreRouteTables sets the checkpointed tgw route table ids that are still available
+func (nm *NMgr) restoreRouteTables() (bool, error) {
+	for deviceName, d := range nm.Devices {
+		if d.Kind != "tgw" || d.DeviceID == nil {
+			continue
+		}
+		d.RouteTables = make(map[string]*string)
+		for rtName := range nm.Config.Topology.RouteTables {
+			name := deviceName + "-" + rtName
+			id := nm.state.RouteTables[name]
+			if id == "" {
+				return false, nil
+			}
+			r, err := nm.DescribeTransitGatewayRouteTable(&d.Region, &id)
+			if err != nil {
+				log.Infof("Checkpointed transit GW route table %s %s not found: %s", name, id, err)
+				return false, nil
+			}
+			if len(r.TransitGatewayRouteTables) == 0 || r.TransitGatewayRouteTables[0].State != types.TransitGatewayRouteTableStateAvailable {
+				log.Infof("Checkpointed transit GW route table %s %s is not available", name, id)
+				return false, nil
+			}
+			d.RouteTables[rtName] = &id
+		}
+	}
+	return true, nil
+}
+
+// restoreVpcs sets the checkpointed VPC and tgw attachment ids that are still available
+func (nm *NMgr) restoreVpcs() (bool, error) {
+	for vpcName, vpc := range nm.Vpcs {
+		vpcID, attachmentID := nm.state.Vpcs[vpcName], nm.state.VpcAttachments[vpcName]
+		if vpcID == "" || attachmentID == "" {
+			return false, nil
+		}
+		r, err := nm.DescribeVpc(&vpc.Region, &vpcID)
+		if err != nil || len(r.Vpcs) == 0 {
+			log.Infof("Checkpointed VPC %s %s not found", vpcName, vpcID)
+			return false, nil
+		}
+		ra, err := nm.DescribeTransitGatewayAttachment(&vpc.Region, &attachmentID)
+		if err != nil || len(ra.TransitGatewayAttachments) == 0 || ra.TransitGatewayAttachments[0].State != types.TransitGatewayAttachmentStateAvailable {
+			log.Infof("Checkpointed transit GW VPC attachment %s %s is not available", vpcName, attachmentID)
+			return false, nil
+		}
+		vpc.VpcID = &vpcID
+		if vpc.Cidr == "" && r.Vpcs[0].CidrBlock != nil {
+			vpc.Cidr = *r.Vpcs[0].CidrBlock
+		}
+		vpc.AttachmentID = &attachmentID
+	}
+	return true, nil
+}
+
+// restoreVpnConnections sets the checkpointed customer gateway and VPN connection ids that
+// still exist and reads the tunnel addresses from the VPN connection configuration
+func (nm *NMgr) restoreVpnConnections() (bool, error) {
+	for _, conn := range nm.Connections {
+		ep := conn.A
+		if ep.Device.Kind != "sdwan" || ep.PublicIP == "" {
+			continue
+		}
+		cgwID := nm.state.CustomerGateways[ep.Name]
+		if cgwID == "" {
+			return false, nil
+		}
+		rc, err := nm.DescribeCustomerGateway(&ep.Region, &cgwID)
+		if err != nil || len(rc.CustomerGateways) == 0 || strValue(rc.CustomerGateways[0].State) != "available" {
+			log.Infof("Checkpointed customer gateway %s %s is not available", ep.Name, cgwID)
+			return false, nil
+		}
+		if conn.B.Device.Kind == "tgw" {
+			vpnID := nm.state.VpnConnections[ep.Name]
+			if vpnID == "" {
+				return false, nil
+			}
+			rv, err := nm.DescribeVpnConnection(&ep.Region, &vpnID)
+			if err != nil || len(rv.VpnConnections) == 0 {
+				log.Infof("Checkpointed VPN connection %s %s not found", ep.Name, vpnID)
+				return false, nil
+			}
+			v := rv.VpnConnections[0]
+			if v.State == types.VpnStateDeleting || v.State == types.VpnStateDeleted || v.CustomerGatewayConfiguration == nil {
+				log.Infof("Checkpointed VPN connection %s %s is %s", ep.Name, vpnID, v.State)
+				return false, nil
+			}
+			vpnConn := VpnConnection{}
+			if err := xml.Unmarshal([]byte(*v.CustomerGatewayConfiguration), &vpnConn); err != nil {
+				return false, err
+			}
+			ep.CustomerGatewayIP, ep.TunnelInsideIPs = nil, nil
+			for _, ipsec := range vpnConn.IpsecTunnel {
+				ep.CustomerGatewayIP = append(ep.CustomerGatewayIP, ipsec.VpnGateway.TunnelOutsideAddress.IPAddress)
+				ep.TunnelInsideIPs = append(ep.TunnelInsideIPs, ipsec.VpnGateway.TunnelInsideAddress.IPAddress)
+			}
+			ep.VpnConnectionID = &vpnID
+		}
+		customerGatewayArn, err := nm.customerGatewayARN(ep.Region, cgwID)
+		if err != nil {
+			return false, err
+		}
+		ep.CustomerGatewayID = &cgwID
+		ep.CustomerGatewayARN = &customerGatewayArn
+		ep.VPNConnState = "not available"
+	}
+	return true, nil
+}
@@ -0,0 +1,211 @@
+package awsnmgr
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/awslabs/smithy-go"
+	"github.com/awslabs/smithy-go/middleware"
+	"github.com/nuagenetworks/go-bambou/bambou"
+	log "github.com/sirupsen/logrus"
+)
+
+// RetryPolicy defines how failed AWS and VSD API calls are retried, MaxTotal caps the
+// total time a run spends waiting on retries, once it is spent errors are returned directly
+type RetryPolicy struct {
+	MaxAttempts int
+	MaxBackoff  time.Duration
+	MaxTotal    time.Duration
+}
+
+var defaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 8,
+	MaxBackoff:  20 * time.Second,
+	MaxTotal:    5 * time.Minute,
+}
+
+// AWS error codes that are retried on top of the retryable errors of the SDK
+var awsRetryableCodes = []string{
+	"ThrottlingException",
+	"Throttling",
+	"RequestLimitExceeded",
+	"TooManyRequestsException",
+	"InternalServerException",
+	"ServiceUnavailable",
+	"Unavailable",
+	"InternalError",
+}
+
+// retryStats counts the retries and the failed calls per API within a run
+type retryStats struct {
+	mu      sync.Mutex
+	counts  map[string]int
+	errors  map[string]int
+	waited  time.Duration
+	maxWait time.Duration
+}
+
+func newRetryStats(maxWait time.Duration) *retryStats {
+	return &retryStats{
+		counts:  make(map[string]int),
+		errors:  make(map[string]int),
+		maxWait: maxWait,
+	}
+}
+
+// addError records a call of the API that failed after its retries
+func (s *retryStats) addError(api string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors[api]++
+}
+
+// resetBudget starts a new retry budget, used by serve between polls
+func (s *retryStats) resetBudget() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.waited = 0
+}
+
+// add records a retry of the API and returns false when the retry budget is spent
+func (s *retryStats) add(api string, delay time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.waited+delay > s.maxWait {
+		return false
+	}
+	s.waited += delay
+	s.counts[api]++
+	return true
+}
+
+func (s *retryStats) budgetLeft() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.waited < s.maxWait
+}
+
+// WithRetryPolicy function
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(nm *NMgr) {
+		nm.retryPolicy = p
+		nm.retries = newRetryStats(p.MaxTotal)
+	}
+}
+
+// awsRetryer extends the standard retryer of the SDK with the retry policy, the
+// throttling error codes and the retry counters of the run
+type awsRetryer struct {
+	aws.Retryer
+	stats *retryStats
+}
+
+func (nm *NMgr) newAWSRetryer() aws.Retryer {
+	std := retry.NewStandard(func(o *retry.StandardOptions) {
+		o.MaxAttempts = nm.retryPolicy.MaxAttempts
+		o.MaxBackoff = nm.retryPolicy.MaxBackoff
+		o.Retryables = append(o.Retryables, retry.IsErrorRetryableFunc(func(err error) aws.Ternary {
+			var apiErr smithy.APIError
+			if errors.As(err, &apiErr) && contains(awsRetryableCodes, apiErr.ErrorCode()) {
+				return aws.TrueTernary
+			}
+			return aws.UnknownTernary
+		}))
+	})
+	return &awsRetryer{Retryer: std, stats: nm.retries}
+}
+
+// IsErrorRetryable returns if the error is retryable and the retry budget is not spent
+func (r *awsRetryer) IsErrorRetryable(err error) bool {
+	return r.Retryer.IsErrorRetryable(err) && r.stats.budgetLeft()
+}
+
+// RetryDelay returns the delay before the retry and records the retry
+func (r *awsRetryer) RetryDelay(attempt int, err error) (time.Duration, error) {
+	delay, dErr := r.Retryer.RetryDelay(attempt, err)
+	if dErr != nil {
+		return delay, dErr
+	}
+	api := "aws"
+	var opErr *smithy.OperationError
+	if errors.As(err, &opErr) {
+		api = opErr.Service() + "." + opErr.Operation()
+	}
+	if !r.stats.add(api, delay) {
+		return 0, errors.New("retry budget of the run is spent")
+	}
+	log.Debugf("Retry %s attempt %d after %s: %s", api, attempt, delay, err)
+	return delay, nil
+}
+
+// addAPIErrorCounter counts the AWS calls that fail after their retries, it runs after
+// the service metadata is set in the context and before the retries of the finalize step
+func (nm *NMgr) addAPIErrorCounter(cfg *aws.Config) {
+	stats := nm.retries
+	cfg.APIOptions = append(cfg.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("APIErrorCounter", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			out, md, err := next.HandleInitialize(ctx, in)
+			if err != nil {
+				stats.addError(awsmiddleware.GetServiceID(ctx) + "." + awsmiddleware.GetOperationName(ctx))
+			}
+			return out, md, err
+		}), middleware.After)
+	})
+}
+
+// isVSDErrorRetryable returns if the VSD error is transient, HTTP client errors and
+// 5xx or 429 responses are retried, VSD errors like conflicts are not
+func isVSDErrorRetryable(err *bambou.Error) bool {
+	switch err.Title {
+	case "HTTP client error":
+		return true
+	case "HTTP error":
+		return strings.HasPrefix(err.Description, "5") || strings.HasPrefix(err.Description, "429")
+	}
+	return false
+}
+
+// retryVSD calls the VSD API until it succeeds, the error is not retryable or the
+// retry policy is exhausted
+func (nm *NMgr) retryVSD(api string, call func() *bambou.Error) *bambou.Error {
+	var err *bambou.Error
+	for attempt := 1; ; attempt++ {
+		if err = call(); err == nil || !isVSDErrorRetryable(err) || attempt >= nm.retryPolicy.MaxAttempts {
+			if err != nil {
+				nm.retries.addError("vsd." + api)
+			}
+			return err
+		}
+		// exponential backoff with full jitter
+		backoff := time.Duration(1<<uint(attempt)) * time.Second
+		if backoff > nm.retryPolicy.MaxBackoff {
+			backoff = nm.retryPolicy.MaxBackoff
+		}
+		delay := time.Duration(rand.Int63n(int64(backoff)))
+		if !nm.retries.add("vsd."+api, delay) {
+			nm.retries.addError("vsd." + api)
+			return err
+		}
+		log.Debugf("Retry vsd.%s attempt %d after %s: %s", api, attempt, delay, err.Description)
+		time.Sleep(delay)
+	}
+}
+
+// LogRetrySummary logs the number of retries per API of the run
+func (nm *NMgr) LogRetrySummary() {
+	nm.retries.mu.Lock()
+	defer nm.retries.mu.Unlock()
+	total := 0
+	for api, n := range nm.retries.counts {
+		log.Infof("Retries %s: %d", api, n)
+		total += n
+	}
+	log.Infof("Total API retries: %d (waited %s)", total, nm.retries.waited.Round(time.Millisecond))
+}
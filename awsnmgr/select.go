@@ -0,0 +1,192 @@
+package awsnmgr
+
+import (
+	"fmt"
+	"path"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// selection records which sdwan devices and sites are fully selected, a device is only
+// deleted when all its endpoints are selected and a site when all its devices are
+type selection struct {
+	devices map[string]bool
+	sites   map[string]bool
+}
+
+// matchAny returns if the name matches one of the glob patterns
+func matchAny(patterns []string, name string) (bool, error) {
+	for _, p := range patterns {
+		ok, err := path.Match(p, name)
+		if err != nil {
+			return false, fmt.Errorf("invalid selector %s: %s", p, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SelectTopology limits the parsed topology to the branches that match the site, device
+// or connection glob patterns, connections are matched on the endpoint name
+// (<site>-<device>-<port>). The tgws, vpcs, peerings and route tables are shared by all
+// branches and stay in the topology, without patterns the whole topology is selected
+func (nm *NMgr) SelectTopology(sites, devices, connections []string) error {
+	if len(sites) == 0 && len(devices) == 0 && len(connections) == 0 {
+		return nil
+	}
+
+	selected := make(map[*Endpoint]bool)
+	for i := 0; i < len(nm.Connections); i++ {
+		ep := nm.Connections[i].A
+		if ep.Device.Kind != "sdwan" {
+			continue
+		}
+		siteMatch, err := matchAny(sites, ep.Site.Name)
+		if err != nil {
+			return err
+		}
+		deviceMatch, err := matchAny(devices, ep.Device.Name)
+		if err != nil {
+			return err
+		}
+		connMatch, err := matchAny(connections, ep.Name)
+		if err != nil {
+			return err
+		}
+		if siteMatch || deviceMatch || connMatch {
+			selected[ep] = true
+		}
+	}
+	if len(selected) == 0 {
+		return fmt.Errorf("no connections match the site, device or connection selectors")
+	}
+
+	nm.selection = &selection{
+		devices: make(map[string]bool),
+		sites:   make(map[string]bool),
+	}
+
+	// keep the selected connections in their topology order
+	conns := make(map[int]*Connection)
+	for i := 0; i < len(nm.Connections); i++ {
+		if c := nm.Connections[i]; selected[c.A] {
+			endpointLog(c.A).Infof("Select connection: %s", c.A.Name)
+			conns[len(conns)] = c
+		}
+	}
+	nm.Connections = conns
+
+	for name, d := range nm.Devices {
+		if d.Kind != "sdwan" {
+			continue
+		}
+		full := len(d.Endpoints) > 0
+		eps := make(map[string]*Endpoint)
+		for epName, ep := range d.Endpoints {
+			if selected[ep] {
+				eps[epName] = ep
+			} else {
+				full = false
+			}
+		}
+		if len(eps) == 0 {
+			delete(nm.Devices, name)
+			continue
+		}
+		d.Endpoints = eps
+		nm.selection.devices[name] = full
+	}
+
+	for name, s := range nm.Sites {
+		full := len(s.Devices) > 0
+		kept := false
+		for deviceName := range s.Devices {
+			if _, ok := nm.Devices[deviceName]; ok {
+				kept = true
+			}
+			if !nm.selection.devices[deviceName] {
+				full = false
+			}
+		}
+		if !kept {
+			delete(nm.Sites, name)
+			continue
+		}
+		nm.selection.sites[name] = full
+	}
+	return nil
+}
+
+// selectedAll returns if the whole topology is selected
+func (nm *NMgr) selectedAll() bool {
+	return nm.selection == nil
+}
+
+// fullySelectedDevice returns if all endpoints of the device are selected
+func (nm *NMgr) fullySelectedDevice(name string) bool {
+	return nm.selection == nil || nm.selection.devices[name]
+}
+
+// fullySelectedSite returns if all devices of the site are selected
+func (nm *NMgr) fullySelectedSite(name string) bool {
+	return nm.selection == nil || nm.selection.sites[name]
+}
+
+// selectedLink returns if the link belongs to a selected endpoint
+func (nm *NMgr) selectedLink(linkID string) bool {
+	for _, d := range nm.Devices {
+		for _, ep := range d.Endpoints {
+			if strValue(ep.LinkID) == linkID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deleteSelectedSites deletes the links of the selected endpoints, the devices whose
+// endpoints are all selected and the sites whose devices are all selected
+func (nm *NMgr) deleteSelectedSites() {
+	log.Infof("Deleting selected Links....")
+	for _, d := range nm.Devices {
+		for _, ep := range d.Endpoints {
+			if ep.LinkID == nil {
+				continue
+			}
+			endpointLog(ep).WithField("aws_id", *ep.LinkID).Infof("Delete link: %s %s", ep.Name, *ep.LinkID)
+			if _, err := nm.DeleteLink(ep.LinkID); err != nil {
+				endpointLog(ep).Errorf("Error deleting link: %s", err)
+			}
+		}
+	}
+	log.Infof("Deleting selected Devices....")
+	for name, d := range nm.Devices {
+		if d.Kind != "sdwan" || d.DeviceID == nil {
+			continue
+		}
+		if !nm.fullySelectedDevice(name) {
+			deviceLog(d).Infof("Keep device %s, not all its connections are selected", name)
+			continue
+		}
+		deviceLog(d).Infof("Delete device: %s %s", name, *d.DeviceID)
+		if _, err := nm.DeleteDevice(d.DeviceID); err != nil {
+			deviceLog(d).Errorf("Error deleting device: %s", err)
+		}
+	}
+	log.Infof("Deleting selected Sites....")
+	for name, s := range nm.Sites {
+		if s.SiteID == nil {
+			continue
+		}
+		if !nm.fullySelectedSite(name) {
+			siteLog(name).Infof("Keep site %s, not all its devices are selected", name)
+			continue
+		}
+		siteLog(name).WithField("aws_id", *s.SiteID).Infof("Delete site: %s %s", name, *s.SiteID)
+		if _, err := nm.DeleteSite(s.SiteID); err != nil {
+			siteLog(name).Errorf("Error deleting site: %s", err)
+		}
+	}
+}
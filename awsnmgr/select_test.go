@@ -0,0 +1,171 @@
+package awsnmgr
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// selectNMgr returns a topology with site home1 with device nsg1 with ports port1 and
+// lte0, site home2 with device nsg2 with port port1 and a tgw
+func selectNMgr() *NMgr {
+	nm := &NMgr{
+		Sites:       make(map[string]*Site),
+		Devices:     make(map[string]*Device),
+		Connections: make(map[int]*Connection),
+	}
+	tgw := &Device{Name: "tgw-euc1", Kind: "tgw", Site: new(Site), Endpoints: make(map[string]*Endpoint)}
+	nm.Devices[tgw.Name] = tgw
+	for _, b := range []struct{ site, device, port string }{
+		{"home1", "nsg1", "port1"},
+		{"home1", "nsg1", "lte0"},
+		{"home2", "nsg2", "port1"},
+	} {
+		s, ok := nm.Sites[b.site]
+		if !ok {
+			s = &Site{Name: b.site, Devices: make(map[string]*Device)}
+			nm.Sites[b.site] = s
+		}
+		d, ok := nm.Devices[b.device]
+		if !ok {
+			d = &Device{Name: b.device, Kind: "sdwan", Site: s, Endpoints: make(map[string]*Endpoint)}
+			nm.Devices[b.device] = d
+			s.Devices[b.device] = d
+		}
+		ep := &Endpoint{Name: b.site + "-" + b.device + "-" + b.port, Site: s, Device: d}
+		d.Endpoints[ep.Name] = ep
+		nm.Connections[len(nm.Connections)] = &Connection{A: ep, B: &Endpoint{Name: tgw.Name, Device: tgw}}
+	}
+	return nm
+}
+
+// names returns the sorted keys of the map
+func names[V any](m map[string]V) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestSelectTopology(t *testing.T) {
+	for _, tc := range []struct {
+		name                        string
+		sites, devices, connections []string
+		conns, devs, sts            string
+		fullDevices, fullSites      string
+		err                         bool
+	}{
+		{
+			name:        "site",
+			sites:       []string{"home1"},
+			conns:       "[home1-nsg1-port1 home1-nsg1-lte0]",
+			devs:        "[nsg1 tgw-euc1]",
+			sts:         "[home1]",
+			fullDevices: "[nsg1]",
+			fullSites:   "[home1]",
+		},
+		{
+			name:        "site glob",
+			sites:       []string{"home*"},
+			conns:       "[home1-nsg1-port1 home1-nsg1-lte0 home2-nsg2-port1]",
+			devs:        "[nsg1 nsg2 tgw-euc1]",
+			sts:         "[home1 home2]",
+			fullDevices: "[nsg1 nsg2]",
+			fullSites:   "[home1 home2]",
+		},
+		{
+			name:        "device",
+			devices:     []string{"nsg2"},
+			conns:       "[home2-nsg2-port1]",
+			devs:        "[nsg2 tgw-euc1]",
+			sts:         "[home2]",
+			fullDevices: "[nsg2]",
+			fullSites:   "[home2]",
+		},
+		{
+			name:        "connection glob",
+			connections: []string{"*-lte?"},
+			conns:       "[home1-nsg1-lte0]",
+			devs:        "[nsg1 tgw-euc1]",
+			sts:         "[home1]",
+			fullDevices: "[]",
+			fullSites:   "[]",
+		},
+		{
+			name:        "device and connection",
+			devices:     []string{"nsg2"},
+			connections: []string{"home1-nsg1-port1"},
+			conns:       "[home1-nsg1-port1 home2-nsg2-port1]",
+			devs:        "[nsg1 nsg2 tgw-euc1]",
+			sts:         "[home1 home2]",
+			fullDevices: "[nsg2]",
+			fullSites:   "[home2]",
+		},
+		{name: "no match", sites: []string{"office*"}, err: true},
+		{name: "invalid pattern", devices: []string{"nsg["}, err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			nm := selectNMgr()
+			err := nm.SelectTopology(tc.sites, tc.devices, tc.connections)
+			if tc.err {
+				if err == nil {
+					t.Error("SelectTopology succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SelectTopology: %v", err)
+			}
+			var conns []string
+			for i := 0; i < len(nm.Connections); i++ {
+				conns = append(conns, nm.Connections[i].A.Name)
+			}
+			if got := fmt.Sprint(conns); got != tc.conns {
+				t.Errorf("connections = %s, want %s", got, tc.conns)
+			}
+			if got := fmt.Sprint(names(nm.Devices)); got != tc.devs {
+				t.Errorf("devices = %s, want %s", got, tc.devs)
+			}
+			if got := fmt.Sprint(names(nm.Sites)); got != tc.sts {
+				t.Errorf("sites = %s, want %s", got, tc.sts)
+			}
+			var fullDevices, fullSites []string
+			for name := range nm.Devices {
+				if nm.Devices[name].Kind == "sdwan" && nm.fullySelectedDevice(name) {
+					fullDevices = append(fullDevices, name)
+				}
+			}
+			for name := range nm.Sites {
+				if nm.fullySelectedSite(name) {
+					fullSites = append(fullSites, name)
+				}
+			}
+			sort.Strings(fullDevices)
+			sort.Strings(fullSites)
+			if got := fmt.Sprint(fullDevices); got != tc.fullDevices {
+				t.Errorf("fully selected devices = %s, want %s", got, tc.fullDevices)
+			}
+			if got := fmt.Sprint(fullSites); got != tc.fullSites {
+				t.Errorf("fully selected sites = %s, want %s", got, tc.fullSites)
+			}
+			if nm.selectedAll() {
+				t.Error("selectedAll with selectors")
+			}
+		})
+	}
+}
+
+func TestSelectTopologyAll(t *testing.T) {
+	nm := selectNMgr()
+	if err := nm.SelectTopology(nil, nil, nil); err != nil {
+		t.Fatalf("SelectTopology: %v", err)
+	}
+	if !nm.selectedAll() || len(nm.Connections) != 3 || len(nm.Devices) != 3 || len(nm.Sites) != 2 {
+		t.Errorf("topology without selectors is not fully selected: %d connections, %d devices, %d sites", len(nm.Connections), len(nm.Devices), len(nm.Sites))
+	}
+	if !nm.fullySelectedDevice("nsg1") || !nm.fullySelectedSite("home2") {
+		t.Error("devices and sites are not fully selected without selectors")
+	}
+}
@@ -0,0 +1,70 @@
+package awsnmgr
+
+import (
+	"io/ioutil"
+	"os"
+
+	"gopkg.in/yaml.v2"
+)
+
+// State holds the ids of the AWS resources that are managed through the topology,
+// indexed by their name in the topology. Links, customer gateways and vpn connections
+// are indexed by the endpoint name (<site>-<device>-<port>), tgw route tables by
+// <tgw>-<route table>. Phases lists the deploy phases that completed, a resumed deploy
+// verifies the ids of the completed phases and skips them.
+// PhaseDurations has the duration in seconds of the phases of the last deploy
+type State struct {
+	GlobalNetworkID  string             `yaml:"global-network-id,omitempty"`
+	TransitGateways  map[string]string  `yaml:"transit-gateways,omitempty"`
+	Sites            map[string]string  `yaml:"sites,omitempty"`
+	Devices          map[string]string  `yaml:"devices,omitempty"`
+	Links            map[string]string  `yaml:"links,omitempty"`
+	CustomerGateways map[string]string  `yaml:"customer-gateways,omitempty"`
+	VpnConnections   map[string]string  `yaml:"vpn-connections,omitempty"`
+	RouteTables      map[string]string  `yaml:"route-tables,omitempty"`
+	Vpcs             map[string]string  `yaml:"vpcs,omitempty"`
+	VpcAttachments   map[string]string  `yaml:"vpc-attachments,omitempty"`
+	Phases           []string           `yaml:"completed-phases,omitempty"`
+	PhaseDurations   map[string]float64 `yaml:"phase-durations,omitempty"`
+}
+
+// NewState returns an empty state
+func NewState() *State {
+	return &State{
+		TransitGateways:  make(map[string]string),
+		Sites:            make(map[string]string),
+		Devices:          make(map[string]string),
+		Links:            make(map[string]string),
+		CustomerGateways: make(map[string]string),
+		VpnConnections:   make(map[string]string),
+		RouteTables:      make(map[string]string),
+		Vpcs:             make(map[string]string),
+		VpcAttachments:   make(map[string]string),
+		PhaseDurations:   make(map[string]float64),
+	}
+}
+
+// LoadState reads the state from the file, a missing file returns an empty state
+func LoadState(file string) (*State, error) {
+	s := NewState()
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save writes the state to the file
+func (s *State) Save(file string) error {
+	b, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, b, 0644)
+}
@@ -0,0 +1,82 @@
+package awsnmgr
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// ConnectionStatus is a struct that contains the status of the vpn connection of a connection
+type ConnectionStatus struct {
+	Name            string         `json:"name" yaml:"name"`
+	Region          string         `json:"region" yaml:"region"`
+	VpnConnectionID string         `json:"vpnConnectionId" yaml:"vpn-connection-id"`
+	CustomerGateway string         `json:"customerGatewayId,omitempty" yaml:"customer-gateway-id,omitempty"`
+	State           string         `json:"state" yaml:"state"`
+	Accelerated     bool           `json:"accelerated" yaml:"accelerated"`
+	Tunnels         []TunnelStatus `json:"tunnels" yaml:"tunnels"`
+}
+
+// TunnelStatus is a struct that contains the status of a tunnel of a vpn connection
+type TunnelStatus struct {
+	OutsideIP      string `json:"outsideIp" yaml:"outside-ip"`
+	InsideCidr     string `json:"insideCidr" yaml:"inside-cidr"`
+	Status         string `json:"status" yaml:"status"`
+	StatusMessage  string `json:"statusMessage,omitempty" yaml:"status-message,omitempty"`
+	AcceptedRoutes int32  `json:"acceptedRoutes" yaml:"accepted-routes"`
+}
+
+// GetConnectionStatus returns the status of the vpn connections in the topology,
+// for accelerated vpn connections the outside IPs are the accelerator IPs
+func (nm *NMgr) GetConnectionStatus() ([]*ConnectionStatus, error) {
+	var status []*ConnectionStatus
+	for i := 0; i < len(nm.Connections); i++ {
+		conn := nm.Connections[i]
+		if conn.A.Device.Kind != "sdwan" || conn.B.Device.Kind != "tgw" || conn.A.PublicIP == "" {
+			continue
+		}
+		cs := &ConnectionStatus{
+			Name:        conn.A.Name,
+			Region:      conn.A.Region,
+			State:       "not found",
+			Accelerated: conn.A.Accelerated,
+		}
+		status = append(status, cs)
+
+		r, err := nm.DescribeVpnConnections(&conn.A.Region, &conn.A.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range r.VpnConnections {
+			if v.State == types.VpnStateDeleted || v.State == types.VpnStateDeleting {
+				continue
+			}
+			log.Debugf("VPN connection: %s %s", *v.VpnConnectionId, v.State)
+			cs.VpnConnectionID = *v.VpnConnectionId
+			cs.CustomerGateway = strValue(v.CustomerGatewayId)
+			cs.State = string(v.State)
+			if v.Options != nil {
+				cs.Accelerated = v.Options.EnableAcceleration
+				for _, t := range v.Options.TunnelOptions {
+					ts := TunnelStatus{}
+					if t.OutsideIpAddress != nil {
+						ts.OutsideIP = *t.OutsideIpAddress
+					}
+					if t.TunnelInsideCidr != nil {
+						ts.InsideCidr = *t.TunnelInsideCidr
+					}
+					for _, tm := range v.VgwTelemetry {
+						if tm.OutsideIpAddress != nil && *tm.OutsideIpAddress == ts.OutsideIP {
+							ts.Status = string(tm.Status)
+							ts.AcceptedRoutes = tm.AcceptedRouteCount
+							if tm.StatusMessage != nil {
+								ts.StatusMessage = *tm.StatusMessage
+							}
+						}
+					}
+					cs.Tunnels = append(cs.Tunnels, ts)
+				}
+			}
+		}
+	}
+	return status, nil
+}
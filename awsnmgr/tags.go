@@ -0,0 +1,153 @@
+package awsnmgr
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/networkmanager"
+	nmtypes "github.com/aws/aws-sdk-go-v2/service/networkmanager/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// managedByTagKey is the tag that marks the resources of the tool, it can be overruled
+// in the topology
+const (
+	managedByTagKey   = "managed-by"
+	managedByTagValue = "nuage-aws-networkmgr"
+)
+
+// mergeTags merges the tag maps, the tags of a later map overrule the earlier ones
+func mergeTags(maps ...map[string]string) map[string]string {
+	tags := map[string]string{managedByTagKey: managedByTagValue}
+	for _, m := range maps {
+		for k, v := range m {
+			tags[k] = v
+		}
+	}
+	return tags
+}
+
+// validateTags checks that the tags of the topology do not set the tags of the tool
+func validateTags(level string, tags map[string]string) error {
+	for k := range tags {
+		if k == "Name" || k == RunIDTagKey {
+			return fmt.Errorf("%s: tag '%s' is set by the tool", level, k)
+		}
+	}
+	return nil
+}
+
+// mergeTopologyTags merges the global, site, device and connection tags down the
+// hierarchy into the sites, devices and sdwan endpoints of the topology
+func (nm *NMgr) mergeTopologyTags() error {
+	if err := validateTags("global", nm.Config.Tags); err != nil {
+		return err
+	}
+	for name, s := range nm.Sites {
+		cfg := nm.Config.Topology.Sites[name]
+		if err := validateTags("site "+name, cfg.Tags); err != nil {
+			return err
+		}
+		s.Tags = mergeTags(nm.Config.Tags, cfg.Tags)
+	}
+	for name, d := range nm.Devices {
+		cfg := nm.Config.Topology.Devices[name]
+		if err := validateTags("device "+name, cfg.Tags); err != nil {
+			return err
+		}
+		var siteTags map[string]string
+		if s, ok := nm.Sites[d.Site.Name]; ok {
+			siteTags = s.Tags
+		}
+		d.Tags = mergeTags(nm.Config.Tags, siteTags, cfg.Tags)
+	}
+	for i := 0; i < len(nm.Connections); i++ {
+		ep := nm.Connections[i].A
+		if err := validateTags("connection "+ep.Name, ep.Tags); err != nil {
+			return err
+		}
+		ep.Tags = mergeTags(ep.Device.Tags, ep.Tags)
+	}
+	return nil
+}
+
+// changedTags returns the tags that are missing or have another value on the resource,
+// tags that are removed from the topology are left on the resource
+func changedTags(current, tags map[string]string) map[string]string {
+	changed := make(map[string]string)
+	for k, v := range tags {
+		if cv, ok := current[k]; !ok || cv != v {
+			changed[k] = v
+		}
+	}
+	return changed
+}
+
+// syncNetwTags updates the tags of an existing Network Manager resource to the topology
+func (nm *NMgr) syncNetwTags(kind, name string, arn *string, current []nmtypes.Tag, tags map[string]string) {
+	if arn == nil || tags == nil {
+		return
+	}
+	c := make(map[string]string)
+	for _, t := range current {
+		c[strValue(t.Key)] = strValue(t.Value)
+	}
+	changed := changedTags(c, tags)
+	if len(changed) == 0 {
+		return
+	}
+	log.WithField("aws_id", *arn).Infof("Update tags of %s %s: %v", kind, name, changed)
+	input := &networkmanager.TagResourceInput{
+		ResourceArn: arn,
+		Tags:        netwTags(changed),
+	}
+	if _, err := nm.ClientNMgr.TagResource(nm.ctx, input); err != nil {
+		log.WithField("aws_id", *arn).Errorf("Error tagging resource %s: %s", *arn, err)
+		return
+	}
+	nm.updated(kind+" tags", name, "", *arn)
+}
+
+// syncEC2Tags updates the tags of an existing EC2 resource to the topology
+func (nm *NMgr) syncEC2Tags(kind, name, region string, id *string, current []ec2types.Tag, tags map[string]string) {
+	if id == nil || tags == nil {
+		return
+	}
+	c := make(map[string]string)
+	for _, t := range current {
+		c[strValue(t.Key)] = strValue(t.Value)
+	}
+	changed := changedTags(c, tags)
+	if len(changed) == 0 {
+		return
+	}
+	awsLog(region, id).Infof("Update tags of %s %s: %v", kind, name, changed)
+	input := &ec2.CreateTagsInput{
+		Resources: []string{*id},
+		Tags:      ec2Tags(changed),
+	}
+	if _, err := nm.ClientEC2[region].CreateTags(nm.ctx, input); err != nil {
+		awsLog(region, id).Errorf("Error tagging resource %s: %s", *id, err)
+		return
+	}
+	nm.updated(kind+" tags", name, *id, "")
+}
+
+// netwTags returns the tags as Network Manager tags
+func netwTags(tags map[string]string) (t []nmtypes.Tag) {
+	for _, k := range sortedKeys(tags) {
+		key, value := k, tags[k]
+		t = append(t, nmtypes.Tag{Key: &key, Value: &value})
+	}
+	return t
+}
+
+// ec2Tags returns the tags as EC2 tags
+func ec2Tags(tags map[string]string) (t []ec2types.Tag) {
+	for _, k := range sortedKeys(tags) {
+		key, value := k, tags[k]
+		t = append(t, ec2types.Tag{Key: &key, Value: &value})
+	}
+	return t
+}
@@ -0,0 +1,91 @@
+package awsnmgr
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMergeTags(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		maps []map[string]string
+		want map[string]string
+	}{
+		{
+			name: "no tags",
+			want: map[string]string{managedByTagKey: managedByTagValue},
+		},
+		{
+			name: "later map overrules",
+			maps: []map[string]string{
+				{"env": "prod", "team": "net"},
+				nil,
+				{"env": "lab"},
+			},
+			want: map[string]string{managedByTagKey: managedByTagValue, "env": "lab", "team": "net"},
+		},
+		{
+			name: "managed-by overruled",
+			maps: []map[string]string{{managedByTagKey: "terraform"}},
+			want: map[string]string{managedByTagKey: "terraform"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := mergeTags(tc.maps...); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("mergeTags = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	for _, tc := range []struct {
+		tags map[string]string
+		err  bool
+	}{
+		{tags: nil},
+		{tags: map[string]string{"env": "prod", managedByTagKey: "other"}},
+		{tags: map[string]string{"Name": "branch"}, err: true},
+		{tags: map[string]string{RunIDTagKey: "run-1"}, err: true},
+	} {
+		if err := validateTags("site home1", tc.tags); (err != nil) != tc.err {
+			t.Errorf("validateTags(%v) error = %v, want error %t", tc.tags, err, tc.err)
+		}
+	}
+}
+
+func TestMergeTopologyTags(t *testing.T) {
+	nm := selectNMgr()
+	nm.Config = &Config{
+		Tags: map[string]string{"env": "prod", "owner": "net"},
+		Topology: Topology{
+			Sites:   map[string]SiteConfig{"home1": {Tags: map[string]string{"env": "lab", "site": "home1"}}},
+			Devices: map[string]DeviceConfig{"nsg1": {Tags: map[string]string{"model": "e300"}}},
+		},
+	}
+	nm.Devices["nsg1"].Endpoints["home1-nsg1-lte0"].Tags = map[string]string{"owner": "mobile"}
+	if err := nm.mergeTopologyTags(); err != nil {
+		t.Fatalf("mergeTopologyTags: %v", err)
+	}
+	for _, tc := range []struct {
+		name string
+		tags map[string]string
+		want string
+	}{
+		{name: "site home1", tags: nm.Sites["home1"].Tags, want: "map[env:lab managed-by:nuage-aws-networkmgr owner:net site:home1]"},
+		{name: "site home2", tags: nm.Sites["home2"].Tags, want: "map[env:prod managed-by:nuage-aws-networkmgr owner:net]"},
+		{name: "device nsg1", tags: nm.Devices["nsg1"].Tags, want: "map[env:lab managed-by:nuage-aws-networkmgr model:e300 owner:net site:home1]"},
+		{name: "device nsg2", tags: nm.Devices["nsg2"].Tags, want: "map[env:prod managed-by:nuage-aws-networkmgr owner:net]"},
+		{name: "connection lte0", tags: nm.Devices["nsg1"].Endpoints["home1-nsg1-lte0"].Tags, want: "map[env:lab managed-by:nuage-aws-networkmgr model:e300 owner:mobile site:home1]"},
+		{name: "connection port1", tags: nm.Devices["nsg1"].Endpoints["home1-nsg1-port1"].Tags, want: "map[env:lab managed-by:nuage-aws-networkmgr model:e300 owner:net site:home1]"},
+	} {
+		if got := fmt.Sprint(tc.tags); got != tc.want {
+			t.Errorf("%s tags = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+
+	nm.Config.Topology.Devices["nsg2"] = DeviceConfig{Tags: map[string]string{"Name": "nsg2"}}
+	if err := nm.mergeTopologyTags(); err == nil {
+		t.Error("mergeTopologyTags succeeded with a Name tag on a device")
+	}
+}
@@ -0,0 +1,163 @@
+package awsnmgr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/imdario/mergo"
+	"github.com/nuagenetworks/go-bambou/bambou"
+	log "github.com/sirupsen/logrus"
+)
+
+// vsdObject is a VSD object that is created under a parent object
+type vsdObject interface {
+	Identifier() string
+	Save() *bambou.Error
+	Delete() *bambou.Error
+}
+
+// vsdWriteOnly lists per kind the attributes the VSD does not return on a read, they
+// are sent when the object is saved but left out of the drift compare
+var vsdWriteOnly = map[string]map[string]bool{
+	"IKEPSK": {"UnencryptedPSK": true},
+}
+
+// vsdFind fetches the children of a parent and returns the first child that matches,
+// found is false when no child matches
+func vsdFind[T vsdObject](nm *NMgr, api string, fetch func() ([]T, *bambou.Error), match func(T) bool) (obj T, found bool, err error) {
+	var objs []T
+	bErr := nm.retryVSD(api, func() *bambou.Error {
+		var bErr *bambou.Error
+		objs, bErr = fetch()
+		return bErr
+	})
+	if bErr != nil {
+		return obj, false, fmt.Errorf("unable to read %s: %s", api, bErr.Description)
+	}
+	for _, o := range objs {
+		if match(o) {
+			return o, true, nil
+		}
+	}
+	return obj, false, nil
+}
+
+// vsdCreateOrUpdate creates the object from the configuration under its parent, when
+// a child matches it is updated with the configuration instead and only saved when the
+// configuration changes it. The create or update is written to the audit log, also
+// when it fails, created returns if the object is new
+func vsdCreateOrUpdate[T vsdObject](nm *NMgr, api, kind string, cfg map[string]interface{}, obj T,
+	fetch func() ([]T, *bambou.Error), match func(T) bool, create func(T) *bambou.Error) (T, bool, error) {
+	existing, found, err := vsdFind(nm, api, fetch, match)
+	if err != nil {
+		return obj, false, err
+	}
+	if found {
+		compared := make(map[string]interface{}, len(cfg))
+		for k, v := range cfg {
+			if !vsdWriteOnly[kind][k] {
+				compared[k] = v
+			}
+		}
+		before, _ := json.Marshal(existing)
+		if err := mergo.Map(existing, compared, mergo.WithOverride); err != nil {
+			return existing, false, err
+		}
+		if after, _ := json.Marshal(existing); bytes.Equal(before, after) {
+			log.Debugf("VSD %s already exists: %s", kind, existing.Identifier())
+			return existing, false, nil
+		}
+		if err := mergo.Map(existing, cfg, mergo.WithOverride); err != nil {
+			return existing, false, err
+		}
+		err := vsdError(nm.retryVSD(api, existing.Save))
+		nm.auditVSD("Update"+kind, cfg, existing.Identifier(), err)
+		if err != nil {
+			return existing, false, fmt.Errorf("unable to update %s %s: %s", kind, existing.Identifier(), err)
+		}
+		return existing, false, nil
+	}
+	if err := mergo.Map(obj, cfg, mergo.WithOverride); err != nil {
+		return obj, false, err
+	}
+	obj, err = vsdCreateOnce(nm, api, obj, fetch, match, create)
+	nm.auditVSD("Create"+kind, cfg, obj.Identifier(), err)
+	if err != nil {
+		return obj, false, fmt.Errorf("unable to create %s: %s", kind, err)
+	}
+	return obj, true, nil
+}
+
+// vsdCreate creates a child object that has no name to match on, the create is written
+// to the audit log, also when it fails
+func vsdCreate[T vsdObject](nm *NMgr, api, kind string, obj T,
+	fetch func() ([]T, *bambou.Error), match func(T) bool, create func(T) *bambou.Error) (T, error) {
+	obj, err := vsdCreateOnce(nm, api, obj, fetch, match, create)
+	nm.auditVSD("Create"+kind, obj, obj.Identifier(), err)
+	if err != nil {
+		return obj, fmt.Errorf("unable to create %s: %s", kind, err)
+	}
+	return obj, nil
+}
+
+// vsdCreateOnce creates the object with retries. A create is not idempotent, a failed
+// attempt may still have been committed by the VSD, so before a retry the children are
+// read again and a matching child is returned instead of creating a duplicate
+func vsdCreateOnce[T vsdObject](nm *NMgr, api string, obj T,
+	fetch func() ([]T, *bambou.Error), match func(T) bool, create func(T) *bambou.Error) (T, error) {
+	attempt := 0
+	bErr := nm.retryVSD(api, func() *bambou.Error {
+		attempt++
+		if attempt > 1 {
+			objs, bErr := fetch()
+			if bErr != nil {
+				return bErr
+			}
+			for _, o := range objs {
+				if match(o) {
+					log.Debugf("VSD %s created by a failed attempt: %s", api, o.Identifier())
+					obj = o
+					return nil
+				}
+			}
+		}
+		return create(obj)
+	})
+	return obj, vsdError(bErr)
+}
+
+// vsdDelete deletes the object, the delete is written to the audit log, also when it fails
+func vsdDelete[T vsdObject](nm *NMgr, api, kind string, obj T) error {
+	err := vsdError(nm.retryVSD(api, obj.Delete))
+	nm.auditVSD("Delete"+kind, obj, obj.Identifier(), err)
+	if err != nil {
+		return fmt.Errorf("unable to delete %s %s: %s", kind, obj.Identifier(), err)
+	}
+	return nil
+}
+
+// vsdDeleteAll deletes the children of a parent that match, it returns the number of
+// deleted objects
+func vsdDeleteAll[T vsdObject](nm *NMgr, api, kind string, fetch func() ([]T, *bambou.Error), match func(T) bool) (int, error) {
+	var objs []T
+	bErr := nm.retryVSD(api, func() *bambou.Error {
+		var bErr *bambou.Error
+		objs, bErr = fetch()
+		return bErr
+	})
+	if bErr != nil {
+		return 0, fmt.Errorf("unable to read %s: %s", api, bErr.Description)
+	}
+	n := 0
+	for _, o := range objs {
+		if !match(o) {
+			continue
+		}
+		if err := vsdDelete(nm, api, kind, o); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nuage-lab/aws-tgw-network-mgr/awsnmgr"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var auditFilter awsnmgr.AuditFilter
+var auditSince time.Duration
+
+// auditShowCmd represents the audit show command
+var auditShowCmd = &cobra.Command{
+	Use:          "show",
+	Short:        "show the entries of the audit log",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if auditSince > 0 {
+			auditFilter.Since = time.Now().Add(-auditSince)
+		}
+		entries, err := awsnmgr.ReadAuditLog(auditLogFile, auditFilter)
+		if err != nil {
+			return err
+		}
+
+		switch outputFormat {
+		case "json":
+			out, err := json.MarshalIndent(entries, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			return nil
+		case "yaml":
+			out, err := yaml.Marshal(entries)
+			if err != nil {
+				return err
+			}
+			fmt.Print(string(out))
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tRUN ID\tUSER\tAWS IDENTITY\tCOMMAND\tSERVICE\tREGION\tOPERATION\tRESULT\tRESOURCE IDS")
+		for _, e := range entries {
+			result := e.Result
+			if e.Error != "" {
+				result += ": " + e.Error
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Time.Format(time.RFC3339), e.RunID, e.User, e.AWSIdentity, e.Command, e.Service, e.Region, e.Operation, result, strings.Join(e.ResourceIDs, ","))
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	auditCmd.AddCommand(auditShowCmd)
+	auditShowCmd.Flags().StringVar(&auditFilter.RunID, "run-id", "", "show the entries of the run")
+	auditShowCmd.Flags().StringVar(&auditFilter.User, "user", "", "show the entries of the OS user")
+	auditShowCmd.Flags().StringVar(&auditFilter.Service, "service", "", "show the entries of the service, ec2, networkmanager or vsd")
+	auditShowCmd.Flags().StringVar(&auditFilter.Operation, "operation", "", "show the entries of the operations that match the glob pattern, e.g. 'Delete*'")
+	auditShowCmd.Flags().StringVar(&auditFilter.Resource, "resource", "", "show the entries of the resource ids or ARNs that match the glob pattern")
+	auditShowCmd.Flags().DurationVar(&auditSince, "since", 0, "show the entries of the last duration, e.g. 24h")
+	auditShowCmd.Flags().BoolVar(&auditFilter.Errors, "errors", false, "show only the failed calls")
+}
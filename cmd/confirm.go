@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nuage-lab/aws-tgw-network-mgr/awsnmgr"
+	log "github.com/sirupsen/logrus"
+)
+
+var assumeYes bool
+
+// confirmDestroy lists the resources the destroy deletes and asks for confirmation,
+// --yes skips the question
+func confirmDestroy(nm *awsnmgr.NMgr, sites, network bool) error {
+	plan, err := nm.DestroyPlan(sites, network)
+	if err != nil {
+		return fmt.Errorf("destroy refused: %s", err)
+	}
+	if len(plan) == 0 {
+		log.Infof("Nothing to delete....")
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, "The following resources will be deleted:")
+	for _, p := range plan {
+		fmt.Fprintf(os.Stderr, "  - %s\n", p)
+	}
+	if assumeYes {
+		return nil
+	}
+	fmt.Fprint(os.Stderr, "Do you want to continue? Only 'yes' will be accepted: ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.TrimSpace(answer) != "yes" {
+		return fmt.Errorf("destroy cancelled")
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var noRollback bool
+
 // deployCmd represents the deploy command
 var deployCmd = &cobra.Command{
 	Use:          "deploy",
@@ -18,4 +20,5 @@ var deployCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(deployCmd)
+	deployCmd.PersistentFlags().BoolVar(&noRollback, "no-rollback", false, "keep the resources created by a failed deploy instead of rolling them back")
 }
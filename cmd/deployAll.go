@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"github.com/nuage-lab/aws-tgw-network-mgr/awsnmgr"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// deployAllCmd represents the deploy all command
+var deployAllCmd = &cobra.Command{
+	Use:          "all",
+	Short:        "deploy the tgw and site configuration",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log.Info("deploying nuage aws tgw network manager configuration and sites ...")
+		opts := []awsnmgr.Option{
+			awsnmgr.WithDebug(debug),
+			awsnmgr.WithTimeout(timeout),
+			awsnmgr.WithRetryPolicy(retryPolicy()),
+			awsnmgr.WithRunID(runID),
+			awsnmgr.WithCommand(commandPath),
+			awsnmgr.WithAuditLog(auditLogFile),
+			awsnmgr.WithConfigFile(config),
+			awsnmgr.WithCheckpoint(deployState, deployResume),
+		}
+
+		nm, err := awsnmgr.NewAWsNMgrNuage(opts...)
+		if err != nil {
+			return err
+		}
+		defer nm.LogRetrySummary()
+		runNMgr = nm
+
+		// Parse topology information
+		if err = nm.ParseTopology(); err != nil {
+			return err
+		}
+		nm.NotifyDeployStarted()
+
+		// Create the global network and tgws, wait till the tgws are available and
+		// registered in the global network and create the sites
+		err = nm.CreateAWSNetworkMgrNetwork()
+		if err == nil {
+			err = nm.WaitTransitGatewaysReady()
+		}
+		if err == nil {
+			err = nm.CreateAWSNetworkMgrSites()
+		}
+		if err != nil {
+			log.Error(err)
+			if !noRollback {
+				nm.Rollback()
+			}
+		} else {
+			addVpnConnections(nm)
+		}
+		nm.LogDeployReport()
+		nm.NotifyDeployFinished(err)
+
+		return err
+	},
+}
+
+func init() {
+	deployCmd.AddCommand(deployAllCmd)
+	deployAllCmd.Flags().StringVarP(&deployState, "state", "s", "state.yml", "file to checkpoint the completed phases and resource ids to")
+	deployAllCmd.Flags().BoolVar(&deployResume, "resume", false, "resume the deploy from the completed phases in the state file")
+}
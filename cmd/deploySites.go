@@ -6,6 +6,9 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var deployState string
+var deployResume bool
+
 // deploySitesCmd represents the deploy command
 var deploySitesCmd = &cobra.Command{
 	Use:          "sites",
@@ -17,28 +20,50 @@ var deploySitesCmd = &cobra.Command{
 		opts := []awsnmgr.Option{
 			awsnmgr.WithDebug(debug),
 			awsnmgr.WithTimeout(timeout),
+			awsnmgr.WithRetryPolicy(retryPolicy()),
+			awsnmgr.WithRunID(runID),
+			awsnmgr.WithCommand(commandPath),
+			awsnmgr.WithAuditLog(auditLogFile),
 			awsnmgr.WithConfigFile(config),
+			awsnmgr.WithCheckpoint(deployState, deployResume),
 		}
 
 		nm, err := awsnmgr.NewAWsNMgrNuage(opts...)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
+		defer nm.LogRetrySummary()
+		runNMgr = nm
 
 		// Parse topology information
 		if err = nm.ParseTopology(); err != nil {
 			return err
 		}
+		if err = nm.SelectTopology(siteSelectors, deviceSelectors, connectionSelectors); err != nil {
+			return err
+		}
+		nm.NotifyDeployStarted()
 
 		// Create AWS resources
-		if err := nm.CreateAWSNetworkMgrSites(); err != nil {
+		err = nm.CreateAWSNetworkMgrSites()
+		if err != nil {
 			log.Error(err)
+			if !noRollback {
+				nm.Rollback()
+			}
+		} else {
+			addVpnConnections(nm)
 		}
+		nm.LogDeployReport()
+		nm.NotifyDeployFinished(err)
 
-		return nil
+		return err
 	},
 }
 
 func init() {
 	deployCmd.AddCommand(deploySitesCmd)
+	deploySitesCmd.Flags().StringVarP(&deployState, "state", "s", "state.yml", "file to checkpoint the completed phases and resource ids to")
+	deploySitesCmd.Flags().BoolVar(&deployResume, "resume", false, "resume the deploy from the completed phases in the state file")
+	addSelectorFlags(deploySitesCmd)
 }
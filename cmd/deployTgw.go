@@ -17,25 +17,38 @@ var deployTgwCmd = &cobra.Command{
 		opts := []awsnmgr.Option{
 			awsnmgr.WithDebug(debug),
 			awsnmgr.WithTimeout(timeout),
+			awsnmgr.WithRetryPolicy(retryPolicy()),
+			awsnmgr.WithRunID(runID),
+			awsnmgr.WithCommand(commandPath),
+			awsnmgr.WithAuditLog(auditLogFile),
 			awsnmgr.WithConfigFile(config),
 		}
 
 		nm, err := awsnmgr.NewAWsNMgrNuage(opts...)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
+		defer nm.LogRetrySummary()
+		runNMgr = nm
 
 		// Parse topology information
 		if err = nm.ParseTopology(); err != nil {
 			return err
 		}
+		nm.NotifyDeployStarted()
 
 		// Create AWS resources
-		if err := nm.CreateAWSNetworkMgrNetwork(); err != nil {
+		err = nm.CreateAWSNetworkMgrNetwork()
+		if err != nil {
 			log.Error(err)
+			if !noRollback {
+				nm.Rollback()
+			}
 		}
+		nm.LogDeployReport()
+		nm.NotifyDeployFinished(err)
 
-		return nil
+		return err
 	},
 }
 
@@ -17,4 +17,5 @@ var destroyCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(destroyCmd)
+	destroyCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "delete without asking for confirmation")
 }
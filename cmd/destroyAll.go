@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"github.com/nuage-lab/aws-tgw-network-mgr/awsnmgr"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// destroyAllCmd represents the destroy all command
+var destroyAllCmd = &cobra.Command{
+	Use:          "all",
+	Short:        "destroy the site and tgw configuration",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log.Info("destroying nuage aws tgw network manager sites and configuration ...")
+		opts := []awsnmgr.Option{
+			awsnmgr.WithDebug(debug),
+			awsnmgr.WithTimeout(timeout),
+			awsnmgr.WithRetryPolicy(retryPolicy()),
+			awsnmgr.WithRunID(runID),
+			awsnmgr.WithCommand(commandPath),
+			awsnmgr.WithAuditLog(auditLogFile),
+			awsnmgr.WithConfigFile(config),
+		}
+
+		nm, err := awsnmgr.NewAWsNMgrNuage(opts...)
+		if err != nil {
+			return err
+		}
+		defer nm.LogRetrySummary()
+		runNMgr = nm
+
+		// Parse topology information
+		if err = nm.ParseTopology(); err != nil {
+			return err
+		}
+		if err = confirmDestroy(nm, true, true); err != nil {
+			return err
+		}
+
+		// Delete the sites, wait till the VPN attachments are gone and delete the tgws
+		// and the global network
+		if err := nm.DeleteAWSNetworkMgrSites(); err != nil {
+			return err
+		}
+		if err := nm.WaitVpnAttachmentsDeleted(); err != nil {
+			return err
+		}
+		return nm.DeleteAWSNetworkMgrNetwork()
+	},
+}
+
+func init() {
+	destroyCmd.AddCommand(destroyAllCmd)
+}
@@ -17,19 +17,28 @@ var destroyTgwCmd = &cobra.Command{
 		opts := []awsnmgr.Option{
 			awsnmgr.WithDebug(debug),
 			awsnmgr.WithTimeout(timeout),
+			awsnmgr.WithRetryPolicy(retryPolicy()),
+			awsnmgr.WithRunID(runID),
+			awsnmgr.WithCommand(commandPath),
+			awsnmgr.WithAuditLog(auditLogFile),
 			awsnmgr.WithConfigFile(config),
 			//awstgwmgr.WithSecrets(&accessKey, &secretKey, &region),
 		}
 
 		nm, err := awsnmgr.NewAWsNMgrNuage(opts...)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
+		defer nm.LogRetrySummary()
+		runNMgr = nm
 
 		// Parse topology information
 		if err = nm.ParseTopology(); err != nil {
 			return err
 		}
+		if err = confirmDestroy(nm, false, true); err != nil {
+			return err
+		}
 
 		// Create AWS resources
 		if err := nm.DeleteAWSNetworkMgrNetwork(); err != nil {
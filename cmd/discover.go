@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/nuage-lab/aws-tgw-network-mgr/awsnmgr"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var discoverEnterprise string
+var discoverNsgs []string
+var discoverTgw string
+var discoverURL string
+var discoverFile string
+
+// discoverCmd represents the discover command
+var discoverCmd = &cobra.Command{
+	Use:          "discover",
+	Short:        "discover the sites, devices and connections of NSGs from VSD",
+	Aliases:      []string{"disc"},
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if outputFormat != "" && discoverFile == "" {
+			return fmt.Errorf("the topology must be written to --file when --output is set")
+		}
+		log.Info("discovering nuage NSG uplinks ...")
+		opts := []awsnmgr.Option{
+			awsnmgr.WithDebug(debug),
+			awsnmgr.WithTimeout(timeout),
+			awsnmgr.WithRetryPolicy(retryPolicy()),
+			awsnmgr.WithRunID(runID),
+			awsnmgr.WithCommand(commandPath),
+			awsnmgr.WithAuditLog(auditLogFile),
+			awsnmgr.WithConfigFile(config),
+			awsnmgr.WithNuageURL(discoverURL),
+		}
+
+		nm, err := awsnmgr.NewAWsNMgrNuage(opts...)
+		if err != nil {
+			return err
+		}
+		defer nm.LogRetrySummary()
+		runNMgr = nm
+
+		if discoverEnterprise == "" {
+			discoverEnterprise = nm.Config.Nuage.Enterprise
+		}
+		if discoverEnterprise == "" || len(discoverNsgs) == 0 {
+			return fmt.Errorf("an enterprise and at least one NSG are required")
+		}
+
+		c, err := nm.DiscoverTopology(discoverEnterprise, discoverNsgs, discoverTgw, region)
+		if err != nil {
+			return err
+		}
+
+		out, err := yaml.Marshal(c)
+		if err != nil {
+			return err
+		}
+		if discoverFile == "" {
+			fmt.Print(string(out))
+			return nil
+		}
+		log.Infof("Writing topology to %s", discoverFile)
+		return ioutil.WriteFile(discoverFile, out, 0644)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(discoverCmd)
+	discoverCmd.Flags().StringVarP(&discoverEnterprise, "enterprise", "e", "", "Nuage enterprise of the NSGs, defaults to the enterprise in the configuration file")
+	discoverCmd.Flags().StringSliceVarP(&discoverNsgs, "nsg", "n", nil, "names of the NSGs to discover")
+	discoverCmd.Flags().StringVarP(&discoverTgw, "tgw", "t", "tgw", "name of the tgw device the connections terminate on")
+	discoverCmd.Flags().StringVarP(&region, "region", "r", "us-west-2", "region of the tgw device")
+	discoverCmd.Flags().StringVarP(&discoverURL, "url", "u", "", "Nuage VSD url, defaults to the url in the configuration file")
+	discoverCmd.Flags().StringVarP(&discoverFile, "file", "f", "", "file to write the topology to, defaults to stdout")
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/nuage-lab/aws-tgw-network-mgr/awsnmgr"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var importFile string
+var importState string
+var importAdopt bool
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:          "import <global network id or name>",
+	Short:        "import an existing global network into a topology and state file",
+	Aliases:      []string{"imp"},
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if outputFormat != "" && importFile == "" {
+			return fmt.Errorf("the topology must be written to --file when --output is set")
+		}
+		log.Infof("importing global network %s ...", args[0])
+		opts := []awsnmgr.Option{
+			awsnmgr.WithDebug(debug),
+			awsnmgr.WithTimeout(timeout),
+			awsnmgr.WithRetryPolicy(retryPolicy()),
+			awsnmgr.WithRunID(runID),
+			awsnmgr.WithCommand(commandPath),
+			awsnmgr.WithAuditLog(auditLogFile),
+			awsnmgr.WithConfigFile(config),
+		}
+
+		nm, err := awsnmgr.NewAWsNMgrNuage(opts...)
+		if err != nil {
+			return err
+		}
+		defer nm.LogRetrySummary()
+		runNMgr = nm
+
+		c, state, err := nm.ImportGlobalNetwork(args[0], importAdopt)
+		if err != nil {
+			return err
+		}
+
+		out, err := yaml.Marshal(c)
+		if err != nil {
+			return err
+		}
+		if importFile == "" {
+			fmt.Print(string(out))
+		} else {
+			log.Infof("Writing topology to %s", importFile)
+			if err := ioutil.WriteFile(importFile, out, 0644); err != nil {
+				return err
+			}
+		}
+		if !importAdopt {
+			log.Warnf("The resources are not tagged with their topology name, deploy and destroy only find them after an import with --adopt")
+		}
+		log.Infof("Writing state to %s", importState)
+		return state.Save(importState)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().StringVarP(&importFile, "file", "f", "", "file to write the topology to, defaults to stdout")
+	importCmd.Flags().StringVarP(&importState, "state", "s", "state.yml", "file to write the state with the resource ids to")
+	importCmd.Flags().BoolVarP(&importAdopt, "adopt", "a", false, "tag the imported resources with their topology name, so deploy and destroy manage them")
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/nuage-lab/aws-tgw-network-mgr/awsnmgr"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// output format of the command result, without a format only the logs are written
+var outputFormat string
+
+// path of the command that runs, e.g. nuage-aws-networkmgr deploy sites
+var commandPath string
+
+// runNMgr is the network manager of the command that runs, its result is written
+// when the command ends
+var runNMgr *awsnmgr.NMgr
+
+// checkOutputFormat validates the --output flag
+func checkOutputFormat() error {
+	switch outputFormat {
+	case "", "json", "yaml", "table":
+		return nil
+	}
+	return fmt.Errorf("invalid output format %s, supported formats are json, yaml and table", outputFormat)
+}
+
+// addVpnConnections adds the tunnel details of the deployed vpn connections to the result
+func addVpnConnections(nm *awsnmgr.NMgr) {
+	if outputFormat == "" {
+		return
+	}
+	status, err := nm.GetConnectionStatus()
+	if err != nil {
+		log.Errorf("Error getting the vpn connection status: %s", err)
+		return
+	}
+	nm.SetVpnConnections(status)
+}
+
+// writeResult writes the result of the command in the output format to stdout, the
+// logs go to stderr. The error the command returns is added to the errors of the result,
+// a command that fails before its network manager exists only reports the error
+func writeResult(cmdErr error) {
+	if outputFormat == "" {
+		return
+	}
+	var r *awsnmgr.Result
+	if runNMgr != nil {
+		r = runNMgr.Result(commandPath)
+	} else {
+		r = &awsnmgr.Result{Command: commandPath, RunID: runID}
+	}
+	if cmdErr != nil {
+		r.AddError(cmdErr.Error())
+	}
+	switch outputFormat {
+	case "json":
+		out, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		fmt.Println(string(out))
+	case "yaml":
+		out, err := yaml.Marshal(r)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		fmt.Print(string(out))
+	case "table":
+		writeResultTable(os.Stdout, r)
+	}
+}
+
+// writeResultTable writes the resources, waits, vpn tunnels, warnings and errors of the
+// result as tables
+func writeResultTable(out io.Writer, r *awsnmgr.Result) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACTION\tKIND\tNAME\tID\tARN")
+	for _, a := range []struct {
+		action    string
+		resources []awsnmgr.ResourceResult
+	}{
+		{"created", r.Created},
+		{"updated", r.Updated},
+		{"deleted", r.Deleted},
+		{"rolled back", r.RolledBack},
+	} {
+		for _, res := range a.resources {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", a.action, res.Kind, res.Name, res.ID, res.ARN)
+		}
+	}
+	w.Flush()
+
+	if len(r.Waits) > 0 {
+		fmt.Fprintln(out)
+		fmt.Fprintln(w, "WAIT\tDURATION")
+		for _, wr := range r.Waits {
+			fmt.Fprintf(w, "%s\t%s\n", wr.Name, wr.Duration)
+		}
+		w.Flush()
+	}
+
+	if len(r.VpnConnections) > 0 {
+		fmt.Fprintln(out)
+		fmt.Fprintln(w, "CONNECTION\tREGION\tVPN CONNECTION\tSTATE\tACCELERATED\tTUNNEL\tOUTSIDE IP\tINSIDE CIDR\tTUNNEL STATUS\tROUTES")
+		for _, cs := range r.VpnConnections {
+			if len(cs.Tunnels) == 0 {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t\t\t\t\t\n", cs.Name, cs.Region, cs.VpnConnectionID, cs.State, cs.Accelerated)
+			}
+			for i, t := range cs.Tunnels {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%d\t%s\t%s\t%s\t%d\n", cs.Name, cs.Region, cs.VpnConnectionID, cs.State, cs.Accelerated, i, t.OutsideIP, t.InsideCidr, t.Status, t.AcceptedRoutes)
+			}
+		}
+		w.Flush()
+	}
+
+	for _, m := range r.Warnings {
+		fmt.Fprintf(out, "WARNING: %s\n", m)
+	}
+	for _, m := range r.Errors {
+		fmt.Fprintf(out, "ERROR: %s\n", m)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/nuage-lab/aws-tgw-network-mgr/awsnmgr"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -19,6 +20,18 @@ var accessKey string
 var secretKey string
 var region string
 var vpc string
+var retryMaxAttempts int
+var siteSelectors []string
+var deviceSelectors []string
+var connectionSelectors []string
+var retryMaxTime time.Duration
+var logFormat string
+
+// append-only log of the mutating AWS and VSD calls
+var auditLogFile string
+
+// id of the run, set on every log entry, AWS request and created resource
+var runID string
 
 // path to the topology file
 var config string
@@ -27,18 +40,31 @@ var config string
 var rootCmd = &cobra.Command{
 	Use:   "nuage-aws-networkmgr",
 	Short: "Automates the connectivity of the aws network manager with nuage",
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		if debug {
 			log.SetLevel(log.DebugLevel)
 		}
-
+		if err := awsnmgr.SetLogFormat(logFormat); err != nil {
+			return err
+		}
+		runID = awsnmgr.NewRunID()
+		log.AddHook(&awsnmgr.RunIDHook{RunID: runID})
+		commandPath = cmd.CommandPath()
+		return checkOutputFormat()
 	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	// a fatal log exits without returning, its message is already in the result
+	log.RegisterExitHandler(func() { writeResult(nil) })
+	err := rootCmd.Execute()
+	if runNMgr != nil {
+		runNMgr.FlushEvents()
+	}
+	writeResult(err)
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -47,5 +73,27 @@ func init() {
 	rootCmd.SilenceUsage = true
 	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "enable debug mode")
 	rootCmd.PersistentFlags().StringVarP(&config, "config", "c", "", "path to the file with configuration information")
+	rootCmd.PersistentFlags().IntVar(&retryMaxAttempts, "retry-max-attempts", 8, "maximum attempts of a throttled or failed AWS or VSD API call")
+	rootCmd.PersistentFlags().DurationVar(&retryMaxTime, "retry-max-time", 5*time.Minute, "maximum time a run waits on API call retries")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 30*time.Minute, "maximum time a wait for AWS resources to reach a state")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "format of the logs, text or json")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "", "write the result of the command to stdout as json, yaml or table")
+	rootCmd.PersistentFlags().StringVar(&auditLogFile, "audit-log", "audit.jsonl", "file the mutating AWS and VSD calls are appended to")
+
+}
+
+// retryPolicy returns the retry policy from the retry flags
+func retryPolicy() awsnmgr.RetryPolicy {
+	return awsnmgr.RetryPolicy{
+		MaxAttempts: retryMaxAttempts,
+		MaxBackoff:  20 * time.Second,
+		MaxTotal:    retryMaxTime,
+	}
+}
 
+// addSelectorFlags adds the flags that select the branches of the topology a command works on
+func addSelectorFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&siteSelectors, "site", nil, "only the sites that match the glob patterns")
+	cmd.Flags().StringSliceVar(&deviceSelectors, "device", nil, "only the sdwan devices that match the glob patterns")
+	cmd.Flags().StringSliceVar(&connectionSelectors, "connection", nil, "only the connections whose endpoint name (<site>-<device>-<port>) matches the glob patterns")
 }
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nuage-lab/aws-tgw-network-mgr/awsnmgr"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var serveListen string
+var serveInterval time.Duration
+var serveState string
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:          "serve",
+	Short:        "poll the health of the topology and expose it on a prometheus /metrics endpoint",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := []awsnmgr.Option{
+			awsnmgr.WithDebug(debug),
+			awsnmgr.WithTimeout(timeout),
+			awsnmgr.WithRetryPolicy(retryPolicy()),
+			awsnmgr.WithRunID(runID),
+			awsnmgr.WithCommand(commandPath),
+			awsnmgr.WithAuditLog(auditLogFile),
+			awsnmgr.WithConfigFile(config),
+		}
+
+		nm, err := awsnmgr.NewAWsNMgrNuage(opts...)
+		if err != nil {
+			return err
+		}
+		defer nm.LogRetrySummary()
+		runNMgr = nm
+
+		// Parse topology information
+		if err = nm.ParseTopology(); err != nil {
+			return err
+		}
+
+		var mu sync.Mutex
+		metrics := nm.CollectMetrics(serveState)
+		go func() {
+			for range time.Tick(serveInterval) {
+				m := nm.CollectMetrics(serveState)
+				mu.Lock()
+				metrics = m
+				mu.Unlock()
+			}
+		}()
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			m := metrics
+			mu.Unlock()
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+			w.Write(m)
+		})
+
+		log.Infof("serving metrics on %s/metrics, polling every %s", serveListen, serveInterval)
+		return http.ListenAndServe(serveListen, mux)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVarP(&serveListen, "listen", "l", ":9100", "address to serve the /metrics endpoint on")
+	serveCmd.Flags().DurationVarP(&serveInterval, "interval", "i", time.Minute, "interval to poll the tunnels, associations and VSD")
+	serveCmd.Flags().StringVarP(&serveState, "state", "s", "state.yml", "state file with the phase durations of the last deploy")
+}
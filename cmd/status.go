@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/nuage-lab/aws-tgw-network-mgr/awsnmgr"
+	"github.com/spf13/cobra"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:          "status",
+	Short:        "show the status of the vpn connections",
+	Aliases:      []string{"st"},
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := []awsnmgr.Option{
+			awsnmgr.WithDebug(debug),
+			awsnmgr.WithTimeout(timeout),
+			awsnmgr.WithRetryPolicy(retryPolicy()),
+			awsnmgr.WithRunID(runID),
+			awsnmgr.WithCommand(commandPath),
+			awsnmgr.WithAuditLog(auditLogFile),
+			awsnmgr.WithConfigFile(config),
+		}
+
+		nm, err := awsnmgr.NewAWsNMgrNuage(opts...)
+		if err != nil {
+			return err
+		}
+		defer nm.LogRetrySummary()
+		runNMgr = nm
+
+		// Parse topology information
+		if err = nm.ParseTopology(); err != nil {
+			return err
+		}
+
+		status, err := nm.GetConnectionStatus()
+		if err != nil {
+			return err
+		}
+
+		nm.SetVpnConnections(status)
+		if outputFormat != "" {
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CONNECTION\tREGION\tVPN CONNECTION\tSTATE\tACCELERATED\tTUNNEL\tOUTSIDE IP\tINSIDE CIDR\tTUNNEL STATUS\tROUTES")
+		for _, cs := range status {
+			if len(cs.Tunnels) == 0 {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t\t\t\t\t\n", cs.Name, cs.Region, cs.VpnConnectionID, cs.State, cs.Accelerated)
+			}
+			for i, t := range cs.Tunnels {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%d\t%s\t%s\t%s\t%d\n", cs.Name, cs.Region, cs.VpnConnectionID, cs.State, cs.Accelerated, i, t.OutsideIP, t.InsideCidr, t.Status, t.AcceptedRoutes)
+			}
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/nuage-lab/aws-tgw-network-mgr/awsnmgr"
+	"github.com/spf13/cobra"
+)
+
+// webhookTestCmd represents the webhook test command
+var webhookTestCmd = &cobra.Command{
+	Use:          "test",
+	Short:        "post a test event to the webhooks of the topology",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return awsnmgr.TestWebhooks(config, runID, commandPath)
+	},
+}
+
+func init() {
+	webhookCmd.AddCommand(webhookTestCmd)
+}
@@ -3,10 +3,14 @@ module github.com/nuage-lab/aws-tgw-network-mgr
 go 1.21
 
 require (
+	github.com/aws/aws-sdk-go-v2 v0.30.0
 	github.com/aws/aws-sdk-go-v2/config v0.3.0
 	github.com/aws/aws-sdk-go-v2/service/ec2 v0.30.0
 	github.com/aws/aws-sdk-go-v2/service/networkmanager v0.30.0
+	github.com/aws/aws-sdk-go-v2/service/sts v0.30.0
+	github.com/awslabs/smithy-go v0.4.0
 	github.com/henderiw/nuage-wrapper v0.1.6
+	github.com/imdario/mergo v0.3.11
 	github.com/kelvins/geocoder v0.0.0-20200113010004-f579500e9e27
 	github.com/nuagenetworks/go-bambou v1.0.1
 	github.com/sirupsen/logrus v1.7.0
@@ -15,15 +19,11 @@ require (
 )
 
 require (
-	github.com/aws/aws-sdk-go-v2 v0.30.0 // indirect
 	github.com/aws/aws-sdk-go-v2/credentials v0.1.5 // indirect
 	github.com/aws/aws-sdk-go-v2/ec2imds v0.1.5 // indirect
 	github.com/aws/aws-sdk-go-v2/service/internal/presigned-url v0.1.2 // indirect
-	github.com/aws/aws-sdk-go-v2/service/sts v0.30.0 // indirect
-	github.com/awslabs/smithy-go v0.4.0 // indirect
 	github.com/ccding/go-config-reader v0.0.0-20130817225950-8b6c2b50197f // indirect
 	github.com/ccding/go-logging v0.0.0-20190618175518-0ac4cc1a6533 // indirect
-	github.com/imdario/mergo v0.3.11 // indirect
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
 	github.com/kardianos/osext v0.0.0-20190222173326-2bc1f35cddc0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect